@@ -33,6 +33,16 @@ func configure() {
 		panic(emperror.Wrap(err, "failed to read configuration"))
 	}
 
+	// the validate-config command reports the problems of the configuration itself
+	if pflag.Arg(0) == "validate-config" {
+		return
+	}
+
+	err = config.ValidateStrict(viper.GetViper())
+	if err != nil {
+		panic(err)
+	}
+
 	err = viper.Unmarshal(&configuration)
 	if err != nil {
 		panic(emperror.Wrap(err, "failed to unmarshal configuration"))
@@ -18,25 +18,37 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
-	evbus "github.com/asaskevich/EventBus"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	yaml "gopkg.in/yaml.v2"
 
+	"github.com/banzaicloud/hollowtrees/internal/dedup"
 	"github.com/banzaicloud/hollowtrees/internal/flows"
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/platform/admin"
 	"github.com/banzaicloud/hollowtrees/internal/platform/config"
+	"github.com/banzaicloud/hollowtrees/internal/platform/eventbus"
 	"github.com/banzaicloud/hollowtrees/internal/platform/healthcheck"
 	"github.com/banzaicloud/hollowtrees/internal/platform/log"
 	"github.com/banzaicloud/hollowtrees/internal/plugin"
+	_ "github.com/banzaicloud/hollowtrees/internal/plugin/builtin"
 	"github.com/banzaicloud/hollowtrees/internal/promalert"
+	"github.com/banzaicloud/hollowtrees/internal/scheduler"
+	"github.com/banzaicloud/hollowtrees/internal/sources"
+	"github.com/banzaicloud/hollowtrees/internal/spotpoller"
 )
 
 // nolint: gochecknoinits
 func init() {
 	pflag.Bool("version", false, "Show version information")
 	pflag.Bool("dump-config", false, "Dump configuration to the console")
+
+	// flags after the command name belong to the command
+	pflag.CommandLine.SetInterspersed(false)
 }
 
 func main() {
@@ -60,33 +72,132 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Validate configuration if asked for, the configuration is not loaded in this case
+	if pflag.Arg(0) == "validate-config" {
+		err := config.ValidateStrict(viper.GetViper())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("configuration is valid")
+		os.Exit(0)
+	}
+
 	// Create logger
 	logger := log.NewLogger(configuration.Log)
 
 	// Create error handler
 	errorHandler := config.ErrorHandler(logger)
 
+	// Run command if asked for
+	switch pflag.Arg(0) {
+	case "":
+	case "replay":
+		err := runReplay(pflag.Args()[1:], logger, errorHandler)
+		if err != nil {
+			errorHandler.Handle(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", pflag.Arg(0))
+		os.Exit(2)
+	}
+
 	// Create event bus
-	eventBus := evbus.New()
+	eventBus, err := eventbus.New(configuration.EventBus, logger, errorHandler)
+	if err != nil {
+		errorHandler.Handle(err)
+		os.Exit(2)
+	}
+
+	// Load flows and plugins defined besides the config file
+	loader, err := sources.New(configuration.Sources, logger, errorHandler)
+	if err != nil {
+		errorHandler.Handle(err)
+		os.Exit(2)
+	}
+	err = loader.Load(viper.GetViper())
+	if err != nil {
+		errorHandler.Handle(err)
+		os.Exit(2)
+	}
 
 	// Create plugin manager
-	pluginManager := plugin.NewManager(logger, errorHandler)
-	err := pluginManager.LoadFromConfig(viper.GetViper())
+	pluginManager := plugin.NewManager(logger, errorHandler,
+		plugin.WithEventPublisher(plugin.NewEventDispatcher(eventBus)),
+		plugin.WithTenantKeys(configuration.FlowEngine.Tenancy.OrgKey, configuration.FlowEngine.Tenancy.ClusterKey),
+		plugin.WithCircuitBreaker(configuration.CircuitBreaker),
+		plugin.WithOperations(configuration.Operations),
+	)
+	err = pluginManager.LoadFromConfig(viper.GetViper())
 	if err != nil {
 		errorHandler.Handle(err)
 		os.Exit(2)
 	}
 	// Add internal demo plugin
-	pluginManager.Add(plugin.NewInternalPlugin("internal-demo", logger))
+	demoPlugin, err := pluginManager.NewInternalPlugin(plugin.DemoPluginName, "log", nil)
+	if err != nil {
+		errorHandler.Handle(err)
+		os.Exit(2)
+	}
+	pluginManager.Add(demoPlugin)
+
+	// Create event history store, incoming events are recorded before any flow handles them
+	var historyStore *history.BoltStore
+	flowOptions := []flows.ManagerOption{flows.WithEngineConfig(configuration.FlowEngine)}
+	if configuration.History.Enabled {
+		historyStore, err = history.NewBoltStore(configuration.History.Path, configuration.History.Retention)
+		if err != nil {
+			errorHandler.Handle(err)
+			os.Exit(2)
+		}
+
+		recorder := history.NewRecorder(historyStore, errorHandler)
+		err = eventBus.SubscribeAsync(flows.CEIncomingTopic, recorder.Handle, false)
+		if err != nil {
+			errorHandler.Handle(err)
+			os.Exit(2)
+		}
+		flowOptions = append(flowOptions, flows.WithExecutionRecorder(recorder))
+	}
+
+	// Create cooldown store, cooldowns are stored in the history database if it is enabled
+	cooldownStore := historyStore
+	if configuration.FlowEngine.Cooldowns.Persist {
+		if cooldownStore == nil {
+			cooldownStore, err = history.NewBoltStore(configuration.FlowEngine.Cooldowns.Path, 0)
+			if err != nil {
+				errorHandler.Handle(err)
+				os.Exit(2)
+			}
+			go cooldownStore.RunCleanup(time.Hour, logger, errorHandler)
+		}
+		flowOptions = append(flowOptions, flows.WithCooldownStore(cooldownStore))
+	}
 
 	// Create flow manager
-	flowManager := flows.NewManager(logger, errorHandler, flows.NewEventDispatcher(eventBus), pluginManager)
+	flowManager := flows.NewManager(logger, errorHandler, flows.NewEventDispatcher(eventBus), pluginManager, flowOptions...)
 	err = flowManager.LoadFlows(viper.GetViper())
 	if err != nil {
 		errorHandler.Handle(err)
 		os.Exit(2)
 	}
 
+	// Reloads the flows and plugins when their custom resources change, the flows are loaded
+	// with the new plugins and the previous plugins are restored if they are rejected
+	go loader.Watch(func(v *viper.Viper) error {
+		return pluginManager.ReloadFromConfig(v, func() error {
+			return flowManager.LoadFlows(v)
+		})
+	})
+
+	// Starts processing events, subscriptions must be made before this point
+	eventBus.Start()
+
+	// Incoming events are deduplicated before reaching the event bus
+	ingestion := dedup.New(configuration.Dedup, logger, eventBus)
+
 	var wg sync.WaitGroup
 
 	// Starts health check HTTP server
@@ -95,12 +206,58 @@ func main() {
 		healthcheck.New(configuration.Healthcheck, logger, errorHandler)
 	}()
 
+	// Starts admin API
+	if configuration.Admin.Enabled {
+		adminServer := admin.New(configuration.Admin, logger, errorHandler)
+		adminServer.Register(plugin.NewAPI(pluginManager), flows.NewAPI(flowManager.Executions()))
+		prometheus.MustRegister(pluginManager, flowManager)
+		if historyStore != nil {
+			adminServer.Register(history.NewAPI(historyStore, errorHandler))
+		}
+
+		wg.Add(1)
+		go func() {
+			adminServer.Run()
+		}()
+	}
+
+	// Deletes expired history records
+	if historyStore != nil {
+		interval := time.Hour
+		if configuration.History.Retention < interval {
+			interval = configuration.History.Retention
+		}
+		go historyStore.RunCleanup(interval, logger, errorHandler)
+	}
+
 	// Starts prometheus alert manager
 	wg.Add(1)
 	go func() {
-		promalert.New(configuration.Promalert, logger, errorHandler, promalert.NewEventDispatcher(eventBus)).Run()
+		promalert.New(configuration.Promalert, logger, errorHandler, promalert.NewEventDispatcher(ingestion)).Run()
 	}()
 
+	// Starts spot interruption notice poller
+	if configuration.SpotPoller.Enabled {
+		wg.Add(1)
+		go func() {
+			spotpoller.New(configuration.SpotPoller, logger, errorHandler, spotpoller.NewEventDispatcher(ingestion)).Run()
+		}()
+	}
+
+	// Starts publishing scheduled events
+	if configuration.Scheduler.Enabled {
+		s, err := scheduler.New(configuration.Scheduler, logger, errorHandler, scheduler.NewEventDispatcher(ingestion))
+		if err != nil {
+			errorHandler.Handle(err)
+			os.Exit(2)
+		}
+
+		wg.Add(1)
+		go func() {
+			s.Run()
+		}()
+	}
+
 	logger.Infof("%s started", config.FriendlyServiceName)
 
 	wg.Wait()
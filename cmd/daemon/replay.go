@@ -0,0 +1,121 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/replay"
+	"github.com/banzaicloud/hollowtrees/internal/sources"
+)
+
+// runReplay feeds recorded events through the flows in dry-run mode and prints which flows would have fired
+func runReplay(args []string, logger log.Logger, errorHandler emperror.Handler) error {
+	flags := pflag.NewFlagSet("replay", pflag.ExitOnError)
+	flowsPath := flags.String("flows", "", "Configuration file of the flows to replay (default: flows of the main configuration)")
+	historyURL := flags.String("history-url", "", "Admin API URL of a running instance to read the events from (default: read history.path)")
+	from := flags.String("from", "", "Replay events received after this time (RFC3339)")
+	to := flags.String("to", "", "Replay events received before this time (RFC3339)")
+	eventType := flags.String("type", "", "Replay events of this type only")
+	clusterID := flags.String("cluster-id", "", "Replay events of this cluster only")
+	_ = flags.Parse(args)
+
+	query := history.Query{
+		Type:      *eventType,
+		ClusterID: *clusterID,
+	}
+
+	var err error
+	for _, t := range []struct {
+		value  string
+		target *time.Time
+	}{{*from, &query.From}, {*to, &query.To}} {
+		if t.value == "" {
+			continue
+		}
+		*t.target, err = time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return emperror.Wrap(err, "invalid time range")
+		}
+	}
+
+	var source replay.Source
+	if *historyURL != "" {
+		source = history.NewClient(*historyURL)
+	} else {
+		store, err := history.NewReadOnlyBoltStore(configuration.History.Path)
+		if errors.Cause(err) == history.ErrDatabaseLocked {
+			return emperror.Wrap(err, "could not open history, stop the daemon or use --history-url to read it through the admin API")
+		}
+		if err != nil {
+			return emperror.Wrap(err, "could not open history")
+		}
+		defer store.Close()
+		source = store
+	}
+
+	v := viper.GetViper()
+	if *flowsPath != "" {
+		v = viper.New()
+		v.SetConfigFile(*flowsPath)
+		err = v.ReadInConfig()
+		if err != nil {
+			return emperror.Wrap(err, "could not read flow configuration")
+		}
+	} else {
+		// flows of the flows directory are replayed as well, custom resources are not read
+		config := configuration.Sources
+		config.Kubernetes.Enabled = false
+		loader, err := sources.New(config, logger, errorHandler)
+		if err != nil {
+			return err
+		}
+		err = loader.Load(v)
+		if err != nil {
+			return emperror.Wrap(err, "could not load flows")
+		}
+	}
+
+	report, err := replay.New(logger, errorHandler).Run(source, query, v)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tFLOW\tGROUP KEY\tEVENT\tPLUGINS\tSTATUS")
+	for _, e := range report.Executions {
+		plugins := make([]string, 0, len(e.Plugins))
+		for _, p := range e.Plugins {
+			plugins = append(plugins, p.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.StartedAt.Format(time.RFC3339), e.FlowID, e.GroupKey, e.EventType, strings.Join(plugins, ","), e.Status)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d events replayed, %d flow executions\n", report.Events, len(report.Executions))
+
+	return nil
+}
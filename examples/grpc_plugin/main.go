@@ -17,18 +17,63 @@ package main
 import (
 	"flag"
 	"fmt"
+	"time"
 
 	gp "github.com/banzaicloud/hollowtrees/pkg/grpcplugin"
 )
 
-// dummyEventHandler dummy implementation of EventHandler
-type dummyEventHandler struct{}
+// dummyEventHandler dummy implementation of EventHandler, the embedded
+// Operations reports the state of its long-running operations
+type dummyEventHandler struct {
+	*gp.Operations
+}
 
-// Handle dummy implementation
+// Handle dummy implementation, drains run as long-running operations
 func (d *dummyEventHandler) Handle(event *gp.CloudEvent) (*gp.Result, error) {
 	fmt.Printf("got GRPC request, handling alert: %s\n", event.Data)
 
-	return &gp.Result{Status: "ok"}, nil
+	drainTimeout, err := event.ParamDuration("drainTimeout", 0)
+	if err != nil {
+		return nil, err
+	}
+	if drainTimeout == 0 {
+		return &gp.Result{Status: "ok"}, nil
+	}
+
+	return d.Start(event, func() (*gp.Result, error) {
+		time.Sleep(drainTimeout)
+		fmt.Printf("drained node %s\n", event.ParamString("node", ""))
+
+		return &gp.Result{Status: "drained"}, nil
+	}), nil
+}
+
+// HandleWithProgress reports the progress of handling the alert to Hollowtrees
+func (d *dummyEventHandler) HandleWithProgress(event *gp.CloudEvent, progress gp.ProgressReporter) (*gp.Result, error) {
+	err := progress.Report(0, "handling alert", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := d.Handle(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, progress.Report(100, "alert handled", map[string]string{"node": event.ParamString("node", "")})
+}
+
+// Describe tells Hollowtrees the capabilities of the plugin
+func (d *dummyEventHandler) Describe() *gp.Description {
+	return &gp.Description{
+		Name:       "dummy",
+		Version:    "0.1.0",
+		EventTypes: []string{"prometheus.server.alert.*"},
+		Params: []gp.ParamSpec{
+			{Name: "node", Type: gp.ParamTypeString, Description: "node to act on"},
+			{Name: "drainTimeout", Type: gp.ParamTypeDuration, Description: "time to wait for the drain"},
+		},
+	}
 }
 
 var listenAddr string
@@ -41,7 +86,7 @@ func main() {
 	flag.Parse()
 
 	fmt.Printf("Hollowtrees Dummy GRPC EventHandler Plugin listening on %s\n", listenAddr)
-	err := gp.Serve(listenAddr, &dummyEventHandler{})
+	err := gp.Serve(listenAddr, &dummyEventHandler{Operations: gp.NewOperations(time.Hour)})
 	if err != nil {
 		panic(err)
 	}
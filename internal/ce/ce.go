@@ -15,10 +15,22 @@
 package ce
 
 import (
+	"encoding/json"
+
 	ce "github.com/cloudevents/sdk-go/v02"
 	"github.com/spf13/cast"
 )
 
+const (
+	// AlertStatusKey is the extension key of the status of alert events: firing or resolved
+	AlertStatusKey = "alertstatus"
+	AlertFiring    = "firing"
+	AlertResolved  = "resolved"
+
+	// AlertIDKey is the extension key identifying the alert of alert events regardless of its status
+	AlertIDKey = "alertid"
+)
+
 // Event describes a wrapped CloudEvent
 type Event struct {
 	ce.Event
@@ -32,14 +44,64 @@ func (e Event) GetExtensions() map[string]string {
 	}
 
 	switch t {
-	case "prometheus":
-		return e.getExtensionsForPrometheusAlert()
+	case "prometheus", "spot":
+		return e.getExtensionsFromLabels()
 	}
 
 	return nil
 }
 
-func (e Event) getExtensionsForPrometheusAlert() map[string]string {
+// Attributes returns the extension attributes of the event, eg. the Prometheus alert labels
+func (e Event) Attributes() (map[string]interface{}, error) {
+	b, err := e.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes map[string]interface{}
+	err = json.Unmarshal(b, &attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	for p := range e.Properties() {
+		delete(attributes, p)
+	}
+
+	return attributes, nil
+}
+
+// Clone returns a copy of the event, setting attributes on the copy does not change the original
+func (e Event) Clone() (*Event, error) {
+	attributes, err := e.Attributes()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Event{
+		Event: ce.Event{
+			SpecVersion: e.SpecVersion,
+			Type:        e.Type,
+			Source:      e.Source,
+			ID:          e.ID,
+			Time:        e.Time,
+			SchemaURL:   e.SchemaURL,
+			ContentType: e.ContentType,
+			Data:        e.Data,
+		},
+	}
+
+	// attribute values are taken from the original event to preserve their types
+	for k := range attributes {
+		if v, ok := e.Get(k); ok {
+			c.Set(k, v)
+		}
+	}
+
+	return c, nil
+}
+
+func (e Event) getExtensionsFromLabels() map[string]string {
 	if l, ok := e.Get("labels"); ok {
 		return cast.ToStringMapString(l)
 	}
@@ -0,0 +1,60 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ce
+
+import (
+	"net/url"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/cast"
+)
+
+const (
+	// HopsKey is the extension key of the number of events an event was derived through
+	HopsKey = "hops"
+
+	// CorrelationIDKey is the extension key of the correlation ID shared by related events
+	CorrelationIDKey = "correlationid"
+)
+
+// Hops returns how many events the event was derived through, zero for external events
+func (e Event) Hops() int {
+	if v, ok := e.Get(HopsKey); ok {
+		return cast.ToInt(v)
+	}
+
+	return 0
+}
+
+// Derive returns a new event caused by this one, it keeps the correlation ID
+// and increments the hop counter used for loop protection
+func (e Event) Derive(eventType string, source url.URL, t time.Time) *Event {
+	d := &Event{}
+	d.SpecVersion = "0.2"
+	d.Type = eventType
+	d.Source = source
+	d.ID = uuid.NewV4().String()
+	d.Time = &t
+
+	cid, ok := e.GetString(CorrelationIDKey)
+	if !ok || cid == "" {
+		cid = uuid.NewV4().String()
+	}
+	d.Set(CorrelationIDKey, cid)
+	d.Set(HopsKey, e.Hops()+1)
+
+	return d
+}
@@ -0,0 +1,66 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ce
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// FingerprintKey is the extension key of the event fingerprint
+const FingerprintKey = "fingerprint"
+
+// fingerprintNamespace is the UUID namespace of event IDs derived from fingerprints
+var fingerprintNamespace = uuid.NewV5(uuid.NamespaceURL, "https://github.com/banzaicloud/hollowtrees/fingerprint") // nolint: gochecknoglobals
+
+// Fingerprint returns a stable identifier of the event, subsequent events describing
+// the same occurrence have the same fingerprint
+func (e Event) Fingerprint() string {
+	if f, ok := e.GetString(FingerprintKey); ok && f != "" {
+		return f
+	}
+
+	return Fingerprint(map[string]string{
+		"source": e.Source.String(),
+		"id":     e.ID,
+	})
+}
+
+// Fingerprint returns a hash of the given key-value pairs which does not depend on their order
+func Fingerprint(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(values[k]))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// IDFromFingerprint returns a deterministic event ID for the given fingerprint
+func IDFromFingerprint(fingerprint string) string {
+	return uuid.NewV5(fingerprintNamespace, fingerprint).String()
+}
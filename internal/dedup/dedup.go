@@ -0,0 +1,112 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"sync"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+type baseEventPublisher interface {
+	Publish(topic string, args ...interface{}) error
+}
+
+// Deduplicator is an event publisher which drops events whose
+// fingerprint was already published within the configured TTL
+type Deduplicator struct {
+	enabled bool
+	ttl     time.Duration
+	seen    *cache.Cache
+
+	// guards the fingerprint keys of the last published status of alerts
+	mu sync.Mutex
+
+	logger log.Logger
+	eb     baseEventPublisher
+}
+
+// New returns an initialized Deduplicator publishing to eb
+func New(config Config, logger log.Logger, eb baseEventPublisher) *Deduplicator {
+	return &Deduplicator{
+		enabled: config.Enabled,
+		ttl:     config.TTL,
+		seen:    cache.New(config.TTL, time.Minute),
+
+		logger: logger,
+		eb:     eb,
+	}
+}
+
+// Publish publishes the event unless it is a duplicate
+func (d *Deduplicator) Publish(topic string, args ...interface{}) error {
+	event, ok := d.event(args)
+	if !d.enabled || !ok {
+		return d.eb.Publish(topic, args...)
+	}
+
+	key := topic + "/" + event.Fingerprint()
+
+	// Add fails if the key is already present, which makes check-and-set atomic
+	if err := d.seen.Add(key, true, d.ttl); err != nil {
+		cid, _ := event.GetString("correlationid")
+		d.logger.WithFields(log.Fields{
+			"correlation-id": cid,
+			"event-id":       event.ID,
+			"type":           event.Type,
+			"fingerprint":    event.Fingerprint(),
+		}).Debug("dropping duplicate event")
+		return nil
+	}
+
+	err := d.eb.Publish(topic, args...)
+	if err != nil {
+		// let a retried delivery through
+		d.seen.Delete(key)
+		return err
+	}
+
+	if alertID, ok := event.GetString(ce.AlertIDKey); ok && alertID != "" {
+		d.statusChanged(topic+"/alert/"+alertID, key)
+	}
+
+	return nil
+}
+
+// statusChanged forgets the fingerprint of the previously published status of an alert,
+// so an alert firing again after it was resolved is not dropped as a duplicate
+func (d *Deduplicator) statusChanged(alertKey string, key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if previous, ok := d.seen.Get(alertKey); ok && previous.(string) != key {
+		d.seen.Delete(previous.(string))
+	}
+	d.seen.Set(alertKey, key, d.ttl)
+}
+
+func (d *Deduplicator) event(args []interface{}) (*ce.Event, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+
+	event, ok := args[0].(*ce.Event)
+
+	return event, ok && event != nil
+}
@@ -0,0 +1,147 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+type testPublisher struct {
+	published []*ce.Event
+}
+
+func (p *testPublisher) Publish(topic string, args ...interface{}) error {
+	p.published = append(p.published, args[0].(*ce.Event))
+
+	return nil
+}
+
+func newTestDeduplicator(ttl time.Duration) (*Deduplicator, *testPublisher) {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	publisher := &testPublisher{}
+
+	return New(Config{Enabled: true, TTL: ttl}, logger, publisher), publisher
+}
+
+// alertEvent returns an event of an alert with the given status the way the alert handler creates it
+func alertEvent(status string) *ce.Event {
+	labels := map[string]string{"alertname": "NodeNotReady", "cluster_id": "1", "instance": "node-1"}
+
+	values := map[string]string{ce.AlertStatusKey: status}
+	for k, v := range labels {
+		values[k] = v
+	}
+	fingerprint := ce.Fingerprint(values)
+
+	e := &ce.Event{}
+	e.Set("id", ce.IDFromFingerprint(fingerprint))
+	e.Set("type", "prometheus.server.alert.NodeNotReady")
+	e.Set(ce.AlertStatusKey, status)
+	e.Set(ce.AlertIDKey, ce.Fingerprint(labels))
+	e.Set(ce.FingerprintKey, fingerprint)
+
+	return e
+}
+
+func publish(t *testing.T, d *Deduplicator, events ...*ce.Event) {
+	for _, e := range events {
+		err := d.Publish("test", e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDuplicatesDropped(t *testing.T) {
+	d, publisher := newTestDeduplicator(time.Hour)
+
+	publish(t, d, alertEvent(ce.AlertFiring), alertEvent(ce.AlertFiring), alertEvent(ce.AlertFiring))
+	if len(publisher.published) != 1 {
+		t.Fatalf("expected the duplicates to be dropped, got %d events", len(publisher.published))
+	}
+
+	// the same event on another topic is not a duplicate
+	err := d.Publish("other", alertEvent(ce.AlertFiring))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.published) != 2 {
+		t.Fatalf("expected the event of another topic to be published, got %d events", len(publisher.published))
+	}
+}
+
+func TestDuplicatesExpire(t *testing.T) {
+	d, publisher := newTestDeduplicator(50 * time.Millisecond)
+
+	publish(t, d, alertEvent(ce.AlertFiring))
+	time.Sleep(100 * time.Millisecond)
+	publish(t, d, alertEvent(ce.AlertFiring))
+
+	if len(publisher.published) != 2 {
+		t.Fatalf("expected the event to be published again after the TTL, got %d events", len(publisher.published))
+	}
+}
+
+func TestAlertFiringAgain(t *testing.T) {
+	d, publisher := newTestDeduplicator(time.Hour)
+
+	publish(t, d,
+		alertEvent(ce.AlertFiring),
+		alertEvent(ce.AlertResolved),
+		alertEvent(ce.AlertResolved),
+		alertEvent(ce.AlertFiring),
+		alertEvent(ce.AlertFiring),
+		alertEvent(ce.AlertResolved),
+	)
+
+	var statuses []string
+	for _, e := range publisher.published {
+		status, _ := e.GetString(ce.AlertStatusKey)
+		statuses = append(statuses, status)
+	}
+
+	expected := []string{ce.AlertFiring, ce.AlertResolved, ce.AlertFiring, ce.AlertResolved}
+	if len(statuses) != len(expected) {
+		t.Fatalf("expected statuses %v, got %v", expected, statuses)
+	}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Fatalf("expected statuses %v, got %v", expected, statuses)
+		}
+	}
+}
+
+func TestStableEventID(t *testing.T) {
+	firing := alertEvent(ce.AlertFiring)
+	if alertEvent(ce.AlertFiring).ID != firing.ID {
+		t.Fatal("expected the same alert to get the same event ID")
+	}
+	if alertEvent(ce.AlertResolved).ID == firing.ID {
+		t.Fatal("expected the resolved alert to get another event ID")
+	}
+
+	a := ce.Fingerprint(map[string]string{"a": "1", "b": "2"})
+	b := ce.Fingerprint(map[string]string{"b": "2", "a": "1"})
+	if ce.IDFromFingerprint(a) != ce.IDFromFingerprint(b) {
+		t.Fatal("expected the event ID not to depend on the order of the labels")
+	}
+	if ce.IDFromFingerprint(a) == ce.IDFromFingerprint(ce.Fingerprint(map[string]string{"a": "1"})) {
+		t.Fatal("expected other labels to give another event ID")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+const (
+	// AggregatedCountKey is the attribute of composite events holding the number of aggregated events
+	AggregatedCountKey = "aggregated_count"
+	// AggregatedEventsKey is the attribute of composite events holding the aggregated events
+	AggregatedEventsKey = "aggregated_events"
+)
+
+// AggregateConfig holds configuration values for buffering events until enough of them arrive within a window
+type AggregateConfig struct {
+	// Events needed within the window to execute the flow, aggregation is disabled if 0
+	Count  int           `mapstructure:"count"`
+	Window time.Duration `mapstructure:"window"`
+
+	// Event attribute whose values are aggregated separately, eg. cluster_id, events are aggregated flow wide if empty
+	Key string `mapstructure:"key"`
+}
+
+// Validate validates the aggregate configuration
+func (c AggregateConfig) Validate() error {
+	if c.Count < 0 {
+		return errors.New("aggregate count must not be negative")
+	}
+
+	if c.Count > 0 && c.Window <= 0 {
+		return errors.New("aggregate window must be positive if count is set")
+	}
+
+	return nil
+}
+
+func (c AggregateConfig) enabled() bool {
+	return c.Count > 0
+}
+
+type bufferedEvent struct {
+	event    *ce.Event
+	received time.Time
+}
+
+// aggregator buffers the events of a flow per aggregation key
+type aggregator struct {
+	config AggregateConfig
+
+	mu      sync.Mutex
+	buffers map[string][]bufferedEvent
+}
+
+func newAggregator(config AggregateConfig) *aggregator {
+	return &aggregator{
+		config:  config,
+		buffers: make(map[string][]bufferedEvent),
+	}
+}
+
+// add buffers the event and returns the buffered events of its aggregation key once
+// the count is reached within the window, the returned events are removed from the buffer
+func (a *aggregator) add(event *ce.Event, now time.Time) ([]*ce.Event, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key, _ := event.GetString(a.config.Key)
+
+	a.expire(now)
+
+	a.buffers[key] = append(a.buffers[key], bufferedEvent{event: event, received: now})
+	buffered := a.buffers[key]
+	if len(buffered) < a.config.Count {
+		return nil, len(buffered)
+	}
+	delete(a.buffers, key)
+
+	events := make([]*ce.Event, 0, len(buffered))
+	for _, b := range buffered {
+		events = append(events, b.event)
+	}
+
+	return events, len(events)
+}
+
+// reconfigure applies the configuration of the reloaded flow, the buffered
+// events are kept unless they are aggregated by another key
+func (a *aggregator) reconfigure(config AggregateConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if config.Key != a.config.Key {
+		a.buffers = make(map[string][]bufferedEvent)
+	}
+	a.config = config
+}
+
+// expire forgets the buffered events received before the window
+func (a *aggregator) expire(now time.Time) {
+	cut := now.Add(-a.config.Window)
+	for key, buffered := range a.buffers {
+		i := 0
+		for i < len(buffered) && !buffered[i].received.After(cut) {
+			i++
+		}
+		if i == len(buffered) {
+			delete(a.buffers, key)
+			continue
+		}
+		a.buffers[key] = buffered[i:]
+	}
+}
+
+// compositeEvent returns a copy of the last aggregated event carrying every aggregated event
+// in an attribute, the attributes of the last event stay available to the filters and templates
+func compositeEvent(events []*ce.Event) (*ce.Event, error) {
+	last := events[len(events)-1]
+	c, err := last.Clone()
+	if err != nil {
+		return nil, err
+	}
+	c.ID = uuid.NewV4().String()
+
+	aggregated := make([]map[string]interface{}, 0, len(events))
+	for _, e := range events {
+		attributes, err := e.Attributes()
+		if err != nil {
+			return nil, err
+		}
+
+		aggregated = append(aggregated, map[string]interface{}{
+			"id":         e.ID,
+			"type":       e.Type,
+			"time":       e.Time,
+			"attributes": attributes,
+		})
+	}
+	c.Set(AggregatedCountKey, len(events))
+	c.Set(AggregatedEventsKey, aggregated)
+
+	return c, nil
+}
@@ -0,0 +1,154 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const aggregateConfig = `
+flows:
+  scale:
+    name: scale
+    plugins: [scale]
+    aggregate:
+      count: 3
+      window: 2m
+      key: cluster_id
+`
+
+func TestAggregateGroupKey(t *testing.T) {
+	scale := &testPlugin{name: "scale"}
+	env := newTestEnv(t, aggregateConfig, scale)
+
+	first := env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-1"})
+	env.send("scale", "alert", map[string]string{"cluster_id": "2", "instance": "i-2"})
+	second := env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-3"})
+	if scale.calls() != 0 {
+		t.Fatal("expected the events to be buffered per cluster")
+	}
+
+	last := env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-4"})
+	if scale.calls() != 1 {
+		t.Fatalf("expected the flow to be executed once the count is reached, got %d calls", scale.calls())
+	}
+
+	composite := scale.events[0]
+	if composite.ID == last.ID {
+		t.Fatal("expected a composite event with its own ID")
+	}
+	if instance, _ := composite.GetString("instance"); instance != "i-4" {
+		t.Fatalf("expected the attributes of the last event, got instance %q", instance)
+	}
+	if count, _ := composite.Get(AggregatedCountKey); count != 3 {
+		t.Fatalf("expected 3 aggregated events, got %v", count)
+	}
+	v, _ := composite.Get(AggregatedEventsKey)
+	aggregated, ok := v.([]map[string]interface{})
+	if !ok || len(aggregated) != 3 {
+		t.Fatalf("expected the aggregated events, got %v", v)
+	}
+	for i, e := range []string{first.ID, second.ID, last.ID} {
+		if aggregated[i]["id"] != e {
+			t.Fatalf("expected event %s at %d, got %v", e, i, aggregated[i]["id"])
+		}
+	}
+	if attributes := aggregated[0]["attributes"].(map[string]interface{}); attributes["instance"] != "i-1" {
+		t.Fatalf("expected the attributes of the aggregated events, got %v", attributes)
+	}
+}
+
+func TestAggregateBatchSize(t *testing.T) {
+	scale := &testPlugin{name: "scale"}
+	env := newTestEnv(t, aggregateConfig, scale)
+
+	// the buffer is emptied once its events are aggregated, batches hold count events
+	for i := 0; i < 8; i++ {
+		env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-" + string(rune('a'+i))})
+	}
+	if scale.calls() != 2 {
+		t.Fatalf("expected 2 batches, got %d plugin calls", scale.calls())
+	}
+	for _, e := range scale.events {
+		if count, _ := e.Get(AggregatedCountKey); count != 3 {
+			t.Fatalf("expected batches of 3 events, got %v", count)
+		}
+	}
+}
+
+func TestAggregateWindow(t *testing.T) {
+	scale := &testPlugin{name: "scale"}
+	env := newTestEnv(t, aggregateConfig, scale)
+
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-1"})
+	env.clock.Advance(time.Minute)
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-2"})
+
+	// the first event is dropped from the buffer at the end of its window
+	env.clock.Advance(time.Minute)
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-3"})
+	if scale.calls() != 0 {
+		t.Fatal("expected the events outside the window not to be aggregated")
+	}
+
+	env.clock.Advance(30 * time.Second)
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-4"})
+	if scale.calls() != 1 {
+		t.Fatalf("expected the events within the window to be aggregated, got %d plugin calls", scale.calls())
+	}
+	v, _ := scale.events[0].Get(AggregatedEventsKey)
+	if aggregated := v.([]map[string]interface{}); aggregated[0]["attributes"].(map[string]interface{})["instance"] != "i-2" {
+		t.Fatalf("expected the aggregation to start with the second event, got %v", aggregated[0])
+	}
+}
+
+func TestAggregateReload(t *testing.T) {
+	scale := &testPlugin{name: "scale"}
+	env := newTestEnv(t, aggregateConfig, scale)
+
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-1"})
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-2"})
+
+	// the buffers are carried across a reload with the same key, the new count applies
+	err := env.manager.LoadFlows(readConfig(t, strings.Replace(aggregateConfig, "count: 3", "count: 2", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-3"})
+	if scale.calls() != 1 {
+		t.Fatalf("expected the buffered events to be aggregated after the reload, got %d plugin calls", scale.calls())
+	}
+	if count, _ := scale.events[0].Get(AggregatedCountKey); count != 3 {
+		t.Fatalf("expected the events buffered before the reload, got %v aggregated events", count)
+	}
+
+	// events buffered by another key are dropped
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-4"})
+	err = env.manager.LoadFlows(readConfig(t, strings.Replace(aggregateConfig, "key: cluster_id", "key: instance", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-4"})
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-4"})
+	if scale.calls() != 1 {
+		t.Fatal("expected the buffers to be dropped when the key changes")
+	}
+	env.send("scale", "alert", map[string]string{"cluster_id": "1", "instance": "i-4"})
+	if scale.calls() != 2 {
+		t.Fatalf("expected the events of the new key to be aggregated, got %d plugin calls", scale.calls())
+	}
+}
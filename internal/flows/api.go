@@ -0,0 +1,62 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API serves the running event flow endpoints
+type API struct {
+	executions *Executions
+}
+
+// NewAPI returns an initialized API
+func NewAPI(executions *Executions) *API {
+	return &API{
+		executions: executions,
+	}
+}
+
+// RegisterRoutes registers the running event flow endpoints
+func (a *API) RegisterRoutes(r gin.IRouter) {
+	r.GET("/flows/executions", a.listExecutions)
+	r.GET("/flows/executions/:id", a.getExecution)
+}
+
+func (a *API) listExecutions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   a.executions.List(),
+	})
+}
+
+func (a *API) getExecution(c *gin.Context) {
+	s, ok := a.executions.Get(c.Param("id"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "execution not found or already finished",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   s,
+	})
+}
@@ -0,0 +1,301 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+)
+
+const (
+	// BudgetExceededEventType is the type of the events published about disruptive
+	// event flows blocked by the disruption budget
+	BudgetExceededEventType = "hollowtrees.budget.exceeded"
+
+	DefaultBudgetKey     = "cluster_id"
+	DefaultBudgetNodeKey = "instance"
+)
+
+// ErrBudgetExceeded is returned for disruptive event flows blocked by the disruption budget
+var ErrBudgetExceeded = errors.New("disruption budget exceeded") // nolint: gochecknoglobals
+
+// DisruptionBudgetConfig holds configuration values limiting the disruptive event flows of every flow per cluster
+type DisruptionBudgetConfig struct {
+	Enabled bool
+
+	// Event attribute identifying the cluster of an event
+	Key string
+
+	// Disruptive event flows started within the window in a cluster, unlimited if 0
+	MaxDisruptions int
+	Window         time.Duration
+
+	// Disruptive event flows started within the window as a percentage of the known nodes of the cluster,
+	// rounded up and at least one, unlimited if 0
+	MaxPercent int
+
+	// Event attribute identifying the node of an event, its distinct values are the known nodes of a cluster
+	NodeKey string
+
+	// Nodes not seen in events for this long are forgotten
+	NodeRetention time.Duration
+
+	// Node counts of clusters overriding the nodes known from events, the nodes
+	// of the clusters not listed are still learned from events
+	ClusterNodes map[string]int
+}
+
+// Validate validates the disruption budget configuration
+func (c DisruptionBudgetConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxDisruptions < 0 {
+		return errors.New("max disruptions must not be negative")
+	}
+
+	if c.MaxPercent < 0 || c.MaxPercent > 100 {
+		return errors.New("max percent must be between 0 and 100")
+	}
+
+	if c.MaxDisruptions == 0 && c.MaxPercent == 0 {
+		return errors.New("max disruptions or max percent must be set")
+	}
+
+	if c.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+
+	// the nodes of clusters without a configured node count are learned from events
+	if c.MaxPercent > 0 && c.NodeRetention <= 0 {
+		return errors.New("node retention must be positive")
+	}
+
+	return nil
+}
+
+// DisruptionBudget limits the disruptive event flows of every flow per cluster, it learns
+// the nodes of the clusters from the incoming events
+type DisruptionBudget struct {
+	config DisruptionBudgetConfig
+	clock  clock.Clock
+
+	mu          sync.Mutex
+	disruptions map[string][]time.Time
+	nodes       map[string]map[string]time.Time
+	blocked     map[string]uint64
+}
+
+// NewDisruptionBudget returns an initialized DisruptionBudget
+func NewDisruptionBudget(config DisruptionBudgetConfig, clock clock.Clock) *DisruptionBudget {
+	if config.Key == "" {
+		config.Key = DefaultBudgetKey
+	}
+	if config.NodeKey == "" {
+		config.NodeKey = DefaultBudgetNodeKey
+	}
+
+	// keys of configuration maps are lower cased
+	clusterNodes := make(map[string]int, len(config.ClusterNodes))
+	for k, v := range config.ClusterNodes {
+		clusterNodes[strings.ToLower(k)] = v
+	}
+	config.ClusterNodes = clusterNodes
+
+	return &DisruptionBudget{
+		config:      config,
+		clock:       clock,
+		disruptions: make(map[string][]time.Time),
+		nodes:       make(map[string]map[string]time.Time),
+		blocked:     make(map[string]uint64),
+	}
+}
+
+// Handle records the node of the event as a known node of its cluster
+func (b *DisruptionBudget) Handle(event interface{}) {
+	e, ok := event.(*ce.Event)
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.learn(e, b.clock.Now())
+}
+
+func (b *DisruptionBudget) learn(event *ce.Event, now time.Time) {
+	node, ok := event.GetString(b.config.NodeKey)
+	if !ok || node == "" {
+		return
+	}
+	cluster, _ := event.GetString(b.config.Key)
+
+	if b.nodes[cluster] == nil {
+		b.nodes[cluster] = make(map[string]time.Time)
+	}
+	b.nodes[cluster][node] = now
+}
+
+// budgetStatus describes the usage of the disruption budget of a cluster
+type budgetStatus struct {
+	Cluster     string
+	Disruptions int
+	// Disruptions allowed within the window, -1 if unlimited
+	Limit int
+}
+
+// acquire records a disruption in the cluster of the event if the budget allows it
+func (b *DisruptionBudget) acquire(event *ce.Event, flowID string) (budgetStatus, bool) {
+	cluster, _ := event.GetString(b.config.Key)
+	now := b.clock.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the node of the event counts even if the budget has not handled the event yet
+	b.learn(event, now)
+
+	s := b.status(cluster, now)
+	if s.Limit >= 0 && s.Disruptions >= s.Limit {
+		b.blocked[flowID]++
+		return s, false
+	}
+
+	b.disruptions[cluster] = append(b.disruptions[cluster], now)
+	s.Disruptions++
+
+	return s, true
+}
+
+// status returns the budget usage of the cluster, it forgets the expired disruptions and nodes
+func (b *DisruptionBudget) status(cluster string, now time.Time) budgetStatus {
+	cut := now.Add(-b.config.Window)
+	d := b.disruptions[cluster]
+	i := 0
+	for i < len(d) && !d[i].After(cut) {
+		i++
+	}
+	b.disruptions[cluster] = d[i:]
+	if len(b.disruptions[cluster]) == 0 {
+		delete(b.disruptions, cluster)
+	}
+
+	s := budgetStatus{
+		Cluster:     cluster,
+		Disruptions: len(d) - i,
+		Limit:       -1,
+	}
+	if b.config.MaxDisruptions > 0 {
+		s.Limit = b.config.MaxDisruptions
+	}
+	if b.config.MaxPercent > 0 {
+		// small clusters are not blocked entirely, at least one disruption is allowed
+		limit := int(math.Ceil(float64(b.knownNodes(cluster, now)*b.config.MaxPercent) / 100))
+		if limit < 1 {
+			limit = 1
+		}
+		if s.Limit < 0 || limit < s.Limit {
+			s.Limit = limit
+		}
+	}
+
+	return s
+}
+
+func (b *DisruptionBudget) knownNodes(cluster string, now time.Time) int {
+	if n, ok := b.config.ClusterNodes[strings.ToLower(cluster)]; ok {
+		return n
+	}
+
+	cut := now.Add(-b.config.NodeRetention)
+	for node, seen := range b.nodes[cluster] {
+		if !seen.After(cut) {
+			delete(b.nodes[cluster], node)
+		}
+	}
+
+	return len(b.nodes[cluster])
+}
+
+// statuses returns the budget usage of every cluster with disruptions or known nodes
+func (b *DisruptionBudget) statuses() []budgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	clusters := make(map[string]bool)
+	for c := range b.disruptions {
+		clusters[c] = true
+	}
+	for c := range b.nodes {
+		clusters[c] = true
+	}
+
+	statuses := make([]budgetStatus, 0, len(clusters))
+	for c := range clusters {
+		statuses = append(statuses, b.status(c, now))
+	}
+
+	return statuses
+}
+
+func (b *DisruptionBudget) blockedCount(flowID string) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.blocked[flowID]
+}
+
+// checkBudget checks the disruption budget for disruptive flows, blocked
+// event flows are reported by a budget exceeded event
+func (ef *EventFlow) checkBudget() error {
+	budget := ef.flow.manager.Budget()
+	if !ef.flow.disruptive || budget == nil {
+		return nil
+	}
+
+	s, ok := budget.acquire(ef.event, ef.flow.id)
+	if ok {
+		return nil
+	}
+
+	source := url.URL{Path: "/hollowtrees/budget"}
+	e := ef.event.Derive(BudgetExceededEventType, source, ef.flow.manager.Clock().Now())
+	e.Set(budget.config.Key, s.Cluster)
+	e.Set("flow_id", ef.flow.id)
+	e.Set("flow_name", ef.flow.name)
+	e.Set("origin_event_id", ef.event.ID)
+	e.Set("origin_event_type", ef.event.Type)
+	e.Set("disruptions", s.Disruptions)
+	e.Set("limit", s.Limit)
+
+	err := ef.flow.manager.Publisher().Publish(CEIncomingTopic, e)
+	if err != nil {
+		ef.flow.manager.ErrorHandler().Handle(emperror.WrapWith(err, "could not publish budget exceeded event", "flow", ef.flow.id))
+	}
+
+	return emperror.With(ErrBudgetExceeded, "flow", ef.flow.id, budget.config.Key, s.Cluster, "disruptions", s.Disruptions, "limit", s.Limit)
+}
@@ -0,0 +1,205 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+// nodeEvent returns an event of the node in the cluster
+func nodeEvent(cluster string, node string) *ce.Event {
+	event := &ce.Event{}
+	event.Set("id", cluster+"/"+node)
+	event.Set("type", "alert")
+	event.Set("cluster_id", cluster)
+	event.Set("instance", node)
+
+	return event
+}
+
+// acquired counts the disruptions the budget allows for the events of the nodes
+func acquired(b *DisruptionBudget, cluster string, nodes ...string) int {
+	count := 0
+	for _, node := range nodes {
+		if _, ok := b.acquire(nodeEvent(cluster, node), "drain"); ok {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestBudgetMaxDisruptions(t *testing.T) {
+	c := newFakeClock()
+	b := NewDisruptionBudget(DisruptionBudgetConfig{Enabled: true, MaxDisruptions: 2, Window: time.Hour}, c)
+
+	if n := acquired(b, "1", "n-1", "n-2", "n-3"); n != 2 {
+		t.Fatalf("expected 2 disruptions to be allowed, got %d", n)
+	}
+	if n := acquired(b, "2", "n-1"); n != 1 {
+		t.Fatal("expected the budget to apply to every cluster separately")
+	}
+	if b.blockedCount("drain") != 1 {
+		t.Fatalf("expected 1 blocked event flow, got %d", b.blockedCount("drain"))
+	}
+
+	// disruptions expire at the end of the window
+	c.Advance(30 * time.Minute)
+	if n := acquired(b, "1", "n-4"); n != 0 {
+		t.Fatal("expected the disruptions to count within the window")
+	}
+	c.Advance(30 * time.Minute)
+	if n := acquired(b, "1", "n-4", "n-5", "n-6"); n != 2 {
+		t.Fatalf("expected 2 disruptions to be allowed in the next window, got %d", n)
+	}
+}
+
+func TestBudgetMaxPercent(t *testing.T) {
+	tests := []struct {
+		name   string
+		nodes  int
+		config DisruptionBudgetConfig
+		limit  int
+	}{
+		{"rounded up", 10, DisruptionBudgetConfig{MaxPercent: 25}, 3},
+		{"exact", 10, DisruptionBudgetConfig{MaxPercent: 20}, 2},
+		{"small cluster", 2, DisruptionBudgetConfig{MaxPercent: 20}, 1},
+		{"single node", 1, DisruptionBudgetConfig{MaxPercent: 10}, 1},
+		{"both limits", 10, DisruptionBudgetConfig{MaxPercent: 50, MaxDisruptions: 3}, 3},
+		{"both limits percent", 10, DisruptionBudgetConfig{MaxPercent: 10, MaxDisruptions: 3}, 1},
+		{"configured nodes", 1, DisruptionBudgetConfig{MaxPercent: 50, ClusterNodes: map[string]int{"Cluster-1": 8}}, 4},
+	}
+	for _, test := range tests {
+		config := test.config
+		config.Enabled = true
+		config.Window = time.Hour
+		config.NodeRetention = time.Hour
+		c := newFakeClock()
+		b := NewDisruptionBudget(config, c)
+
+		// the budget learns the nodes of the cluster from the events it handles
+		for i := 0; i < test.nodes; i++ {
+			b.Handle(nodeEvent("cluster-1", "node-"+string(rune('a'+i))))
+		}
+
+		nodes := make([]string, 0, 10)
+		for i := 0; i < 10; i++ {
+			nodes = append(nodes, "node-"+string(rune('a'+i%test.nodes)))
+		}
+		if n := acquired(b, "cluster-1", nodes...); n != test.limit {
+			t.Errorf("%s: expected %d disruptions to be allowed, got %d", test.name, test.limit, n)
+		}
+	}
+}
+
+func TestBudgetNodeRetention(t *testing.T) {
+	c := newFakeClock()
+	b := NewDisruptionBudget(DisruptionBudgetConfig{Enabled: true, MaxPercent: 50, Window: time.Minute, NodeRetention: time.Hour}, c)
+
+	for _, node := range []string{"n-1", "n-2", "n-3", "n-4", "n-5", "n-6"} {
+		b.Handle(nodeEvent("1", node))
+	}
+	c.Advance(30 * time.Minute)
+	b.Handle(nodeEvent("1", "n-1"))
+	b.Handle(nodeEvent("1", "n-2"))
+
+	statuses := b.statuses()
+	if len(statuses) != 1 || statuses[0].Limit != 3 {
+		t.Fatalf("expected a limit of 3 with 6 known nodes, got %+v", statuses)
+	}
+
+	// the nodes not seen within the retention are forgotten
+	c.Advance(30 * time.Minute)
+	statuses = b.statuses()
+	if len(statuses) != 1 || statuses[0].Limit != 1 {
+		t.Fatalf("expected a limit of 1 with 2 known nodes, got %+v", statuses)
+	}
+}
+
+func TestBudgetConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DisruptionBudgetConfig
+		valid  bool
+	}{
+		{"disabled", DisruptionBudgetConfig{}, true},
+		{"max disruptions", DisruptionBudgetConfig{Enabled: true, MaxDisruptions: 1, Window: time.Hour}, true},
+		{"max percent", DisruptionBudgetConfig{Enabled: true, MaxPercent: 20, Window: time.Hour, NodeRetention: time.Hour}, true},
+		{"no limit", DisruptionBudgetConfig{Enabled: true, Window: time.Hour}, false},
+		{"no window", DisruptionBudgetConfig{Enabled: true, MaxDisruptions: 1}, false},
+		{"invalid percent", DisruptionBudgetConfig{Enabled: true, MaxPercent: 120, Window: time.Hour, NodeRetention: time.Hour}, false},
+		{"no retention", DisruptionBudgetConfig{Enabled: true, MaxPercent: 20, Window: time.Hour, ClusterNodes: map[string]int{"1": 10}}, false},
+	}
+	for _, test := range tests {
+		err := test.config.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+const budgetConfig = `
+flowEngine:
+  disruptionBudget:
+    enabled: true
+    maxDisruptions: 1
+    window: 1h
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+    groupBy: [instance]
+    disruptive: true
+  notify:
+    name: notify
+    plugins: [notify]
+    groupBy: [instance]
+`
+
+func TestBudgetBlocksDisruptiveFlows(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	notify := &testPlugin{name: "notify"}
+	env := newTestEnv(t, budgetConfig, drain, notify)
+
+	env.send("drain", "alert", map[string]string{"cluster_id": "1", "instance": "i-1"})
+	env.send("drain", "alert", map[string]string{"cluster_id": "1", "instance": "i-2"})
+	env.send("notify", "alert", map[string]string{"cluster_id": "1", "instance": "i-2"})
+
+	if drain.calls() != 1 || notify.calls() != 1 {
+		t.Fatalf("expected the budget to block disruptive flows only, got %d drain and %d notify calls", drain.calls(), notify.calls())
+	}
+	if env.errors.count() != 1 || errors.Cause(env.errors.errors[0]) != ErrBudgetExceeded {
+		t.Fatalf("expected the blocked event flow to fail, got %v", env.errors.errors)
+	}
+
+	events := env.dispatcher.events()
+	if len(events) != 1 || events[0].Type != BudgetExceededEventType {
+		t.Fatalf("expected a budget exceeded event, got %d events", len(events))
+	}
+	if flowID, _ := events[0].GetString("flow_id"); flowID != "drain" {
+		t.Fatalf("expected the blocked flow in the event, got %q", flowID)
+	}
+	if limit, _ := events[0].Get("limit"); limit != 1 {
+		t.Fatalf("expected the limit in the event, got %v", limit)
+	}
+}
@@ -15,6 +15,8 @@
 package flows
 
 import (
+	"fmt"
+	"text/template"
 	"time"
 
 	"github.com/goph/emperror"
@@ -25,32 +27,344 @@ import (
 
 // FlowConfig holds configuration values for an action flow
 type FlowConfig struct {
-	Name    string   `mapstructure:"name"`
-	Plugins []string `mapstructure:"plugins"`
+	Name    string       `mapstructure:"name"`
+	Plugins []string     `mapstructure:"plugins"`
+	Steps   []StepConfig `mapstructure:"steps"`
 
 	Description   string            `mapstructure:"description"`
 	AllowedEvents []string          `mapstructure:"allowedEvents"`
 	GroupBy       []string          `mapstructure:"groupBy"`
 	Filters       map[string]string `mapstructure:"filters"`
 	Cooldown      time.Duration     `mapstructure:"cooldown"`
+
+	// What happens when the circuit breaker of a plugin is open: fail (default) or defer
+	OnPluginUnavailable string        `mapstructure:"onPluginUnavailable"`
+	DeferTimeout        time.Duration `mapstructure:"deferTimeout"`
+
+	// Limits of the event flows across group keys
+	RateLimit RateLimitConfig `mapstructure:"rateLimit"`
+
+	// Event flows are pending for the delay and cancelled by resolved alerts or cancel events arriving meanwhile
+	Delay        time.Duration `mapstructure:"delay"`
+	CancelEvents []string      `mapstructure:"cancelEvents"`
+
+	// Event flows are executed only within the active windows, events arriving outside are deferred (default) or dropped
+	ActiveWindows []ActiveWindowConfig `mapstructure:"activeWindows"`
+	WindowPolicy  string               `mapstructure:"windowPolicy"`
+
+	// Events buffered until enough of them arrive within a window
+	Aggregate AggregateConfig `mapstructure:"aggregate"`
+
+	// Disruptive flows (eg. draining nodes) are limited by the disruption budget
+	Disruptive bool `mapstructure:"disruptive"`
+
+	// Tenants whose events the flow handles, the flow handles the events of any tenant if empty
+	Tenants []string `mapstructure:"tenants"`
 }
 
 type FlowConfigs map[string]FlowConfig
 
+// EngineConfig holds configuration values common to every action flow
+type EngineConfig struct {
+	// Publish hollowtrees.flow.<id>.started/completed/failed events for every event flow
+	LifecycleEvents bool
+
+	// Events derived from other events through more hops than this are dropped
+	MaxHops int
+
+	// Limits of the disruptive event flows per cluster
+	DisruptionBudget DisruptionBudgetConfig
+
+	// Tenants the flows and plugins are scoped to
+	Tenancy TenancyConfig
+
+	// Persistence of the cooldowns of event flows
+	Cooldowns CooldownConfig
+}
+
+// Validate validates the flow engine configuration
+func (c EngineConfig) Validate() error {
+	if c.MaxHops < 1 {
+		return errors.New("max hops must be at least 1")
+	}
+
+	err := c.DisruptionBudget.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "invalid disruption budget")
+	}
+
+	err = c.Tenancy.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "invalid tenancy")
+	}
+
+	err = c.Cooldowns.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "invalid cooldowns")
+	}
+
+	return nil
+}
+
+// StepConfig holds configuration values for a plugin call of an action flow or a group of
+// steps running in parallel, parameter values can be Go templates over the event attributes
+// and the results of the previous steps
+type StepConfig struct {
+	// Name the results of the step are referenced by in templates, defaults to the plugin name
+	Name   string                 `mapstructure:"name"`
+	Plugin string                 `mapstructure:"plugin"`
+	Params map[string]interface{} `mapstructure:"params"`
+
+	// Limits the plugin call including the long-running operation it starts
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Go template condition, the step is skipped unless it renders true
+	When string `mapstructure:"when"`
+
+	// Steps running in parallel instead of a plugin call
+	Parallel []StepConfig `mapstructure:"parallel"`
+
+	// Steps running if the step fails, the failure is handled if they all succeed
+	OnFailure []StepConfig `mapstructure:"onFailure"`
+}
+
 // Validate validates flow configuration
 func (c FlowConfig) Validate(plugins plugin.PluginManager, id string) error {
+	err := c.ValidateDefinition(id)
+	if err != nil {
+		return err
+	}
+
+	steps, err := c.GetSteps()
+	if err != nil {
+		return emperror.WrapWith(err, "invalid flow config", "flow", id)
+	}
+
+	_, err = plugins.GetByNames(pluginNames(steps)...)
+	if err != nil {
+		return emperror.WrapWith(err, "invalid flow", "flow", id)
+	}
+
+	err = c.validateCapabilities(plugins)
+	if err != nil {
+		return emperror.WrapWith(err, "invalid flow", "flow", id)
+	}
+
+	err = c.validateTenants(plugins)
+	if err != nil {
+		return emperror.WrapWith(err, "invalid flow", "flow", id)
+	}
+
+	return nil
+}
+
+// ValidateDefinition validates the flow configuration on its own, without checking its plugins
+func (c FlowConfig) ValidateDefinition(id string) error {
 	if c.Name == "" {
 		return errors.New("name must be set")
 	}
 
-	if len(c.Plugins) == 0 {
+	if len(c.Plugins) == 0 && len(c.Steps) == 0 {
 		return emperror.WrapWith(errors.New("no plugins defined"), "invalid flow config", "flow", id)
 	}
 
-	_, err := plugins.GetByNames(c.Plugins...)
+	if len(c.Plugins) > 0 && len(c.Steps) > 0 {
+		return emperror.WrapWith(errors.New("plugins and steps are mutually exclusive"), "invalid flow config", "flow", id)
+	}
+
+	switch c.OnPluginUnavailable {
+	case "", UnavailableFail:
+	case UnavailableDefer:
+		if c.DeferTimeout <= 0 {
+			return emperror.WrapWith(errors.New("deferTimeout must be positive with the defer policy"), "invalid flow config", "flow", id)
+		}
+	default:
+		return emperror.WrapWith(errors.New("invalid onPluginUnavailable policy"), "invalid flow config", "flow", id, "policy", c.OnPluginUnavailable)
+	}
+
+	err := c.RateLimit.Validate()
 	if err != nil {
-		return emperror.WrapWith(err, "invalid flow", "flow", id)
+		return emperror.WrapWith(err, "invalid flow config", "flow", id)
+	}
+
+	if c.Delay < 0 {
+		return emperror.WrapWith(errors.New("delay must not be negative"), "invalid flow config", "flow", id)
+	}
+
+	if len(c.CancelEvents) > 0 && c.Delay == 0 {
+		return emperror.WrapWith(errors.New("cancelEvents require a delay"), "invalid flow config", "flow", id)
+	}
+
+	for i, w := range c.ActiveWindows {
+		err = w.Validate()
+		if err != nil {
+			return emperror.WrapWith(err, "invalid flow config", "flow", id, "active-window", i)
+		}
+	}
+
+	switch c.WindowPolicy {
+	case "", WindowDefer, WindowDrop:
+	default:
+		return emperror.WrapWith(errors.New("invalid window policy"), "invalid flow config", "flow", id, "policy", c.WindowPolicy)
+	}
+
+	err = c.Aggregate.Validate()
+	if err != nil {
+		return emperror.WrapWith(err, "invalid flow config", "flow", id)
+	}
+
+	_, err = c.GetSteps()
+	if err != nil {
+		return emperror.WrapWith(err, "invalid flow config", "flow", id)
+	}
+
+	return nil
+}
+
+// validateCapabilities checks the allowed events and the step parameters against
+// the capabilities of the plugins which described them
+func (c FlowConfig) validateCapabilities(plugins plugin.PluginManager) error {
+	for _, step := range c.pluginSteps() {
+		d, ok := plugins.Description(step.Plugin)
+		if !ok {
+			continue
+		}
+
+		if len(d.EventTypes) > 0 && len(c.AllowedEvents) == 0 {
+			return emperror.With(errors.New("plugin supports specific event types only, allowedEvents must be set"), "plugin", step.Plugin)
+		}
+		for _, t := range c.AllowedEvents {
+			if !d.SupportsEventType(t) {
+				return emperror.With(errors.New("plugin does not support event type"), "plugin", step.Plugin, "type", t)
+			}
+		}
+
+		err := d.ValidateParams(step.Params)
+		if err != nil {
+			return emperror.With(err, "plugin", step.Plugin)
+		}
 	}
 
 	return nil
 }
+
+// PluginNames returns the names of every plugin the flow calls
+func (c FlowConfig) PluginNames() []string {
+	steps := c.pluginSteps()
+	names := make([]string, 0, len(steps))
+	for _, s := range steps {
+		names = append(names, s.Plugin)
+	}
+
+	return names
+}
+
+// pluginSteps returns the plugin calls of the flow including the ones in parallel
+// groups and onFailure branches, a plain plugin list is converted to steps
+func (c FlowConfig) pluginSteps() []StepConfig {
+	if len(c.Steps) == 0 {
+		steps := make([]StepConfig, 0, len(c.Plugins))
+		for _, name := range c.Plugins {
+			steps = append(steps, StepConfig{Plugin: name})
+		}
+		return steps
+	}
+
+	return flattenSteps(c.Steps)
+}
+
+func flattenSteps(configs []StepConfig) []StepConfig {
+	var steps []StepConfig
+	for _, sc := range configs {
+		if sc.Plugin != "" {
+			steps = append(steps, sc)
+		}
+		steps = append(steps, flattenSteps(sc.Parallel)...)
+		steps = append(steps, flattenSteps(sc.OnFailure)...)
+	}
+
+	return steps
+}
+
+// GetSteps returns the compiled steps of the flow, a plain plugin list
+// is converted to steps without parameters
+func (c FlowConfig) GetSteps() ([]Step, error) {
+	if len(c.Steps) == 0 {
+		steps := make([]Step, 0, len(c.Plugins))
+		for _, name := range c.Plugins {
+			steps = append(steps, Step{plugin: name})
+		}
+		return steps, nil
+	}
+
+	return newSteps(c.Steps, "", make(map[string]bool))
+}
+
+// newSteps compiles the step configs, prefix identifies the nested steps in errors
+func newSteps(configs []StepConfig, prefix string, names map[string]bool) ([]Step, error) {
+	steps := make([]Step, 0, len(configs))
+	for i, sc := range configs {
+		s, err := newStepFromConfig(sc, fmt.Sprintf("%s%d", prefix, i), names)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+
+	return steps, nil
+}
+
+func newStepFromConfig(sc StepConfig, id string, names map[string]bool) (Step, error) {
+	switch {
+	case sc.Plugin == "" && len(sc.Parallel) == 0:
+		return Step{}, emperror.With(errors.New("plugin or parallel must be set"), "step", id)
+	case sc.Plugin != "" && len(sc.Parallel) > 0:
+		return Step{}, emperror.With(errors.New("plugin and parallel are mutually exclusive"), "step", id)
+	case sc.Plugin == "" && (len(sc.Params) > 0 || sc.Timeout != 0):
+		return Step{}, emperror.With(errors.New("params and timeout can only be set for plugin steps"), "step", id)
+	case sc.Timeout < 0:
+		return Step{}, emperror.With(errors.New("timeout must not be negative"), "step", id)
+	}
+
+	// the results of steps are referenced by name, steps calling the same plugin must be named
+	name := sc.Name
+	if name == "" {
+		name = sc.Plugin
+	}
+	if name != "" {
+		if names[name] {
+			return Step{}, emperror.With(errors.New("duplicate step name, steps calling the same plugin must have a name"), "step", id, "name", name)
+		}
+		names[name] = true
+	}
+
+	var s Step
+	var err error
+	if sc.Plugin != "" {
+		s, err = NewStep(sc.Plugin, sc.Params)
+		if err != nil {
+			return s, emperror.With(err, "step", id)
+		}
+		s.timeout = sc.Timeout
+	} else {
+		s.parallel, err = newSteps(sc.Parallel, id+".parallel.", names)
+		if err != nil {
+			return s, err
+		}
+	}
+	s.name = sc.Name
+
+	if sc.When != "" {
+		s.when, err = template.New("when").Option("missingkey=error").Parse(sc.When)
+		if err != nil {
+			return s, emperror.WrapWith(err, "could not parse condition", "step", id)
+		}
+	}
+
+	s.onFailure, err = newSteps(sc.OnFailure, id+".onFailure.", names)
+	if err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,102 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"testing"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin"
+)
+
+// describedPlugins is a plugin manager whose plugins described their capabilities
+type describedPlugins struct {
+	*plugin.Manager
+	descriptions map[string]*plugin.Description
+}
+
+func (m describedPlugins) Description(name string) (*plugin.Description, bool) {
+	d, ok := m.descriptions[name]
+	return d, ok
+}
+
+func TestFlowConfigCapabilities(t *testing.T) {
+	pm := plugin.NewManager(log.NewLogger(log.Config{Format: "logfmt", Level: "error"}), emperror.NewNopHandler())
+	pm.Add(&testPlugin{name: "drain"}, &testPlugin{name: "notify"})
+	plugins := describedPlugins{
+		Manager: pm,
+		descriptions: map[string]*plugin.Description{
+			"drain": {
+				EventTypes: []string{"prometheus.server.alert.*"},
+				Params: []plugin.ParamSpec{
+					{Name: "node", Type: grpcplugin.ParamTypeString, Required: true},
+					{Name: "grace", Type: grpcplugin.ParamTypeDuration},
+				},
+			},
+		},
+	}
+
+	drain := StepConfig{Plugin: "drain", Params: map[string]interface{}{"node": "{{ .instance }}", "grace": "30s"}}
+	tests := []struct {
+		name   string
+		config FlowConfig
+		valid  bool
+	}{
+		{
+			name:   "valid",
+			config: FlowConfig{Name: "drain", AllowedEvents: []string{"prometheus.server.alert.NodeNotReady"}, Steps: []StepConfig{drain}},
+			valid:  true,
+		},
+		{
+			name:   "undescribed plugin",
+			config: FlowConfig{Name: "notify", Plugins: []string{"notify"}},
+			valid:  true,
+		},
+		{
+			name:   "any event",
+			config: FlowConfig{Name: "drain", Steps: []StepConfig{drain}},
+		},
+		{
+			name:   "unsupported event",
+			config: FlowConfig{Name: "drain", AllowedEvents: []string{"hollowtrees.flow.scale.completed"}, Steps: []StepConfig{drain}},
+		},
+		{
+			name: "missing required parameter",
+			config: FlowConfig{Name: "drain", AllowedEvents: []string{"prometheus.server.alert.NodeNotReady"}, Steps: []StepConfig{
+				{Plugin: "drain", Params: map[string]interface{}{"grace": "30s"}},
+			}},
+		},
+		{
+			name: "invalid nested parameter",
+			config: FlowConfig{Name: "drain", AllowedEvents: []string{"prometheus.server.alert.NodeNotReady"}, Steps: []StepConfig{
+				{Plugin: "notify", OnFailure: []StepConfig{
+					{Plugin: "drain", Params: map[string]interface{}{"node": "node-1", "grace": "soon"}},
+				}},
+			}},
+		},
+	}
+	for _, test := range tests {
+		err := test.config.Validate(plugins, "flow")
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
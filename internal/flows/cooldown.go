@@ -0,0 +1,117 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+)
+
+// CooldownConfig holds configuration values for persisting the cooldowns of event flows
+type CooldownConfig struct {
+	// Persists the cooldowns, so they survive restarts
+	Persist bool
+
+	// Database file of the cooldowns if the history is disabled, they are stored in the history database otherwise
+	Path string
+}
+
+// Validate validates the cooldown configuration
+func (c CooldownConfig) Validate() error {
+	if c.Persist && c.Path == "" {
+		return errors.New("path must not be empty")
+	}
+
+	return nil
+}
+
+// CooldownStore persists the expiry of the cooldowns of event flows, so they survive restarts
+type CooldownStore interface {
+	SetCooldown(key string, until time.Time) error
+	Cooldowns(now time.Time) (map[string]time.Time, error)
+}
+
+type nopCooldownStore struct{}
+
+func (nopCooldownStore) SetCooldown(key string, until time.Time) error {
+	return nil
+}
+
+func (nopCooldownStore) Cooldowns(now time.Time) (map[string]time.Time, error) {
+	return nil, nil
+}
+
+// startCooldown starts the cooldown of the finished event flow, during the cooldown subsequent
+// events of the group key are skipped, its status turns completed once the cooldown expires
+func (ef *EventFlow) startCooldown() {
+	until := ef.flow.manager.Clock().Now().Add(ef.cooldown)
+
+	ef.mu.Lock()
+	ef.CooldownUntil = until
+	ef.Status = EventFlowCoolingDown
+	ef.mu.Unlock()
+
+	if ef.cooldown <= 0 {
+		return
+	}
+
+	err := ef.flow.manager.Cooldowns().SetCooldown(path.Join(ef.flow.id, ef.key), until)
+	if err != nil {
+		ef.flow.manager.ErrorHandler().Handle(emperror.WrapWith(err, "could not store cooldown", "flow", ef.flow.id, "group-key", ef.key))
+	}
+}
+
+// currentStatus returns the status of the event flow at the time now, cooling down
+// event flows are completed once their cooldown expired
+func (ef *EventFlow) currentStatus(now time.Time) EventFlowStatus {
+	ef.mu.Lock()
+	defer ef.mu.Unlock()
+
+	if ef.Status == EventFlowCoolingDown && !now.Before(ef.CooldownUntil) {
+		return EventFlowCompleted
+	}
+
+	return ef.Status
+}
+
+// restoreCooldowns puts the stored cooldowns of the flow into its flow store,
+// so events of a group key cooling down before a restart are still skipped
+func (f *Flow) restoreCooldowns(cooldowns map[string]time.Time, now time.Time) error {
+	prefix := f.id + "/"
+	for k, until := range cooldowns {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(k, prefix)
+
+		ef := &EventFlow{
+			Status:        EventFlowCoolingDown,
+			CooldownUntil: until,
+
+			flow: f,
+			key:  key,
+		}
+		err := f.cache.Set(key, ef, until.Sub(now)+flowStoreExpiration)
+		if err != nil {
+			return emperror.WrapWith(err, "could not restore cooldown", "group-key", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memCooldownStore keeps the cooldowns in memory, it stands for the database surviving restarts
+type memCooldownStore struct {
+	mu        sync.Mutex
+	cooldowns map[string]time.Time
+}
+
+func (s *memCooldownStore) SetCooldown(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cooldowns[key] = until
+
+	return nil
+}
+
+func (s *memCooldownStore) Cooldowns(now time.Time) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cooldowns := make(map[string]time.Time)
+	for k, until := range s.cooldowns {
+		if until.After(now) {
+			cooldowns[k] = until
+		}
+	}
+
+	return cooldowns, nil
+}
+
+const cooldownConfig = `
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+    groupBy: [instance]
+    cooldown: 10m
+`
+
+func TestCooldownSkipsEvents(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	env := newTestEnv(t, cooldownConfig, drain)
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	env.clock.Advance(5 * time.Minute)
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if drain.calls() != 1 {
+		t.Fatalf("expected the event to be skipped during the cooldown, got %d plugin calls", drain.calls())
+	}
+
+	// the cooldown applies to the group key only
+	env.send("drain", "alert", map[string]string{"instance": "i-2"})
+	if drain.calls() != 2 {
+		t.Fatalf("expected 2 plugin calls, got %d", drain.calls())
+	}
+
+	env.clock.Advance(5 * time.Minute)
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if drain.calls() != 3 {
+		t.Fatalf("expected the event to be handled once the cooldown expired, got %d plugin calls", drain.calls())
+	}
+}
+
+func TestCooldownsRestored(t *testing.T) {
+	store := &memCooldownStore{cooldowns: make(map[string]time.Time)}
+
+	drain := &testPlugin{name: "drain"}
+	env := newTestEnvWithOptions(t, cooldownConfig, []ManagerOption{WithCooldownStore(store)}, drain)
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if len(store.cooldowns) != 1 {
+		t.Fatalf("expected the cooldown to be stored, got %v", store.cooldowns)
+	}
+
+	// a restarted manager skips the events of the group key cooling down
+	restarted := &testPlugin{name: "drain"}
+	env = newTestEnvWithOptions(t, cooldownConfig, []ManagerOption{WithCooldownStore(store)}, restarted)
+	env.clock.Advance(5 * time.Minute)
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if restarted.calls() != 0 {
+		t.Fatal("expected the event to be skipped during the restored cooldown")
+	}
+
+	env.clock.Advance(5 * time.Minute)
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if restarted.calls() != 1 {
+		t.Fatal("expected the event to be handled once the restored cooldown expired")
+	}
+}
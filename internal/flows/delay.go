@@ -0,0 +1,195 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"path"
+	"sync"
+
+	"github.com/goph/logur"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// pendingKey identifies the event flows a cancel event applies to, it is the group key
+// without the event type, so cancel events of other types match the same event flows,
+// without a group key resolved alerts match the event flows of the same alert
+func (f *Flow) pendingKey(event *ce.Event) string {
+	key := ""
+	for _, g := range f.groupBy {
+		if s, ok := event.GetString(g); ok {
+			key = path.Join(key, s)
+		}
+	}
+
+	if key == "" {
+		if id, ok := event.GetString(ce.AlertIDKey); ok && id != "" {
+			return id
+		}
+		return event.ID
+	}
+
+	return key
+}
+
+// isCancelEvent checks whether the event cancels the pending event flows of the flow: resolved
+// alerts of an allowed event type and the configured cancel event types do
+func (f *Flow) isCancelEvent(event *ce.Event) bool {
+	if status, _ := event.GetString(ce.AlertStatusKey); status == ce.AlertResolved {
+		return f.isEventTypeAllowed(event.Type)
+	}
+
+	return f.isCancelEventType(event.Type)
+}
+
+// cancelPending cancels the pending event flows matching the cancel event, resolved
+// alerts only cancel the event flows started by the same alert type
+func (f *Flow) cancelPending(event *ce.Event, log log.Logger) {
+	key := f.pendingKey(event)
+	resolved := !f.isCancelEventType(event.Type)
+
+	f.pending.mu.Lock()
+	defer f.pending.mu.Unlock()
+
+	for _, ef := range f.pending.flows[key] {
+		if resolved && ef.event.Type != event.Type {
+			continue
+		}
+
+		ef.cancel()
+		log.WithFields(logur.Fields{"execution-id": ef.ID}).Info("pending event flow cancelled")
+	}
+}
+
+func (f *Flow) isCancelEventType(eventType string) bool {
+	for _, t := range f.cancelEvents {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pendingEventFlows holds the pending event flows of a flow by pending key, it is
+// handed over to the flow replacing it on reload
+type pendingEventFlows struct {
+	mu    sync.Mutex
+	flows map[string][]*EventFlow
+	keys  map[*EventFlow]string
+}
+
+func newPendingEventFlows() *pendingEventFlows {
+	return &pendingEventFlows{
+		flows: make(map[string][]*EventFlow),
+		keys:  make(map[*EventFlow]string),
+	}
+}
+
+func (f *Flow) addPending(ef *EventFlow) {
+	key := f.pendingKey(ef.event)
+
+	f.pending.mu.Lock()
+	defer f.pending.mu.Unlock()
+
+	f.pending.flows[key] = append(f.pending.flows[key], ef)
+	f.pending.keys[ef] = key
+}
+
+func (f *Flow) removePending(ef *EventFlow) {
+	f.pending.mu.Lock()
+	defer f.pending.mu.Unlock()
+
+	key, ok := f.pending.keys[ef]
+	if !ok {
+		return
+	}
+	delete(f.pending.keys, ef)
+
+	pending := f.pending.flows[key]
+	for i, p := range pending {
+		if p == ef {
+			pending = append(pending[:i], pending[i+1:]...)
+			break
+		}
+	}
+
+	if len(pending) == 0 {
+		delete(f.pending.flows, key)
+		return
+	}
+	f.pending.flows[key] = pending
+}
+
+// takePending takes over the pending event flows of the previous flow, they are keyed
+// by the pending key of this flow, so cancel events match them if the groupBy changed
+func (f *Flow) takePending(previous *Flow) {
+	pending := previous.pending
+
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+
+	flows := make(map[string][]*EventFlow, len(pending.flows))
+	for ef := range pending.keys {
+		key := f.pendingKey(ef.event)
+		flows[key] = append(flows[key], ef)
+		pending.keys[ef] = key
+	}
+	pending.flows = flows
+
+	f.pending = pending
+}
+
+// cancel marks the pending event flow cancelled, the parked event flow is resumed right away
+func (ef *EventFlow) cancel() {
+	ef.mu.Lock()
+	defer ef.mu.Unlock()
+
+	ef.cancelled = true
+	ef.wake()
+}
+
+func (ef *EventFlow) isCancelled() bool {
+	ef.mu.Lock()
+	defer ef.mu.Unlock()
+
+	return ef.cancelled
+}
+
+// delay parks the pending event flow for the delay of the flow instead of blocking the event
+// dispatcher, it returns false if the event flow was parked and continues on the timer
+func (ef *EventFlow) delay() bool {
+	if ef.flow.delay <= 0 || ef.delayed {
+		return true
+	}
+	ef.delayed = true
+
+	ef.setStatus(EventFlowPending)
+	ef.flow.addPending(ef)
+	ef.park(ef.flow.delay, func() {
+		ef.flow.removePending(ef)
+		ef.proceed()
+	})
+
+	// the event flow may have been cancelled before it was parked
+	if ef.isCancelled() {
+		ef.mu.Lock()
+		ef.wake()
+		ef.mu.Unlock()
+	}
+
+	return false
+}
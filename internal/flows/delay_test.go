@@ -0,0 +1,108 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+const delayConfig = `
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+    allowedEvents: [prometheus.server.alert.SpotTerminating]
+    cancelEvents: [node.ready]
+    groupBy: [instance]
+    delay: 2m
+`
+
+func TestDelayParksEventFlow(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	env := newTestEnv(t, delayConfig, drain)
+
+	// Handle returns right away instead of blocking the event dispatcher for the delay
+	env.send("drain", "prometheus.server.alert.SpotTerminating", map[string]string{"instance": "i-1"})
+	if drain.calls() != 0 {
+		t.Fatal("plugin called before the delay passed")
+	}
+	if env.clock.pending() != 1 {
+		t.Fatalf("expected the event flow to be parked on a timer, got %d timers", env.clock.pending())
+	}
+
+	// subsequent events of the group key are handled by the pending event flow
+	env.send("drain", "prometheus.server.alert.SpotTerminating", map[string]string{"instance": "i-1"})
+
+	env.clock.Advance(time.Minute)
+	if drain.calls() != 0 {
+		t.Fatal("plugin called before the delay passed")
+	}
+
+	env.clock.Advance(time.Minute)
+	if drain.calls() != 1 {
+		t.Fatalf("expected 1 plugin call after the delay, got %d", drain.calls())
+	}
+}
+
+func TestDelayCancelledByResolvedAlert(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	env := newTestEnv(t, delayConfig, drain)
+
+	env.send("drain", "prometheus.server.alert.SpotTerminating", map[string]string{"instance": "i-1"})
+	env.send("drain", "prometheus.server.alert.SpotTerminating", map[string]string{"instance": "i-2"})
+	env.clock.Advance(time.Minute)
+
+	env.send("drain", "prometheus.server.alert.SpotTerminating", map[string]string{"instance": "i-1", ce.AlertStatusKey: ce.AlertResolved})
+
+	// the cancelled event flow is resumed right away instead of waiting for the rest of its delay
+	env.clock.Advance(0)
+	if env.manager.Executions().List()[0].GroupKey != "prometheus.server.alert.SpotTerminating/i-2" {
+		t.Fatal("expected only the event flow of the other instance to be pending")
+	}
+
+	env.clock.Advance(time.Minute)
+	if drain.calls() != 1 {
+		t.Fatalf("expected 1 plugin call, got %d", drain.calls())
+	}
+	if instance, _ := drain.events[0].GetString("instance"); instance != "i-2" {
+		t.Fatalf("expected the event flow of i-2 to run, got %s", instance)
+	}
+
+	// the cancelled group key is handled again
+	env.send("drain", "prometheus.server.alert.SpotTerminating", map[string]string{"instance": "i-1"})
+	env.clock.Advance(2 * time.Minute)
+	if drain.calls() != 2 {
+		t.Fatalf("expected 2 plugin calls, got %d", drain.calls())
+	}
+}
+
+func TestDelayCancelledByCancelEvent(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	env := newTestEnv(t, delayConfig, drain)
+
+	env.send("drain", "prometheus.server.alert.SpotTerminating", map[string]string{"instance": "i-1"})
+	env.send("drain", "node.ready", map[string]string{"instance": "i-1"})
+
+	env.clock.Advance(2 * time.Minute)
+	if drain.calls() != 0 {
+		t.Fatalf("expected no plugin calls, got %d", drain.calls())
+	}
+	if len(env.manager.Executions().List()) != 0 {
+		t.Fatal("expected no running event flows")
+	}
+}
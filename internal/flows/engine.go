@@ -0,0 +1,340 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// StepSkipped is the status of steps whose condition does not hold
+const StepSkipped = "skipped"
+
+// execution holds the state of an event flow execution shared by its steps
+type execution struct {
+	plugins map[string]plugin.EventHandlerPlugin
+
+	// the event the steps are run for, parallel branches run for their own copy of it
+	event *ce.Event
+
+	*executionState
+}
+
+// executionState holds the records and step results shared by the parallel branches of an execution
+type executionState struct {
+	record *history.ExecutionRecord
+
+	mu      sync.Mutex
+	results map[string]interface{}
+}
+
+func newExecution(plugins map[string]plugin.EventHandlerPlugin, event *ce.Event, record *history.ExecutionRecord) *execution {
+	return &execution{
+		plugins: plugins,
+		event:   event,
+		executionState: &executionState{
+			record:  record,
+			results: make(map[string]interface{}),
+		},
+	}
+}
+
+// branch returns an execution sharing the state of x for a copy of its event
+func (x *execution) branch() (*execution, error) {
+	event, err := x.event.Clone()
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not copy event")
+	}
+
+	return &execution{
+		plugins:        x.plugins,
+		event:          event,
+		executionState: x.executionState,
+	}, nil
+}
+
+// merge sets the attributes changed on the event of the branch on the event of x
+func (x *execution) merge(branch *execution) error {
+	attributes, err := branch.event.Attributes()
+	if err != nil {
+		return emperror.Wrap(err, "could not get event attributes")
+	}
+
+	for k := range attributes {
+		v, _ := branch.event.Get(k)
+		if original, ok := x.event.Get(k); ok && reflect.DeepEqual(original, v) {
+			continue
+		}
+		x.event.Set(k, v)
+	}
+
+	return nil
+}
+
+// templateData returns the template data of the event extended with the results
+// of the finished steps, eg. `.steps.drain.status`
+func (x *execution) templateData() (map[string]interface{}, error) {
+	data, err := templateData(x.event)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not get event attributes")
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	results := make(map[string]interface{}, len(x.results))
+	for k, v := range x.results {
+		results[k] = v
+	}
+	data["steps"] = results
+
+	return data, nil
+}
+
+// setResult makes the outcome of the step available to the following steps,
+// unnamed parallel groups can not be referenced
+func (x *execution) setResult(s Step, status string, call *history.PluginRecord, err error) {
+	if s.Name() == "" {
+		return
+	}
+
+	result := map[string]interface{}{
+		"status": status,
+		"result": "",
+		"output": map[string]string{},
+		"error":  "",
+	}
+	if call != nil {
+		result["result"] = call.Result
+		if call.Output != nil {
+			result["output"] = call.Output
+		}
+	}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.results[s.Name()] = result
+}
+
+func (x *execution) addCall(call history.PluginRecord) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.record.Plugins = append(x.record.Plugins, call)
+}
+
+// runSteps runs the steps one after the other, a failed step does not stop the following ones,
+// done is called with the last failure not handled by onFailure steps once the steps finished,
+// steps waiting for a long-running operation continue on a timer of the clock
+func (ef *EventFlow) runSteps(x *execution, steps []Step, done func(error)) {
+	ef.runStepsFrom(x, steps, 0, nil, done)
+}
+
+func (ef *EventFlow) runStepsFrom(x *execution, steps []Step, i int, failure error, done func(error)) {
+	if i == len(steps) {
+		done(failure)
+		return
+	}
+
+	ef.runStep(x, steps[i], func(err error) {
+		if err != nil {
+			failure = err
+		}
+		ef.runStepsFrom(x, steps, i+1, failure, done)
+	})
+}
+
+// runStep runs the step and its onFailure steps if it fails, the failure
+// is handled if every onFailure step succeeds
+func (ef *EventFlow) runStep(x *execution, s Step, done func(error)) {
+	ef.execStep(x, s, func(err error) {
+		if err == nil || len(s.onFailure) == 0 {
+			done(err)
+			return
+		}
+
+		ef.runSteps(x, s.onFailure, done)
+	})
+}
+
+// execStep calls the plugin of the step or runs its parallel steps if its condition holds
+func (ef *EventFlow) execStep(x *execution, s Step, done func(error)) {
+	data, err := x.templateData()
+	run := false
+	if err == nil {
+		run, err = s.evaluate(data)
+	}
+
+	switch {
+	case err != nil:
+		ef.flow.manager.ErrorHandler().Handle(err)
+		if s.plugin != "" {
+			x.addCall(history.PluginRecord{
+				Name:      s.plugin,
+				Step:      s.name,
+				StartedAt: ef.flow.manager.Clock().Now(),
+				Status:    string(EventFlowFailed),
+				Error:     err.Error(),
+			})
+		}
+		x.setResult(s, string(EventFlowFailed), nil, err)
+		done(err)
+	case !run:
+		if s.plugin != "" {
+			x.addCall(history.PluginRecord{
+				Name:      s.plugin,
+				Step:      s.name,
+				StartedAt: ef.flow.manager.Clock().Now(),
+				Status:    StepSkipped,
+			})
+		}
+		x.setResult(s, StepSkipped, nil, nil)
+		done(nil)
+	case s.plugin != "":
+		ef.callStep(x, s, data, done)
+	default:
+		ef.runParallel(x, s.parallel, func(err error) {
+			status := string(EventFlowCompleted)
+			if err != nil {
+				status = string(EventFlowFailed)
+			}
+			x.setResult(s, status, nil, err)
+			done(err)
+		})
+	}
+}
+
+// callStep renders the parameters of the step and calls its plugin
+func (ef *EventFlow) callStep(x *execution, s Step, data map[string]interface{}, done func(error)) {
+	call := &history.PluginRecord{
+		Name:      s.plugin,
+		Step:      s.name,
+		StartedAt: ef.flow.manager.Clock().Now(),
+		Status:    string(EventFlowCompleted),
+	}
+
+	finish := func(result *plugin.Result, err error) {
+		call.Duration = ef.flow.manager.Clock().Now().Sub(call.StartedAt)
+		if result != nil {
+			call.Result = result.Status
+			call.Output = result.Output
+		}
+		if err != nil {
+			call.Status = string(EventFlowFailed)
+			call.Error = err.Error()
+			ef.flow.manager.ErrorHandler().Handle(err)
+		}
+
+		x.addCall(*call)
+		x.setResult(s, call.Status, call, err)
+
+		done(err)
+	}
+
+	params, err := s.render(data)
+	if err != nil {
+		finish(nil, err)
+		return
+	}
+	call.Params = params
+
+	ef.call(x.plugins[s.plugin], x.event, s, params, call, finish)
+}
+
+// runParallel runs the steps concurrently and calls done once all of them finished, it fails
+// if any of them fails without its onFailure steps succeeding, each step runs for its own copy
+// of the event, the attributes they set are merged in the order of the steps, the event flow
+// continues in the calling goroutine unless a step waits for a long-running operation
+func (ef *EventFlow) runParallel(x *execution, steps []Step, done func(error)) {
+	branches := make([]*execution, len(steps))
+	for i := range steps {
+		b, err := x.branch()
+		if err != nil {
+			done(err)
+			return
+		}
+		branches[i] = b
+	}
+
+	errs := make([]error, len(steps))
+
+	// the last one of the steps and the calling goroutine to finish joins the branches
+	var mu sync.Mutex
+	running := len(steps) + 1
+	finished := func() {
+		mu.Lock()
+		running--
+		last := running == 0
+		mu.Unlock()
+
+		if last {
+			done(ef.joinParallel(x, steps, branches, errs))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, s := range steps {
+		wg.Add(1)
+		go func(i int, s Step) {
+			defer wg.Done()
+			ef.runStep(branches[i], s, func(err error) {
+				errs[i] = err
+				finished()
+			})
+		}(i, s)
+	}
+	wg.Wait()
+
+	finished()
+}
+
+// joinParallel merges the events of the finished branches and returns the failures of the parallel steps
+func (ef *EventFlow) joinParallel(x *execution, steps []Step, branches []*execution, errs []error) error {
+	for _, b := range branches {
+		err := x.merge(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	var failed []string
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		name := steps[i].Name()
+		if name == "" {
+			name = "parallel"
+		}
+		failed = append(failed, name+": "+err.Error())
+	}
+	if len(failed) > 0 {
+		return errors.New("parallel steps failed: " + strings.Join(failed, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+const parallelConfig = `
+flows:
+  drain:
+    name: drain
+    steps:
+    - parallel:
+      - plugin: tag
+      - plugin: label
+    - plugin: report
+`
+
+func TestParallelStepsSetAttributes(t *testing.T) {
+	setAttribute := func(k, v string) func(event *ce.Event, params plugin.Params) {
+		return func(event *ce.Event, params plugin.Params) {
+			_, _ = event.Attributes()
+			event.Set(k, v)
+		}
+	}
+	tag := &testPlugin{name: "tag", during: setAttribute("tag", "drained")}
+	label := &testPlugin{name: "label", during: setAttribute("label", "spot")}
+	report := &testPlugin{name: "report"}
+	env := newTestEnv(t, parallelConfig, tag, label, report)
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if report.calls() != 1 {
+		t.Fatalf("expected 1 report call, got %d", report.calls())
+	}
+
+	if tag.events[0] == label.events[0] {
+		t.Fatal("expected the parallel steps to get their own copy of the event")
+	}
+
+	// the attributes set by the parallel steps are merged for the following steps
+	event := report.events[0]
+	for k, expected := range map[string]string{"tag": "drained", "label": "spot", "instance": "i-1"} {
+		if v, _ := event.GetString(k); v != expected {
+			t.Fatalf("expected attribute %s to be %q, got %q", k, expected, v)
+		}
+	}
+}
+
+// executionError returns the error of the event flow of the event cooling down
+func executionError(t *testing.T, env *testEnv, flowID string, event *ce.Event) error {
+	t.Helper()
+
+	f := env.manager.flow(flowID)
+	ef, err := f.cache.Get(f.getEventKey(event, f.groupBy))
+	if err != nil || ef == nil {
+		t.Fatal("expected the event flow to be cooling down")
+	}
+
+	return ef.Error
+}
+
+const stepsConfig = `
+flows:
+  drain:
+    name: drain
+    cooldown: 1h
+    steps:
+    - plugin: drain
+      onFailure:
+      - plugin: cordon
+      - name: notify-failure
+        plugin: notify
+        params:
+          message: "could not drain {{ .instance }}: {{ .steps.drain.error }}"
+    - name: notify-drained
+      plugin: notify
+      when: '{{ eq .steps.drain.status "completed" }}'
+    - plugin: report
+      when: '{{ eq .steps.drain.status "failed" }}'
+`
+
+func TestStepConditionsAndOnFailure(t *testing.T) {
+	drain := &testPlugin{name: "drain", err: errors.New("eviction failed")}
+	cordon := &testPlugin{name: "cordon"}
+	notify := &testPlugin{name: "notify"}
+	report := &testPlugin{name: "report"}
+	env := newTestEnv(t, stepsConfig, drain, cordon, notify, report)
+
+	event := env.send("drain", "alert", map[string]string{"instance": "i-1"})
+
+	if cordon.calls() != 1 || report.calls() != 1 {
+		t.Fatal("expected the onFailure steps and the step of the failed condition to run")
+	}
+	if notify.calls() != 1 {
+		t.Fatalf("expected only the failure notification, got %d notify calls", notify.calls())
+	}
+	if message := notify.params[0]["message"]; message != "could not drain i-1: eviction failed" {
+		t.Fatalf("unexpected message: %q", message)
+	}
+
+	// the failure is handled by the onFailure steps
+	if err := executionError(t, env, "drain", event); err != nil {
+		t.Fatalf("expected the execution to succeed, got %v", err)
+	}
+}
+
+func TestStepFailureNotHandled(t *testing.T) {
+	drain := &testPlugin{name: "drain", err: errors.New("eviction failed")}
+	cordon := &testPlugin{name: "cordon", err: errors.New("node not found")}
+	notify := &testPlugin{name: "notify"}
+	report := &testPlugin{name: "report"}
+	env := newTestEnv(t, stepsConfig, drain, cordon, notify, report)
+
+	event := env.send("drain", "alert", map[string]string{"instance": "i-1"})
+
+	// a failed step does not stop the following ones, the execution fails
+	if report.calls() != 1 {
+		t.Fatal("expected the following steps to run")
+	}
+	if err := executionError(t, env, "drain", event); err == nil {
+		t.Fatal("expected the execution to fail")
+	}
+}
+
+const parallelFailureConfig = `
+flows:
+  drain:
+    name: drain
+    cooldown: 1h
+    steps:
+    - parallel:
+      - plugin: tag
+      - plugin: label
+    - plugin: report
+      when: '{{ eq .steps.tag.status "completed" }}'
+`
+
+func TestParallelStepFailure(t *testing.T) {
+	tag := &testPlugin{name: "tag"}
+	label := &testPlugin{name: "label", err: errors.New("label failed")}
+	report := &testPlugin{name: "report"}
+	env := newTestEnv(t, parallelFailureConfig, tag, label, report)
+
+	event := env.send("drain", "alert", nil)
+
+	if tag.calls() != 1 || label.calls() != 1 {
+		t.Fatal("expected every parallel step to run")
+	}
+	if report.calls() != 1 {
+		t.Fatal("expected the results of the parallel steps to be available to the following steps")
+	}
+	if err := executionError(t, env, "drain", event); err == nil {
+		t.Fatal("expected the execution to fail")
+	}
+}
+
+func TestDuplicateStepNames(t *testing.T) {
+	config := FlowConfig{
+		Name: "drain",
+		Steps: []StepConfig{
+			{Plugin: "drain", OnFailure: []StepConfig{{Plugin: "notify"}}},
+			{Parallel: []StepConfig{{Plugin: "notify"}, {Plugin: "report"}}},
+		},
+	}
+
+	_, err := config.GetSteps()
+	if err == nil {
+		t.Fatal("expected an error for steps calling the same plugin without a name")
+	}
+
+	config.Steps[1].Parallel[0].Name = "notify-drained"
+	_, err = config.GetSteps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Steps[1].Parallel[0].Name = "drain"
+	_, err = config.GetSteps()
+	if err == nil {
+		t.Fatal("expected an error for a step named after another one")
+	}
+}
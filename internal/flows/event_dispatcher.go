@@ -14,28 +14,39 @@
 
 package flows
 
+import (
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
 const (
 	CEIncomingTopic = "cloud.events.incoming"
 )
 
-type baseEventSubscriber interface {
+type baseEventDispatcher interface {
 	SubscribeAsync(topic string, fn interface{}, transactional bool) error
+	Publish(topic string, args ...interface{}) error
 }
 
 type eventSubscriber interface {
 	SubscribeAsync(topic string, flow ActionFlow) error
 }
 
+// EventPublisher publishes events created by action flows
+type EventPublisher interface {
+	Publish(topic string, event *ce.Event) error
+}
+
 type flowEventDispatcher interface {
 	eventSubscriber
+	EventPublisher
 }
 
 type eventDispatcher struct {
-	eb baseEventSubscriber
+	eb baseEventDispatcher
 }
 
 // NewEventDispatcher returns an initialized eventDispatcher
-func NewEventDispatcher(eb baseEventSubscriber) flowEventDispatcher {
+func NewEventDispatcher(eb baseEventDispatcher) flowEventDispatcher {
 	return &eventDispatcher{
 		eb: eb,
 	}
@@ -45,3 +56,8 @@ func NewEventDispatcher(eb baseEventSubscriber) flowEventDispatcher {
 func (b *eventDispatcher) SubscribeAsync(topic string, flow ActionFlow) error {
 	return b.eb.SubscribeAsync(topic, flow.Handle, false)
 }
+
+// Publish sends the given event through the event dispatcher
+func (b *eventDispatcher) Publish(topic string, event *ce.Event) error {
+	return b.eb.Publish(topic, event)
+}
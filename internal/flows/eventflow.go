@@ -15,9 +15,20 @@
 package flows
 
 import (
+	"context"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/goph/emperror"
+	"github.com/goph/logur"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+
 	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
 )
 
 const (
@@ -26,6 +37,19 @@ const (
 	EventFlowInProgress  EventFlowStatus = "inprogress"
 	EventFlowInitialized EventFlowStatus = "initialized"
 	EventFlowCoolingDown EventFlowStatus = "coolingdown"
+	EventFlowDeferred    EventFlowStatus = "deferred"
+	EventFlowQueued      EventFlowStatus = "queued"
+	EventFlowDropped     EventFlowStatus = "dropped"
+	EventFlowBlocked     EventFlowStatus = "blocked"
+	EventFlowPending     EventFlowStatus = "pending"
+	EventFlowCancelled   EventFlowStatus = "cancelled"
+
+	// UnavailableFail fails the event flow right away if a plugin is unavailable
+	UnavailableFail = "fail"
+	// UnavailableDefer waits for unavailable plugins to recover before executing the event flow
+	UnavailableDefer = "defer"
+
+	deferCheckInterval = 10 * time.Second
 )
 
 type EventFlowStatus string
@@ -33,46 +57,371 @@ type EventFlowStatus string
 // EventFlow is an actual sequential executing of defined plugins
 // for a particular event and a defined action flow
 type EventFlow struct {
+	ID     string
 	Status EventFlowStatus
 	Error  error
 
-	flow  *Flow
-	event *ce.Event
+	// Subsequent events of the group key are skipped until the cooldown expires
+	CooldownUntil time.Time
+
+	flow     *Flow
+	event    *ce.Event
+	key      string
+	tenant   tenant
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	record    *history.ExecutionRecord
+	plugin    string
+	progress  []history.ProgressRecord
+	cancelled bool
+
+	// the parked event flow continues by calling resume when the timer fires
+	timer  clock.Timer
+	resume func()
+
+	// stages done, they are only accessed by the goroutine running the event flow
+	delayed       bool
+	deferredUntil time.Time
+	queued        map[*rateLimiter]time.Time
+	held          map[*rateLimiter]bool
+	releases      []func()
 }
 
 // NewEventFlow returns an initialized EventFlow
-func NewEventFlow(flow *Flow, event *ce.Event) *EventFlow {
+func NewEventFlow(flow *Flow, event *ce.Event, key string) *EventFlow {
 	return &EventFlow{
+		ID:     uuid.NewV4().String(),
 		Status: EventFlowInitialized,
 
-		flow:  flow,
-		event: event,
+		flow:     flow,
+		event:    event,
+		key:      key,
+		cooldown: flow.cooldown,
+	}
+}
+
+// Exec starts the event flow, it returns once the event flow finished or was parked on a timer
+// of the clock, eg. waiting for its delay, parked event flows continue in the goroutine of the timer
+func (ef *EventFlow) Exec() {
+	ef.flow.manager.Executions().add(ef)
+	ef.proceed()
+}
+
+// proceed runs the stages of the event flow not done yet: it waits for its delay, one of the
+// active windows of the flow, its unavailable plugins with the defer policy and the rate limits
+// of the flow and the tenant, then executes the steps
+func (ef *EventFlow) proceed() {
+	if !ef.delay() {
+		return
+	}
+
+	if ef.isCancelled() {
+		ef.setStatus(EventFlowCancelled)
+		ef.finish(nil)
+		return
+	}
+
+	proceed, dropped := ef.waitForWindow()
+	if dropped {
+		ef.setStatus(EventFlowDropped)
+		ef.finish(emperror.With(ErrOutsideActiveWindows, "flow", ef.flow.id, "group-key", ef.key))
+		return
+	}
+	if !proceed {
+		return
 	}
+
+	if !ef.waitForPlugins() {
+		return
+	}
+
+	ef.admit()
 }
 
-// Exec executes the defined plugins sequentially
-func (ef *EventFlow) Exec() error {
-	ef.Status = EventFlowInProgress
+// admit runs the event flow once the rate limits of the flow and of its tenant let it start
+func (ef *EventFlow) admit() {
+	proceed, dropped := ef.acquire()
+	if dropped {
+		ef.setStatus(EventFlowDropped)
+		ef.finish(emperror.With(ErrRateLimited, "flow", ef.flow.id, "group-key", ef.key))
+		return
+	}
+	if !proceed {
+		return
+	}
+
+	proceed, dropped = ef.acquireTenant()
+	if dropped {
+		ef.setStatus(EventFlowDropped)
+		ef.finish(emperror.With(ErrTenantRateLimited, "flow", ef.flow.id, "tenant", ef.tenant.name, "group-key", ef.key))
+		return
+	}
+	if !proceed {
+		return
+	}
 
-	plugins, err := ef.flow.manager.Plugins().GetByNames(ef.flow.plugins...)
+	ef.execute(ef.finish)
+}
+
+// park stops running the event flow until the timer calls resume after the duration d
+func (ef *EventFlow) park(d time.Duration, resume func()) {
+	ef.mu.Lock()
+	defer ef.mu.Unlock()
+
+	ef.resume = resume
+	ef.timer = ef.flow.manager.Clock().AfterFunc(d, resume)
+}
+
+// wake resumes the parked event flow right away, ef.mu must be held
+func (ef *EventFlow) wake() {
+	if ef.timer != nil && ef.timer.Stop() {
+		ef.timer = ef.flow.manager.Clock().AfterFunc(0, ef.resume)
+	}
+}
+
+// finish releases the rate limits of the finished event flow and updates the flow store
+func (ef *EventFlow) finish(err error) {
+	for _, release := range ef.releases {
+		release()
+	}
+	ef.flow.manager.Executions().remove(ef)
+
+	ef.flow.finish(ef, err)
+}
+
+// execute executes the steps of the flow and calls done once they finished, if a plugin is
+// unavailable the execution fails without calling any plugin
+func (ef *EventFlow) execute(done func(error)) {
+	err := ef.checkBudget()
 	if err != nil {
-		ef.Status = EventFlowFailed
+		ef.setStatus(EventFlowBlocked)
+		done(err)
+		return
+	}
+
+	names := pluginNames(ef.flow.steps)
+
+	plugins, err := ef.flow.manager.Plugins().GetByNames(names...)
+	if err == nil {
+		err = ef.checkPlugins(names)
+	}
+
+	record := ef.newRecord()
+	ef.record = record
+	ef.setStatus(EventFlowInProgress)
+
+	ef.publishLifecycleEvent(lifecycleStarted)
+
+	if err != nil {
+		ef.setStatus(EventFlowFailed)
 		ef.Error = err
-		return err
+		ef.finishRecord(record)
+		done(err)
+		return
+	}
+
+	ef.runSteps(newExecution(plugins, ef.event, record), ef.flow.steps, func(err error) {
+		ef.Error = err
+
+		ef.finishRecord(record)
+		for _, release := range ef.releases {
+			release()
+		}
+
+		ef.startCooldown()
+
+		done(nil)
+	})
+}
+
+// call calls the plugin of the step, if the plugin starts a long-running operation the event flow
+// is parked until the operation finishes or the step timeout passes, done is called with the result
+func (ef *EventFlow) call(p plugin.EventHandlerPlugin, event *ce.Event, step Step, params plugin.Params, call *history.PluginRecord, done func(*plugin.Result, error)) {
+	ef.mu.Lock()
+	ef.plugin = step.Plugin()
+	ef.progress = nil
+	ef.mu.Unlock()
+
+	ctx := plugin.WithProgressFunc(context.Background(), func(p plugin.Progress) {
+		ef.reportProgress(call, p)
+	})
+	cancel := func() {}
+	if step.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, step.timeout)
 	}
 
-	for _, plugin := range plugins {
-		err := plugin.Handle(ef.event)
+	result, err := p.Handle(ctx, event, params)
+	cancel()
+	if err != nil || result == nil || result.OperationID == "" {
+		done(result, err)
+		return
+	}
+	call.OperationID = result.OperationID
+
+	// the step timeout covers the plugin call and the operation
+	var timeout time.Duration
+	if step.timeout > 0 {
+		timeout = step.timeout - ef.flow.manager.Clock().Now().Sub(call.StartedAt)
+		if timeout <= 0 {
+			done(result, emperror.With(plugin.ErrOperationTimeout, "plugin", step.Plugin(), "operation", result.OperationID))
+			return
+		}
+	}
+
+	ef.flow.manager.Plugins().WatchOperation(step.Plugin(), result, timeout, func(op *plugin.OperationStatus, err error) {
 		if err != nil {
-			ef.flow.manager.ErrorHandler().Handle(err)
+			done(result, err)
+			return
+		}
+
+		result.Status = op.Status
+		if op.State == plugin.OperationFailed {
+			done(result, emperror.With(errors.New("operation failed: "+op.Error), "plugin", step.Plugin(), "operation", result.OperationID))
+			return
 		}
+
+		done(result, nil)
+	})
+}
+
+// reportProgress records a progress update of the plugin call on the event flow and in its history
+func (ef *EventFlow) reportProgress(call *history.PluginRecord, p plugin.Progress) {
+	r := history.ProgressRecord{
+		Time:       ef.flow.manager.Clock().Now(),
+		Percentage: p.Percentage,
+		Message:    p.Message,
+		Output:     p.Output,
 	}
+	call.Progress = appendProgress(call.Progress, r)
+
+	ef.mu.Lock()
+	ef.progress = appendProgress(ef.progress, r)
+	ef.mu.Unlock()
 
-	ef.Status = EventFlowCoolingDown
+	cid, _ := ef.event.GetString(ce.CorrelationIDKey)
+	ef.flow.manager.Logger().WithFields(logur.Fields{
+		"correlation-id": cid,
+		"flow-id":        ef.flow.id,
+		"execution-id":   ef.ID,
+		"plugin":         call.Name,
+		"percentage":     p.Percentage,
+	}).Infof("plugin progress: %s", p.Message)
+}
 
-	time.Sleep(ef.flow.cooldown)
+func (ef *EventFlow) setStatus(status EventFlowStatus) {
+	ef.mu.Lock()
+	defer ef.mu.Unlock()
 
-	ef.Status = EventFlowCompleted
+	ef.Status = status
+}
+
+// status returns the state of the running event flow
+func (ef *EventFlow) status() ExecutionStatus {
+	ef.mu.Lock()
+	defer ef.mu.Unlock()
+
+	s := ExecutionStatus{
+		ID:        ef.ID,
+		FlowID:    ef.flow.id,
+		FlowName:  ef.flow.name,
+		GroupKey:  ef.key,
+		EventID:   ef.event.ID,
+		EventType: ef.event.Type,
+		Status:    ef.Status,
+		Plugin:    ef.plugin,
+		Progress:  append([]history.ProgressRecord(nil), ef.progress...),
+	}
+	if ef.record != nil {
+		s.CorrelationID = ef.record.CorrelationID
+		s.StartedAt = ef.record.StartedAt
+	}
+
+	return s
+}
+
+// waitForPlugins reports whether the event flow can proceed, with the defer policy it
+// parks the event flow while any of its plugins is unavailable until the defer timeout
+// passes, checking them again every deferCheckInterval
+func (ef *EventFlow) waitForPlugins() bool {
+	if ef.flow.unavailablePolicy != UnavailableDefer {
+		return true
+	}
+
+	if len(ef.unavailablePlugins(pluginNames(ef.flow.steps))) == 0 {
+		return true
+	}
+
+	now := ef.flow.manager.Clock().Now()
+	if ef.deferredUntil.IsZero() {
+		ef.setStatus(EventFlowDeferred)
+		ef.deferredUntil = now.Add(ef.flow.deferTimeout)
+	}
+
+	// the execution fails as the plugins did not recover in time
+	remaining := ef.deferredUntil.Sub(now)
+	if remaining <= 0 {
+		return true
+	}
+	if remaining > deferCheckInterval {
+		remaining = deferCheckInterval
+	}
+
+	ef.park(remaining, ef.proceed)
+
+	return false
+}
+
+// checkPlugins returns an error if any of the plugins is unavailable
+func (ef *EventFlow) checkPlugins(names []string) error {
+	unavailable := ef.unavailablePlugins(names)
+	if len(unavailable) > 0 {
+		return emperror.With(plugin.ErrCircuitOpen, "plugins", strings.Join(unavailable, ","))
+	}
 
 	return nil
 }
+
+func (ef *EventFlow) unavailablePlugins(names []string) []string {
+	var unavailable []string
+	for _, name := range names {
+		if !ef.flow.manager.Plugins().Available(name) {
+			unavailable = append(unavailable, name)
+		}
+	}
+
+	return unavailable
+}
+
+func (ef *EventFlow) newRecord() *history.ExecutionRecord {
+	cid, _ := ef.event.GetString("correlationid")
+	clusterID, _ := ef.event.GetString("cluster_id")
+
+	return &history.ExecutionRecord{
+		ID:            ef.ID,
+		FlowID:        ef.flow.id,
+		FlowName:      ef.flow.name,
+		GroupKey:      ef.key,
+		EventID:       ef.event.ID,
+		EventType:     ef.event.Type,
+		CorrelationID: cid,
+		ClusterID:     clusterID,
+		StartedAt:     ef.flow.manager.Clock().Now(),
+		Plugins:       []history.PluginRecord{},
+	}
+}
+
+// finishRecord sets the final status of an execution, records it and publishes
+// the lifecycle event, the execution is failed if any of the plugins failed
+func (ef *EventFlow) finishRecord(record *history.ExecutionRecord) {
+	record.FinishedAt = ef.flow.manager.Clock().Now()
+	record.Status = string(EventFlowCompleted)
+
+	if ef.Error != nil {
+		record.Status = string(EventFlowFailed)
+		record.Error = ef.Error.Error()
+	}
+
+	ef.flow.manager.Recorder().RecordExecution(record)
+	ef.publishLifecycleEvent(record.Status)
+}
@@ -0,0 +1,109 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/hollowtrees/internal/history"
+)
+
+// maxProgressRecords limits the progress updates kept of a plugin call
+const maxProgressRecords = 100
+
+// ExecutionStatus describes the state of a running event flow
+type ExecutionStatus struct {
+	ID            string          `json:"id"`
+	FlowID        string          `json:"flowID"`
+	FlowName      string          `json:"flowName"`
+	GroupKey      string          `json:"groupKey"`
+	EventID       string          `json:"eventID"`
+	EventType     string          `json:"eventType"`
+	CorrelationID string          `json:"correlationID,omitempty"`
+	Status        EventFlowStatus `json:"status"`
+	StartedAt     time.Time       `json:"startedAt"`
+
+	// The plugin being called and its progress updates
+	Plugin   string                   `json:"plugin,omitempty"`
+	Progress []history.ProgressRecord `json:"progress,omitempty"`
+}
+
+// Executions keeps track of the running event flows
+type Executions struct {
+	mu    sync.RWMutex
+	flows map[string]*EventFlow
+}
+
+// NewExecutions returns an initialized Executions
+func NewExecutions() *Executions {
+	return &Executions{
+		flows: make(map[string]*EventFlow),
+	}
+}
+
+func (e *Executions) add(ef *EventFlow) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.flows[ef.ID] = ef
+}
+
+func (e *Executions) remove(ef *EventFlow) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.flows, ef.ID)
+}
+
+// List returns the state of the running event flows ordered by their start
+func (e *Executions) List() []ExecutionStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	statuses := make([]ExecutionStatus, 0, len(e.flows))
+	for _, ef := range e.flows {
+		statuses = append(statuses, ef.status())
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].StartedAt.Before(statuses[j].StartedAt)
+	})
+
+	return statuses
+}
+
+// Get returns the state of a running event flow
+func (e *Executions) Get(id string) (ExecutionStatus, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ef, ok := e.flows[id]
+	if !ok {
+		return ExecutionStatus{}, false
+	}
+
+	return ef.status(), true
+}
+
+// appendProgress appends the progress update dropping the oldest ones above the limit
+func appendProgress(records []history.ProgressRecord, r history.ProgressRecord) []history.ProgressRecord {
+	if len(records) >= maxProgressRecords {
+		records = records[len(records)-maxProgressRecords+1:]
+	}
+
+	return append(records, r)
+}
@@ -0,0 +1,163 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// progressPlugin reports progress updates before handling the event
+type progressPlugin struct {
+	testPlugin
+	updates []plugin.Progress
+}
+
+func (p *progressPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	for _, u := range p.updates {
+		plugin.ReportProgress(ctx, u)
+	}
+
+	return p.testPlugin.Handle(ctx, event, params)
+}
+
+// historyRecorder collects the recorded executions
+type historyRecorder struct {
+	mu      sync.Mutex
+	records []*history.ExecutionRecord
+}
+
+func (r *historyRecorder) RecordExecution(record *history.ExecutionRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, record)
+}
+
+const progressConfig = `
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+`
+
+func TestProgress(t *testing.T) {
+	drain := &progressPlugin{
+		testPlugin: testPlugin{name: "drain"},
+		updates: []plugin.Progress{
+			{Percentage: 10, Message: "cordoned"},
+			{Percentage: 50, Message: "evicting", Output: map[string]string{"pods": "3"}},
+		},
+	}
+	recorder := &historyRecorder{}
+	env := newTestEnvWithOptions(t, progressConfig, []ManagerOption{WithExecutionRecorder(recorder)}, drain)
+
+	// the progress of the running plugin call is listed with the execution
+	var running []ExecutionStatus
+	drain.during = func(event *ce.Event, params plugin.Params) {
+		running = env.manager.Executions().List()
+	}
+
+	env.send("drain", "alert", nil)
+
+	if len(running) != 1 || running[0].Plugin != "drain" || running[0].Status != EventFlowInProgress {
+		t.Fatalf("expected the running execution to call drain, got %+v", running)
+	}
+	progress := running[0].Progress
+	if len(progress) != 2 || progress[0].Message != "cordoned" || progress[1].Percentage != 50 || progress[1].Output["pods"] != "3" {
+		t.Fatalf("unexpected progress of the running execution: %+v", progress)
+	}
+	if !progress[0].Time.Equal(env.clock.Now()) {
+		t.Fatalf("expected the progress to be timed by the clock, got %s", progress[0].Time)
+	}
+
+	// and it is recorded in the history of the plugin call
+	if len(recorder.records) != 1 || len(recorder.records[0].Plugins) != 1 {
+		t.Fatalf("expected a recorded execution with a plugin call, got %+v", recorder.records)
+	}
+	if recorded := recorder.records[0].Plugins[0].Progress; len(recorded) != 2 || recorded[1].Message != "evicting" {
+		t.Fatalf("unexpected recorded progress: %+v", recorded)
+	}
+}
+
+func TestAppendProgressLimit(t *testing.T) {
+	var records []history.ProgressRecord
+	for i := 0; i < maxProgressRecords+10; i++ {
+		records = appendProgress(records, history.ProgressRecord{Message: fmt.Sprint(i)})
+	}
+
+	if len(records) != maxProgressRecords {
+		t.Fatalf("expected %d progress records, got %d", maxProgressRecords, len(records))
+	}
+	if records[0].Message != "10" || records[len(records)-1].Message != fmt.Sprint(maxProgressRecords+9) {
+		t.Fatalf("expected the oldest progress records to be dropped, got %s..%s", records[0].Message, records[len(records)-1].Message)
+	}
+}
+
+func TestExecutionsAPI(t *testing.T) {
+	scale := &operationPlugin{testPlugin: testPlugin{name: "scale"}}
+	env := newTestEnv(t, operationConfig, scale, &testPlugin{name: "notify"}, &testPlugin{name: "report"})
+
+	r := gin.New()
+	NewAPI(env.manager.Executions()).RegisterRoutes(r.Group("/api/v1"))
+	get := func(path string, v interface{}) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if v != nil && w.Code == http.StatusOK {
+			err := json.Unmarshal(w.Body.Bytes(), v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return w.Code
+	}
+
+	// the event flow waits for the operation of the scale plugin
+	env.send("scale", "alert", nil)
+
+	var list struct {
+		Data []ExecutionStatus
+	}
+	if code := get("/api/v1/flows/executions", &list); code != http.StatusOK || len(list.Data) != 1 {
+		t.Fatalf("expected a running execution, got %d: %+v", code, list)
+	}
+
+	var execution struct {
+		Data ExecutionStatus
+	}
+	id := list.Data[0].ID
+	if code := get("/api/v1/flows/executions/"+id, &execution); code != http.StatusOK || execution.Data.FlowID != "scale" || execution.Data.Plugin != "scale" {
+		t.Fatalf("unexpected execution, got %d: %+v", code, execution)
+	}
+
+	scale.finish()
+	env.clock.Advance(10 * time.Second)
+	if code := get("/api/v1/flows/executions/"+id, nil); code != http.StatusNotFound {
+		t.Fatalf("expected the finished execution not to be found, got %d", code)
+	}
+}
@@ -16,6 +16,7 @@ package flows
 
 import (
 	"path"
+	"strings"
 	"time"
 
 	"github.com/goph/emperror"
@@ -25,6 +26,9 @@ import (
 	"github.com/banzaicloud/hollowtrees/internal/ce"
 )
 
+// flowStoreExpiration is the time finished event flows are kept in the flow store after their cooldown expires
+const flowStoreExpiration = 5 * time.Minute
+
 // ActionFlow defines an action flow
 type ActionFlow interface {
 	Handle(event interface{})
@@ -38,9 +42,22 @@ type Flow struct {
 	allowedEvents []string
 	cooldown      time.Duration
 	groupBy       []string
-	plugins       []string
+	steps         []Step
 	filters       map[string]string
 
+	unavailablePolicy string
+	deferTimeout      time.Duration
+	limiter           *rateLimiter
+	aggregator        *aggregator
+	disruptive        bool
+	tenants           []string
+	delay             time.Duration
+	cancelEvents      []string
+	activeWindows     activeWindows
+	windowPolicy      string
+
+	pending *pendingEventFlows
+
 	cache   FlowStore
 	manager FlowManager
 }
@@ -53,6 +70,7 @@ func NewFlow(manager FlowManager, cache FlowStore, id string, name string, opts
 
 		manager: manager,
 		cache:   cache,
+		pending: newPendingEventFlows(),
 	}
 
 	for _, o := range opts {
@@ -62,6 +80,22 @@ func NewFlow(manager FlowManager, cache FlowStore, id string, name string, opts
 	return f
 }
 
+// takeOver takes over the state of the flow it replaces on reload: the pending event flows,
+// the buffered events and the rate limits, the event flows in progress finish with the previous flow
+func (f *Flow) takeOver(previous *Flow) {
+	f.takePending(previous)
+
+	if f.limiter != nil && previous.limiter != nil {
+		previous.limiter.reconfigure(f.limiter.config)
+		f.limiter = previous.limiter
+	}
+
+	if f.aggregator != nil && previous.aggregator != nil {
+		previous.aggregator.reconfigure(f.aggregator.config)
+		f.aggregator = previous.aggregator
+	}
+}
+
 // Handle handles the event by starting and event flow which executes the defined plugins
 func (f *Flow) Handle(event interface{}) {
 	e, ok := event.(*ce.Event)
@@ -87,34 +121,87 @@ func (f *Flow) handleEvent(event *ce.Event) error {
 		"group-key":      key,
 	})
 
+	// events of other tenants do not cancel the pending event flows either
+	tn, allowed := f.resolveTenant(event)
+	if !allowed {
+		log.Debug("skip flow - tenant not allowed")
+		return nil
+	}
+	override := f.tenantOverride(tn)
+
+	if f.delay > 0 && f.isCancelEvent(event) {
+		f.cancelPending(event, log)
+		return nil
+	}
+
 	if !f.isEventTypeAllowed(event.Type) {
 		log.Debug("skip flow - disallowed event type")
 		return nil
 	}
 
-	if !f.isEventMatched(event) {
+	if !f.isEventMatched(event) || !matchFilters(event, override.Filters) {
 		log.Debug("skip flow - filter does not match")
 		return nil
 	}
 
-	ef, created, err := f.createOrGetEventFlow(event, key)
-	if err != nil {
-		return err
+	if hops := event.Hops(); hops > f.manager.Config().MaxHops {
+		return emperror.With(errors.New("event exceeded max hops, possible flow loop"), "hops", hops, "max-hops", f.manager.Config().MaxHops)
 	}
 
-	if created {
-		log.Debugf("executing event flow - %s", ef.Status)
-		err = ef.Exec()
+	if f.aggregator != nil {
+		events, count := f.aggregator.add(event, f.manager.Clock().Now())
+		if events == nil {
+			log.WithFields(logur.Fields{"buffered": count}).Debug("skip flow - event buffered for aggregation")
+			return nil
+		}
+
+		composite, err := compositeEvent(events)
 		if err != nil {
-			return err
+			return emperror.Wrap(err, "could not aggregate events")
 		}
-		f.cache.Delete(key)
+		log.WithFields(logur.Fields{"aggregated": count, "composite-event-id": composite.ID}).Info("aggregated events")
+
+		event = composite
+		key = f.getEventKey(event, f.groupBy)
+	}
+
+	ef, created, err := f.createOrGetEventFlow(event, key, tn, override)
+	if err != nil {
+		return err
+	}
+
+	if !created {
+		return nil
 	}
 
+	log.Debugf("executing event flow - %s", ef.Status)
+	ef.Exec()
+
 	return nil
 }
 
-func (f *Flow) createOrGetEventFlow(event *ce.Event, key string) (*EventFlow, bool, error) { // f.mux.Lock()
+// finish updates the flow store once the event flow finished, failed event flows do not cool
+// down, so the next event of the group key is handled again
+func (f *Flow) finish(ef *EventFlow, err error) {
+	if err != nil {
+		f.cache.Delete(ef.key)
+		f.manager.ErrorHandler().Handle(emperror.WrapWith(err, "could not handle event", "type", ef.event.Type, "id", ef.event.ID, "flow", f.name))
+		return
+	}
+
+	if ef.currentStatus(f.manager.Clock().Now()) != EventFlowCoolingDown {
+		f.cache.Delete(ef.key)
+		return
+	}
+
+	// the event flow is kept until its cooldown expires, the execution may have taken longer than expected
+	err = f.cache.Set(ef.key, ef, ef.cooldown+flowStoreExpiration)
+	if err != nil {
+		f.manager.ErrorHandler().Handle(emperror.WrapWith(err, "could not store event flow", "flow", f.name, "group-key", ef.key))
+	}
+}
+
+func (f *Flow) createOrGetEventFlow(event *ce.Event, key string, tn tenant, override TenantOverrideConfig) (*EventFlow, bool, error) { // f.mux.Lock()
 	created := false
 
 	ef, err := f.cache.Get(key)
@@ -122,13 +209,25 @@ func (f *Flow) createOrGetEventFlow(event *ce.Event, key string) (*EventFlow, bo
 		return nil, created, err
 	}
 
-	if ef != nil && ef.Status == EventFlowCompleted {
+	if ef != nil && ef.currentStatus(f.manager.Clock().Now()) == EventFlowCompleted {
 		ef = nil
 	}
 
 	if ef == nil {
-		ef = NewEventFlow(f, event)
-		err := f.cache.Set(key, ef, f.cooldown+time.Duration(5)*time.Minute)
+		// plugins may change the event, every event flow works on its own copy
+		clone, err := event.Clone()
+		if err != nil {
+			return nil, created, err
+		}
+
+		ef = NewEventFlow(f, clone, key)
+		ef.tenant = tn
+		if override.Cooldown != nil {
+			ef.cooldown = *override.Cooldown
+		}
+		// the event flow is kept until it finishes, however long it is pending, deferred or queued,
+		// so subsequent events of the group key do not start another one meanwhile
+		err = f.cache.Set(key, ef, storeNoExpiration)
 		if err != nil {
 			return nil, created, err
 		}
@@ -157,11 +256,11 @@ func (f *Flow) getEventKey(event *ce.Event, groupBy []string) string {
 }
 
 func (f *Flow) isEventMatched(event *ce.Event) bool {
-	if len(f.filters) == 0 {
-		return true
-	}
+	return matchFilters(event, f.filters)
+}
 
-	for key, value := range f.filters {
+func matchFilters(event *ce.Event, filters map[string]string) bool {
+	for key, value := range filters {
 		if v, ok := event.GetString(key); !ok || v != value {
 			return false
 		}
@@ -170,9 +269,11 @@ func (f *Flow) isEventMatched(event *ce.Event) bool {
 	return true
 }
 
+// isEventTypeAllowed checks the event type against the allowed events, flows without allowed
+// events accept every event except the lifecycle events of flows, so they can not trigger themselves
 func (f *Flow) isEventTypeAllowed(eventType string) bool {
 	if len(f.allowedEvents) == 0 {
-		return true
+		return !strings.HasPrefix(eventType, LifecycleEventTypePrefix)
 	}
 
 	for _, t := range f.allowedEvents {
@@ -0,0 +1,291 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// fakeClock is a clock whose time only moves when it is advanced, timers due are fired synchronously
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	fn      func()
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Sleep advances the clock, the code under test does not block
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, fn func()) clock.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, at: c.now.Add(d), fn: fn}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, other := range t.clock.timers {
+		if other == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			t.stopped = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// Advance moves the time forward by d, firing the timers due in the order of their deadlines
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	until := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+		if len(c.timers) == 0 || c.timers[0].at.After(until) {
+			c.now = until
+			c.mu.Unlock()
+			return
+		}
+
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+		c.mu.Unlock()
+
+		t.fn()
+	}
+}
+
+// pending returns the number of timers not fired yet
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+type testDispatcher struct {
+	mu        sync.Mutex
+	published []*ce.Event
+}
+
+func (d *testDispatcher) SubscribeAsync(topic string, flow ActionFlow) error {
+	return nil
+}
+
+func (d *testDispatcher) Publish(topic string, event *ce.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.published = append(d.published, event)
+
+	return nil
+}
+
+// events returns the published events
+func (d *testDispatcher) events() []*ce.Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]*ce.Event(nil), d.published...)
+}
+
+// testPlugin records the events it handles, it fails if err is set
+type testPlugin struct {
+	name string
+	err  error
+
+	// called before returning, eg. to check the state of the flow during the call
+	during func(event *ce.Event, params plugin.Params)
+
+	mu     sync.Mutex
+	events []*ce.Event
+	params []plugin.Params
+}
+
+func (p *testPlugin) GetName() string {
+	return p.name
+}
+
+func (p *testPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	p.mu.Lock()
+	p.events = append(p.events, event)
+	p.params = append(p.params, params)
+	p.mu.Unlock()
+
+	if p.during != nil {
+		p.during(event, params)
+	}
+
+	return &plugin.Result{}, p.err
+}
+
+func (p *testPlugin) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.events)
+}
+
+// errorRecorder is an error handler collecting the handled errors
+type errorRecorder struct {
+	mu     sync.Mutex
+	errors []error
+}
+
+func (h *errorRecorder) Handle(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.errors = append(h.errors, err)
+}
+
+func (h *errorRecorder) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.errors)
+}
+
+var _ emperror.Handler = (*errorRecorder)(nil)
+
+type testEnv struct {
+	manager    *Manager
+	clock      *fakeClock
+	errors     *errorRecorder
+	dispatcher *testDispatcher
+}
+
+// readConfig reads the YAML config
+func readConfig(t *testing.T, config string) *viper.Viper {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(bytes.NewBufferString(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+// newTestEnv loads the flows of the YAML config with the plugins
+func newTestEnv(t *testing.T, config string, plugins ...plugin.EventHandlerPlugin) *testEnv {
+	t.Helper()
+
+	return newTestEnvWithOptions(t, config, nil, plugins...)
+}
+
+// newTestEnvWithOptions loads the flows of the YAML config with the plugins and the manager options
+func newTestEnvWithOptions(t *testing.T, config string, opts []ManagerOption, plugins ...plugin.EventHandlerPlugin) *testEnv {
+	t.Helper()
+
+	return newTestEnvWithPluginOptions(t, config, opts, nil, plugins...)
+}
+
+// newTestEnvWithPluginOptions loads the flows of the YAML config with the plugins, the manager
+// options and the options of the plugin manager, which uses the clock of the flow manager
+func newTestEnvWithPluginOptions(t *testing.T, config string, opts []ManagerOption, pluginOpts []plugin.ManagerOption, plugins ...plugin.EventHandlerPlugin) *testEnv {
+	t.Helper()
+
+	v := readConfig(t, config)
+
+	engine := EngineConfig{
+		MaxHops: DefaultMaxHops,
+	}
+	err := v.UnmarshalKey("flowEngine", &engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	errors := &errorRecorder{}
+
+	c := newFakeClock()
+
+	pm := plugin.NewManager(logger, errors, append([]plugin.ManagerOption{plugin.WithClock(c)}, pluginOpts...)...)
+	pm.Add(plugins...)
+
+	opts = append([]ManagerOption{WithClock(c), WithEngineConfig(engine)}, opts...)
+	d := &testDispatcher{}
+	m := NewManager(logger, errors, d, pm, opts...)
+	err = m.LoadFlows(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testEnv{manager: m, clock: c, errors: errors, dispatcher: d}
+}
+
+// send hands a new event to the flow synchronously
+func (e *testEnv) send(flowID string, eventType string, attributes map[string]string) *ce.Event {
+	now := e.clock.Now()
+	event := &ce.Event{}
+	event.Set("id", ce.IDFromFingerprint(ce.Fingerprint(attributes)+eventType+now.String()))
+	event.Set("type", eventType)
+	event.Set("time", &now)
+	for k, v := range attributes {
+		event.Set(k, v)
+	}
+
+	e.manager.flow(flowID).Handle(event)
+
+	return event
+}
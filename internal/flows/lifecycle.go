@@ -0,0 +1,70 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+const (
+	// LifecycleEventTypePrefix is the type prefix of events published about event flows,
+	// the full type is hollowtrees.flow.<flow id>.<started|completed|failed>
+	LifecycleEventTypePrefix = "hollowtrees.flow."
+
+	lifecycleStarted = "started"
+)
+
+// publishLifecycleEvent publishes an event about the state of the event flow, it has
+// the attributes of the handled event and keeps its correlation ID
+func (ef *EventFlow) publishLifecycleEvent(state string) {
+	if !ef.flow.manager.Config().LifecycleEvents {
+		return
+	}
+
+	source := url.URL{Path: "/hollowtrees/flows/" + ef.flow.id}
+	e := ef.event.Derive(fmt.Sprintf("%s%s.%s", LifecycleEventTypePrefix, ef.flow.id, state), source, ef.flow.manager.Clock().Now())
+
+	attributes, err := ef.event.Attributes()
+	if err != nil {
+		ef.flow.manager.ErrorHandler().Handle(emperror.WrapWith(err, "could not get event attributes", "flow", ef.flow.id))
+	}
+	for k := range attributes {
+		if k == ce.FingerprintKey || k == ce.HopsKey || k == ce.CorrelationIDKey {
+			continue
+		}
+		if v, ok := ef.event.Get(k); ok {
+			e.Set(k, v)
+		}
+	}
+
+	e.Set("flow_id", ef.flow.id)
+	e.Set("flow_name", ef.flow.name)
+	e.Set("execution_id", ef.ID)
+	e.Set("origin_event_id", ef.event.ID)
+	e.Set("origin_event_type", ef.event.Type)
+	if ef.Error != nil {
+		e.Set("error", ef.Error.Error())
+	}
+
+	err = ef.flow.manager.Publisher().Publish(CEIncomingTopic, e)
+	if err != nil {
+		ef.flow.manager.ErrorHandler().Handle(emperror.WrapWith(err, "could not publish lifecycle event", "flow", ef.flow.id, "type", e.Type))
+	}
+}
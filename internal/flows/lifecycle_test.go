@@ -0,0 +1,138 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+const lifecycleConfig = `
+flowEngine:
+  lifecycleEvents: true
+  maxHops: 3
+flows:
+  drain:
+    name: drain
+    allowedEvents:
+    - alert
+    plugins:
+    - drain
+  notify:
+    name: notify
+    allowedEvents:
+    - hollowtrees.flow.drain.completed
+    - hollowtrees.flow.drain.failed
+    plugins:
+    - notify
+  loop:
+    name: loop
+    allowedEvents:
+    - loop
+    - hollowtrees.flow.loop.completed
+    plugins:
+    - loop
+`
+
+// lifecycleEvents returns the published events of the type
+func lifecycleEvents(env *testEnv, eventType string) []*ce.Event {
+	var events []*ce.Event
+	for _, e := range env.dispatcher.events() {
+		if e.Type == eventType {
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	notify := &testPlugin{name: "notify"}
+	env := newTestEnv(t, lifecycleConfig, drain, notify, &testPlugin{name: "loop"})
+
+	event := env.send("drain", "alert", map[string]string{"instance": "i-1", ce.CorrelationIDKey: "cid"})
+
+	started := lifecycleEvents(env, "hollowtrees.flow.drain.started")
+	completed := lifecycleEvents(env, "hollowtrees.flow.drain.completed")
+	if len(started) != 1 || len(completed) != 1 {
+		t.Fatalf("expected a started and a completed event, got %d and %d", len(started), len(completed))
+	}
+
+	e := completed[0]
+	for k, expected := range map[string]string{
+		"instance":          "i-1",
+		"flow_id":           "drain",
+		"origin_event_id":   event.ID,
+		"origin_event_type": "alert",
+		ce.CorrelationIDKey: "cid",
+	} {
+		if v, _ := e.GetString(k); v != expected {
+			t.Errorf("expected attribute %s to be %q, got %q", k, expected, v)
+		}
+	}
+	if e.Hops() != 1 {
+		t.Errorf("expected the lifecycle event to be one hop away, got %d", e.Hops())
+	}
+
+	// the lifecycle event triggers the chained flow
+	env.manager.flow("notify").Handle(e)
+	if notify.calls() != 1 {
+		t.Fatalf("expected the chained flow to run, got %d calls", notify.calls())
+	}
+	if v, _ := notify.events[0].GetString("instance"); v != "i-1" {
+		t.Fatalf("expected the chained flow to get the attributes of the origin event, got %q", v)
+	}
+}
+
+func TestLifecycleEventOfFailure(t *testing.T) {
+	drain := &testPlugin{name: "drain", err: errors.New("eviction failed")}
+	env := newTestEnv(t, lifecycleConfig, drain, &testPlugin{name: "notify"}, &testPlugin{name: "loop"})
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+
+	failed := lifecycleEvents(env, "hollowtrees.flow.drain.failed")
+	if len(failed) != 1 {
+		t.Fatalf("expected a failed event, got %d", len(failed))
+	}
+	if v, _ := failed[0].GetString("error"); v == "" {
+		t.Fatal("expected the error to be set on the failed event")
+	}
+}
+
+func TestMaxHops(t *testing.T) {
+	loop := &testPlugin{name: "loop"}
+	env := newTestEnv(t, lifecycleConfig, &testPlugin{name: "drain"}, &testPlugin{name: "notify"}, loop)
+
+	// the flow is triggered by its own completion until the hop limit stops it
+	env.send("loop", "loop", nil)
+	for i := 0; i < 10; i++ {
+		completed := lifecycleEvents(env, "hollowtrees.flow.loop.completed")
+		if len(completed) <= i {
+			break
+		}
+		env.manager.flow("loop").Handle(completed[i])
+	}
+
+	if loop.calls() != 4 {
+		t.Fatalf("expected the flow to run for hops 0 to 3, got %d calls", loop.calls())
+	}
+	if env.errors.count() != 1 {
+		t.Fatalf("expected the event exceeding the hop limit to be reported, got %d errors", env.errors.count())
+	}
+}
@@ -15,36 +15,124 @@
 package flows
 
 import (
+	"sync"
+
 	"github.com/goph/emperror"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
 	"github.com/banzaicloud/hollowtrees/internal/platform/log"
 	"github.com/banzaicloud/hollowtrees/internal/plugin"
 )
 
+// DefaultMaxHops is the default limit of derived events, eg. lifecycle events of
+// flows triggered by lifecycle events, before an event is dropped
+const DefaultMaxHops = 10
+
 // FlowManager is used for managing action flows
 type FlowManager interface {
 	Logger() log.Logger
 	ErrorHandler() emperror.Handler
 	Plugins() plugin.PluginManager
+	Recorder() ExecutionRecorder
+	Clock() clock.Clock
+	Publisher() EventPublisher
+	Config() EngineConfig
+	Executions() *Executions
+	Budget() *DisruptionBudget
+	Cooldowns() CooldownStore
+	Tenants() *Tenants
+}
+
+// ExecutionRecorder records event flow executions
+type ExecutionRecorder interface {
+	RecordExecution(record *history.ExecutionRecord)
 }
 
 // Manager describes a FlowManager implementation
 type Manager struct {
 	logger       log.Logger
 	errorHandler emperror.Handler
-	dispatcher   eventSubscriber
+	dispatcher   flowEventDispatcher
 	plugins      plugin.PluginManager
+	recorder     ExecutionRecorder
+	clock        clock.Clock
+	config       EngineConfig
+	executions   *Executions
+	budget       *DisruptionBudget
+	cooldowns    CooldownStore
+	tenants      *Tenants
+
+	mu         sync.RWMutex
+	flows      map[string]*Flow
+	load       sync.Mutex
+	subscribed map[string]bool
+}
+
+// ManagerOption sets configuration on the Manager
+type ManagerOption func(*Manager)
+
+// WithExecutionRecorder sets the recorder of event flow executions
+func WithExecutionRecorder(recorder ExecutionRecorder) ManagerOption {
+	return func(m *Manager) {
+		m.recorder = recorder
+	}
+}
+
+// WithCooldownStore sets the store persisting the cooldowns of event flows
+func WithCooldownStore(store CooldownStore) ManagerOption {
+	return func(m *Manager) {
+		m.cooldowns = store
+	}
+}
+
+// WithClock sets the clock used for timing event flows
+func WithClock(clock clock.Clock) ManagerOption {
+	return func(m *Manager) {
+		m.clock = clock
+	}
+}
+
+// WithEngineConfig sets the configuration common to every action flow
+func WithEngineConfig(config EngineConfig) ManagerOption {
+	return func(m *Manager) {
+		m.config = config
+	}
 }
 
 // NewManager returns an initialized FlowManager implementation
-func NewManager(logger log.Logger, errorHandler emperror.Handler, dispatcher flowEventDispatcher, plugins plugin.PluginManager) *Manager {
-	return &Manager{
+func NewManager(logger log.Logger, errorHandler emperror.Handler, dispatcher flowEventDispatcher, plugins plugin.PluginManager, opts ...ManagerOption) *Manager {
+	m := &Manager{
 		logger:       logger,
 		errorHandler: errorHandler,
 		dispatcher:   dispatcher,
 		plugins:      plugins,
+		recorder:     nopRecorder{},
+		cooldowns:    nopCooldownStore{},
+		clock:        clock.New(),
+		executions:   NewExecutions(),
+		subscribed:   make(map[string]bool),
+		config: EngineConfig{
+			LifecycleEvents: true,
+			MaxHops:         DefaultMaxHops,
+		},
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	if m.config.DisruptionBudget.Enabled {
+		m.budget = NewDisruptionBudget(m.config.DisruptionBudget, m.clock)
+	}
+
+	if len(m.config.Tenancy.Tenants) > 0 {
+		m.tenants = NewTenants(m.config.Tenancy)
 	}
+
+	return m
 }
 
 // Logger returns the logger
@@ -62,36 +150,193 @@ func (m *Manager) Plugins() plugin.PluginManager {
 	return m.plugins
 }
 
-// LoadFlows loads flow definitions from config,  initializes Flows
-// and subscribes them to the event dispatcher
+// Recorder returns the event flow execution recorder
+func (m *Manager) Recorder() ExecutionRecorder {
+	return m.recorder
+}
+
+// Clock returns the clock used for timing event flows
+func (m *Manager) Clock() clock.Clock {
+	return m.clock
+}
+
+// Publisher returns the publisher of events created by action flows
+func (m *Manager) Publisher() EventPublisher {
+	return m.dispatcher
+}
+
+// Config returns the configuration common to every action flow
+func (m *Manager) Config() EngineConfig {
+	return m.config
+}
+
+// Executions returns the running event flows
+func (m *Manager) Executions() *Executions {
+	return m.executions
+}
+
+// Budget returns the disruption budget, nil if disabled
+func (m *Manager) Budget() *DisruptionBudget {
+	return m.budget
+}
+
+// Cooldowns returns the store persisting the cooldowns of event flows
+func (m *Manager) Cooldowns() CooldownStore {
+	return m.cooldowns
+}
+
+// Tenants returns the tenants the flows are scoped to, nil if there are none
+func (m *Manager) Tenants() *Tenants {
+	return m.tenants
+}
+
+// LoadFlows loads flow definitions from config, initializes Flows and subscribes them to the
+// event dispatcher, calling it again replaces the flows if every flow definition is valid
 func (m *Manager) LoadFlows(v *viper.Viper) error {
-	var flows FlowConfigs
+	m.load.Lock()
+	defer m.load.Unlock()
+
+	var configs FlowConfigs
 
-	err := viper.UnmarshalKey("flows", &flows)
+	err := v.UnmarshalKey("flows", &configs)
 	if err != nil {
 		return emperror.Wrap(err, "could not unmarshal flow configs")
 	}
 
-	for id, config := range flows {
+	now := m.clock.Now()
+	cooldowns, err := m.cooldowns.Cooldowns(now)
+	if err != nil {
+		return emperror.Wrap(err, "could not load cooldowns")
+	}
+
+	flows := make(map[string]*Flow, len(configs))
+	for id, config := range configs {
 		err := config.Validate(m.plugins, id)
 		if err != nil {
 			return emperror.WrapWith(err, "could not load flow", "flow", id)
 		}
 
-		f := NewFlow(m, NewInMemFlowStore(), id, config.Name,
+		for _, t := range config.Tenants {
+			if !m.config.Tenancy.HasTenant(t) {
+				return emperror.WrapWith(emperror.With(errors.New("unknown tenant"), "tenant", t), "could not load flow", "flow", id)
+			}
+		}
+
+		steps, err := config.GetSteps()
+		if err != nil {
+			return emperror.WrapWith(err, "could not load flow", "flow", id)
+		}
+
+		// reloaded flows keep the event flows in progress or cooling down
+		previous := m.flow(id)
+		store := FlowStore(NewInMemFlowStore())
+		if previous != nil {
+			store = previous.cache
+		}
+
+		f := NewFlow(m, store, id, config.Name,
 			Description(config.Description),
 			AllowedEvents(config.AllowedEvents),
 			Cooldown(config.Cooldown),
 			GroupBy(config.GroupBy),
-			Plugins(config.Plugins),
+			Steps(steps),
 			Filters(config.Filters),
+			UnavailablePolicy(config.OnPluginUnavailable),
+			DeferTimeout(config.DeferTimeout),
+			RateLimit(config.RateLimit),
+			Aggregate(config.Aggregate),
+			Delay(config.Delay),
+			CancelEvents(config.CancelEvents),
+			ActiveWindows(config.ActiveWindows),
+			WindowPolicy(config.WindowPolicy),
+			Disruptive(config.Disruptive),
+			TenantScope(config.Tenants),
 		)
 
-		err = m.dispatcher.SubscribeAsync(CEIncomingTopic, f)
+		if previous == nil {
+			err = f.restoreCooldowns(cooldowns, now)
+			if err != nil {
+				return emperror.WrapWith(err, "could not load flow", "flow", id)
+			}
+		}
+
+		flows[id] = f
+	}
+
+	// the state of the previous flows is taken over only once every flow is valid
+	for id, f := range flows {
+		if previous := m.flow(id); previous != nil {
+			f.takeOver(previous)
+		}
+	}
+
+	m.mu.Lock()
+	m.flows = flows
+	m.mu.Unlock()
+
+	// the disruption budget learns the nodes of the clusters from every event
+	if m.budget != nil && !m.subscribed[budgetSubscription] {
+		err = m.dispatcher.SubscribeAsync(CEIncomingTopic, m.budget)
+		if err != nil {
+			return emperror.Wrap(err, "could not subscribe disruption budget to event dispatcher")
+		}
+		m.subscribed[budgetSubscription] = true
+	}
+
+	// flows are subscribed by their ID once, events are handled by the flow loaded last
+	for id := range flows {
+		if m.subscribed[id] {
+			continue
+		}
+
+		err = m.dispatcher.SubscribeAsync(CEIncomingTopic, flowSubscription{manager: m, id: id})
 		if err != nil {
 			m.errorHandler.Handle(emperror.WrapWith(err, "could not subscribe to event dispatcher", "flow", id))
+			continue
 		}
+		m.subscribed[id] = true
 	}
 
 	return nil
 }
+
+// flow returns the loaded flow by its ID, nil if there is no such flow
+func (m *Manager) flow(id string) *Flow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.flows[id]
+}
+
+// loadedFlows returns the loaded flows
+func (m *Manager) loadedFlows() []*Flow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	flows := make([]*Flow, 0, len(m.flows))
+	for _, f := range m.flows {
+		flows = append(flows, f)
+	}
+
+	return flows
+}
+
+// budgetSubscription marks the subscription of the disruption budget, it is not a valid flow ID
+const budgetSubscription = ""
+
+// flowSubscription hands the events to the flow currently loaded with the ID,
+// events of flows removed by a reload are dropped
+type flowSubscription struct {
+	manager *Manager
+	id      string
+}
+
+func (s flowSubscription) Handle(event interface{}) {
+	if f := s.manager.flow(s.id); f != nil {
+		f.Handle(event)
+	}
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) RecordExecution(*history.ExecutionRecord) {}
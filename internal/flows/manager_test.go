@@ -0,0 +1,103 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const reloadConfig = `
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+    cancelEvents: [node.ready]
+    groupBy: [instance]
+    delay: 2m
+    rateLimit:
+      maxExecutions: 1
+      window: 1h
+  scale:
+    name: scale
+    plugins: [scale]
+    aggregate:
+      count: 2
+      window: 10m
+`
+
+func TestReloadKeepsState(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	scale := &testPlugin{name: "scale"}
+	env := newTestEnv(t, reloadConfig, drain, scale)
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	env.clock.Advance(2 * time.Minute)
+	if drain.calls() != 1 {
+		t.Fatalf("expected 1 plugin call, got %d", drain.calls())
+	}
+
+	env.send("drain", "alert", map[string]string{"instance": "i-2"})
+	env.send("scale", "alert", nil)
+
+	err := env.manager.LoadFlows(readConfig(t, strings.Replace(reloadConfig, "name: drain", "name: drain nodes", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pending event flows are cancelled through the reloaded flow
+	env.send("drain", "node.ready", map[string]string{"instance": "i-2"})
+	env.clock.Advance(0)
+	if len(env.manager.Executions().List()) != 0 {
+		t.Fatal("expected the pending event flow to be cancelled")
+	}
+
+	// the rate limit counts the executions before the reload
+	env.send("drain", "alert", map[string]string{"instance": "i-3"})
+	env.clock.Advance(2 * time.Minute)
+	if drain.calls() != 1 {
+		t.Fatal("expected the event flow to be queued by the rate limit")
+	}
+	if status := env.manager.flow("drain").limiter.status(); status.Waiting != 1 {
+		t.Fatalf("unexpected rate limit status: %+v", status)
+	}
+
+	// the events buffered before the reload are aggregated
+	env.send("scale", "alert", nil)
+	if scale.calls() != 1 {
+		t.Fatalf("expected the buffered events to be aggregated, got %d plugin calls", scale.calls())
+	}
+}
+
+func TestRejectedReloadKeepsFlows(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	env := newTestEnv(t, reloadConfig, drain, &testPlugin{name: "scale"})
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+
+	err := env.manager.LoadFlows(readConfig(t, strings.Replace(reloadConfig, "count: 2", "count: -1", 1)))
+	if err == nil {
+		t.Fatal("expected an invalid flow to be rejected")
+	}
+	if env.manager.flow("drain").name != "drain" {
+		t.Fatal("expected the previous flows to be kept")
+	}
+
+	env.clock.Advance(2 * time.Minute)
+	if drain.calls() != 1 {
+		t.Fatalf("expected 1 plugin call, got %d", drain.calls())
+	}
+}
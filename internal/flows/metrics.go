@@ -0,0 +1,131 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nolint: gochecknoglobals
+var (
+	runningExecutionsDesc = prometheus.NewDesc(
+		"hollowtrees_flow_running_executions",
+		"Event flows being executed, not counting the ones queued by rate limits.",
+		[]string{"flow"}, nil,
+	)
+	queuedExecutionsDesc = prometheus.NewDesc(
+		"hollowtrees_flow_queued_executions",
+		"Event flows waiting for the rate limits of the flow.",
+		[]string{"flow"}, nil,
+	)
+	rateLimitedDesc = prometheus.NewDesc(
+		"hollowtrees_flow_rate_limited_total",
+		"Event flows exceeding the rate limits of the flow by outcome: queued or dropped.",
+		[]string{"flow", "outcome"}, nil,
+	)
+	budgetBlockedDesc = prometheus.NewDesc(
+		"hollowtrees_disruption_budget_blocked_total",
+		"Disruptive event flows blocked by the disruption budget.",
+		[]string{"flow"}, nil,
+	)
+	budgetDisruptionsDesc = prometheus.NewDesc(
+		"hollowtrees_disruption_budget_disruptions",
+		"Disruptive event flows started within the budget window in a cluster.",
+		[]string{"cluster"}, nil,
+	)
+	budgetLimitDesc = prometheus.NewDesc(
+		"hollowtrees_disruption_budget_limit",
+		"Disruptive event flows allowed within the budget window in a cluster, -1 if unlimited.",
+		[]string{"cluster"}, nil,
+	)
+	tenantDeniedDesc = prometheus.NewDesc(
+		"hollowtrees_tenant_denied_total",
+		"Events not handled by the flow because their tenant is not allowed to use it.",
+		[]string{"flow"}, nil,
+	)
+	tenantQueuedExecutionsDesc = prometheus.NewDesc(
+		"hollowtrees_tenant_queued_executions",
+		"Event flows waiting for the rate limits of the tenant.",
+		[]string{"tenant"}, nil,
+	)
+	tenantRateLimitedDesc = prometheus.NewDesc(
+		"hollowtrees_tenant_rate_limited_total",
+		"Event flows exceeding the rate limits of the tenant by outcome: queued or dropped.",
+		[]string{"tenant", "outcome"}, nil,
+	)
+)
+
+// Describe implements prometheus.Collector
+func (m *Manager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- runningExecutionsDesc
+	ch <- queuedExecutionsDesc
+	ch <- rateLimitedDesc
+	ch <- budgetBlockedDesc
+	ch <- budgetDisruptionsDesc
+	ch <- budgetLimitDesc
+	ch <- tenantDeniedDesc
+	ch <- tenantQueuedExecutionsDesc
+	ch <- tenantRateLimitedDesc
+}
+
+// Collect implements prometheus.Collector, it reports the running event flows, the rate limits,
+// the disruption budget and the tenants
+func (m *Manager) Collect(ch chan<- prometheus.Metric) {
+	running := make(map[string]int)
+	for _, s := range m.executions.List() {
+		if s.Status != EventFlowQueued {
+			running[s.FlowID]++
+		}
+	}
+
+	for _, f := range m.loadedFlows() {
+		ch <- prometheus.MustNewConstMetric(runningExecutionsDesc, prometheus.GaugeValue, float64(running[f.id]), f.id)
+
+		if f.disruptive && m.budget != nil {
+			ch <- prometheus.MustNewConstMetric(budgetBlockedDesc, prometheus.CounterValue, float64(m.budget.blockedCount(f.id)), f.id)
+		}
+
+		if m.tenants != nil {
+			ch <- prometheus.MustNewConstMetric(tenantDeniedDesc, prometheus.CounterValue, float64(m.tenants.deniedCount(f.id)), f.id)
+		}
+
+		if f.limiter == nil {
+			continue
+		}
+
+		s := f.limiter.status()
+		ch <- prometheus.MustNewConstMetric(queuedExecutionsDesc, prometheus.GaugeValue, float64(s.Waiting), f.id)
+		ch <- prometheus.MustNewConstMetric(rateLimitedDesc, prometheus.CounterValue, float64(s.Queued), f.id, "queued")
+		ch <- prometheus.MustNewConstMetric(rateLimitedDesc, prometheus.CounterValue, float64(s.Dropped), f.id, "dropped")
+	}
+
+	if m.budget != nil {
+		for _, s := range m.budget.statuses() {
+			ch <- prometheus.MustNewConstMetric(budgetDisruptionsDesc, prometheus.GaugeValue, float64(s.Disruptions), s.Cluster)
+			ch <- prometheus.MustNewConstMetric(budgetLimitDesc, prometheus.GaugeValue, float64(s.Limit), s.Cluster)
+		}
+	}
+
+	if m.tenants == nil {
+		return
+	}
+
+	for name, l := range m.tenants.limiters {
+		s := l.status()
+		ch <- prometheus.MustNewConstMetric(tenantQueuedExecutionsDesc, prometheus.GaugeValue, float64(s.Waiting), name)
+		ch <- prometheus.MustNewConstMetric(tenantRateLimitedDesc, prometheus.CounterValue, float64(s.Queued), name, "queued")
+		ch <- prometheus.MustNewConstMetric(tenantRateLimitedDesc, prometheus.CounterValue, float64(s.Dropped), name, "dropped")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+const operationConfig = `
+flows:
+  scale:
+    name: scale
+    steps:
+    - name: scale
+      plugin: scale
+      timeout: 2m
+    - plugin: notify
+      params:
+        status: "{{ .steps.scale.status }}/{{ .steps.scale.result }}"
+  parallel:
+    name: parallel
+    steps:
+    - parallel:
+      - plugin: scale
+      - plugin: report
+    - plugin: notify
+`
+
+// operationPlugin starts a long-running operation for every event, it can be polled for its state
+type operationPlugin struct {
+	testPlugin
+
+	mu       sync.Mutex
+	finished bool
+}
+
+func (p *operationPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	_, err := p.testPlugin.Handle(ctx, event, params)
+
+	return &plugin.Result{OperationID: "op-1"}, err
+}
+
+func (p *operationPlugin) GetStatus(ctx context.Context, operationID string) (*plugin.OperationStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.finished {
+		return &plugin.OperationStatus{State: plugin.OperationRunning}, nil
+	}
+
+	return &plugin.OperationStatus{State: plugin.OperationSucceeded, Status: "scaled"}, nil
+}
+
+func (p *operationPlugin) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.finished = true
+}
+
+func TestOperationParksEventFlow(t *testing.T) {
+	scale := &operationPlugin{testPlugin: testPlugin{name: "scale"}}
+	notify := &testPlugin{name: "notify"}
+	env := newTestEnv(t, operationConfig, scale, notify, &testPlugin{name: "report"})
+
+	// the event flow is parked on the timers of the operation instead of waiting in the dispatcher
+	env.send("scale", "alert", nil)
+	if scale.calls() != 1 || notify.calls() != 0 {
+		t.Fatal("expected the event flow to wait for the operation")
+	}
+	if s := env.manager.Executions().List(); len(s) != 1 || s[0].Status != EventFlowInProgress || s[0].Plugin != "scale" {
+		t.Fatalf("expected the execution to wait for the scale plugin, got %+v", s)
+	}
+
+	env.clock.Advance(10 * time.Second)
+	if notify.calls() != 0 {
+		t.Fatal("expected the event flow to wait while the operation is running")
+	}
+
+	scale.finish()
+	env.clock.Advance(10 * time.Second)
+	if notify.calls() != 1 {
+		t.Fatalf("expected the following step to run once the operation finished, got %d calls", notify.calls())
+	}
+	if status := notify.params[0]["status"]; status != "completed/scaled" {
+		t.Fatalf("expected the state of the operation as result of the step, got %q", status)
+	}
+	if len(env.manager.Executions().List()) != 0 || env.clock.pending() != 0 {
+		t.Fatal("expected the event flow to finish")
+	}
+}
+
+func TestOperationStepTimeout(t *testing.T) {
+	scale := &operationPlugin{testPlugin: testPlugin{name: "scale"}}
+	notify := &testPlugin{name: "notify"}
+	env := newTestEnv(t, operationConfig, scale, notify, &testPlugin{name: "report"})
+
+	env.send("scale", "alert", nil)
+	env.clock.Advance(2 * time.Minute)
+
+	if notify.calls() != 1 {
+		t.Fatalf("expected the event flow to continue at the step timeout, got %d calls", notify.calls())
+	}
+	if status := notify.params[0]["status"]; status != "failed/" {
+		t.Fatalf("expected the step to fail, got %q", status)
+	}
+	if env.errors.count() != 1 || env.clock.pending() != 0 {
+		t.Fatalf("expected the operation to time out, got %d errors and %d timers", env.errors.count(), env.clock.pending())
+	}
+}
+
+func TestOperationInParallelStep(t *testing.T) {
+	scale := &operationPlugin{testPlugin: testPlugin{name: "scale"}}
+	report := &testPlugin{name: "report"}
+	notify := &testPlugin{name: "notify"}
+	env := newTestEnv(t, operationConfig, scale, notify, report)
+
+	env.send("parallel", "alert", nil)
+	if scale.calls() != 1 || report.calls() != 1 || notify.calls() != 0 {
+		t.Fatal("expected the parallel steps to run and the event flow to wait for the operation")
+	}
+
+	scale.finish()
+	env.clock.Advance(10 * time.Second)
+	if notify.calls() != 1 {
+		t.Fatalf("expected the event flow to continue once every parallel step finished, got %d calls", notify.calls())
+	}
+}
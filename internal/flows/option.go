@@ -14,7 +14,10 @@
 
 package flows
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Option sets configuration on the Flow
 type Option interface {
@@ -42,11 +45,21 @@ func (o GroupBy) apply(f *Flow) {
 	f.groupBy = []string(o)
 }
 
-// Plugins defines the plugins to execute in an event flow
+// Plugins defines the plugins to execute in an event flow without parameters
 type Plugins []string
 
 func (o Plugins) apply(f *Flow) {
-	f.plugins = []string(o)
+	f.steps = make([]Step, 0, len(o))
+	for _, name := range o {
+		f.steps = append(f.steps, Step{plugin: name})
+	}
+}
+
+// Steps defines the plugin calls with parameters to execute in an event flow
+type Steps []Step
+
+func (o Steps) apply(f *Flow) {
+	f.steps = []Step(o)
 }
 
 // Filters defines simple filter on event values
@@ -62,3 +75,85 @@ type Description string
 func (o Description) apply(f *Flow) {
 	f.description = string(o)
 }
+
+// UnavailablePolicy defines what happens when a plugin of the flow is unavailable: fail or defer
+type UnavailablePolicy string
+
+func (o UnavailablePolicy) apply(f *Flow) {
+	f.unavailablePolicy = string(o)
+}
+
+// DeferTimeout is the maximum time an event flow waits for unavailable plugins with the defer policy
+type DeferTimeout time.Duration
+
+func (o DeferTimeout) apply(f *Flow) {
+	f.deferTimeout = time.Duration(o)
+}
+
+// Disruptive marks the flow disruptive, its event flows are limited by the disruption budget
+type Disruptive bool
+
+func (o Disruptive) apply(f *Flow) {
+	f.disruptive = bool(o)
+}
+
+// TenantScope restricts the flow to the events of the tenants, the flow handles the events of any tenant if empty
+type TenantScope []string
+
+func (o TenantScope) apply(f *Flow) {
+	f.tenants = make([]string, 0, len(o))
+	for _, t := range o {
+		f.tenants = append(f.tenants, strings.ToLower(t))
+	}
+}
+
+// Aggregate buffers the events of the flow until enough of them arrive within a window
+type Aggregate AggregateConfig
+
+func (o Aggregate) apply(f *Flow) {
+	if AggregateConfig(o).enabled() {
+		f.aggregator = newAggregator(AggregateConfig(o))
+	}
+}
+
+// Delay is the time an event flow is pending before executing, cancel events arriving meanwhile cancel it
+type Delay time.Duration
+
+func (o Delay) apply(f *Flow) {
+	f.delay = time.Duration(o)
+}
+
+// CancelEvents defines event types cancelling the pending event flows with the same group attributes
+type CancelEvents []string
+
+func (o CancelEvents) apply(f *Flow) {
+	f.cancelEvents = []string(o)
+}
+
+// ActiveWindows restricts the event flows to time windows, invalid windows are ignored
+type ActiveWindows []ActiveWindowConfig
+
+func (o ActiveWindows) apply(f *Flow) {
+	f.activeWindows = nil
+	for _, c := range o {
+		if w, err := newActiveWindow(c); err == nil {
+			f.activeWindows = append(f.activeWindows, w)
+		}
+	}
+}
+
+// WindowPolicy defines what happens to events arriving outside the active windows: defer or drop
+type WindowPolicy string
+
+func (o WindowPolicy) apply(f *Flow) {
+	f.windowPolicy = string(o)
+}
+
+// RateLimit limits the concurrency and the start rate of the event flows across group keys
+type RateLimit RateLimitConfig
+
+func (o RateLimit) apply(f *Flow) {
+	if RateLimitConfig(o).enabled() {
+		f.limiter = newRateLimiter(RateLimitConfig(o))
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RateLimitQueue makes event flows exceeding the rate limits wait
+	RateLimitQueue = "queue"
+	// RateLimitDrop drops event flows exceeding the rate limits
+	RateLimitDrop = "drop"
+
+	rateLimitCheckInterval = time.Second
+)
+
+// ErrRateLimited is returned for event flows dropped by the rate limits of their flow
+var ErrRateLimited = errors.New("event flow dropped by rate limit") // nolint: gochecknoglobals
+
+// RateLimitConfig holds configuration values limiting the event flows of an action flow across group keys
+type RateLimitConfig struct {
+	// Event flows executing at the same time, unlimited if 0
+	MaxConcurrent int `mapstructure:"maxConcurrent"`
+
+	// Event flows started within the window, unlimited if 0
+	MaxExecutions int           `mapstructure:"maxExecutions"`
+	Window        time.Duration `mapstructure:"window"`
+
+	// Event attribute whose values are limited separately, eg. cluster_id, the limits are flow wide if empty
+	Key string `mapstructure:"key"`
+
+	// Event flows exceeding the limits are queued or dropped
+	Policy string `mapstructure:"policy"`
+
+	// Queued event flows are dropped after waiting this long, no limit if 0
+	QueueTimeout time.Duration `mapstructure:"queueTimeout"`
+}
+
+// Validate validates the rate limit configuration
+func (c RateLimitConfig) Validate() error {
+	if c.MaxConcurrent < 0 || c.MaxExecutions < 0 {
+		return errors.New("rate limits must not be negative")
+	}
+
+	if c.MaxExecutions > 0 && c.Window <= 0 {
+		return errors.New("window must be positive if maxExecutions is set")
+	}
+
+	switch c.Policy {
+	case "", RateLimitQueue, RateLimitDrop:
+	default:
+		return emperror.With(errors.New("invalid rate limit policy"), "policy", c.Policy)
+	}
+
+	if c.QueueTimeout < 0 {
+		return errors.New("queue timeout must not be negative")
+	}
+
+	return nil
+}
+
+func (c RateLimitConfig) enabled() bool {
+	return c.MaxConcurrent > 0 || c.MaxExecutions > 0
+}
+
+// rateLimiter limits the concurrency and the start rate of the event flows of a flow per limit key
+type rateLimiter struct {
+	config RateLimitConfig
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+	waiting int
+	queued  uint64
+	dropped uint64
+}
+
+type rateBucket struct {
+	running int
+	starts  []time.Time
+}
+
+// rateLimitStatus describes the state of the rate limiter of a flow
+type rateLimitStatus struct {
+	// Event flows waiting for the limits
+	Waiting int
+	// Event flows queued or dropped because of the limits
+	Queued  uint64
+	Dropped uint64
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:  config,
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// reconfigure applies the configuration of the reloaded flow, the event flows
+// running or started within the window keep counting against the limits
+func (l *rateLimiter) reconfigure(config RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+}
+
+func (l *rateLimiter) getConfig() RateLimitConfig {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.config
+}
+
+// tryAcquire starts an event flow of the key if the limits allow it
+func (l *rateLimiter) tryAcquire(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{}
+		l.buckets[key] = b
+	}
+
+	cut := now.Add(-l.config.Window)
+	i := 0
+	for i < len(b.starts) && !b.starts[i].After(cut) {
+		i++
+	}
+	b.starts = b.starts[i:]
+
+	if l.config.MaxConcurrent > 0 && b.running >= l.config.MaxConcurrent {
+		return false
+	}
+	if l.config.MaxExecutions > 0 && len(b.starts) >= l.config.MaxExecutions {
+		return false
+	}
+
+	b.running++
+	if l.config.MaxExecutions > 0 {
+		b.starts = append(b.starts, now)
+	}
+
+	return true
+}
+
+func (l *rateLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return
+	}
+
+	b.running--
+	if b.running <= 0 && len(b.starts) == 0 {
+		delete(l.buckets, key)
+	}
+}
+
+// wait counts an event flow starting or finishing to wait for the limits
+func (l *rateLimiter) wait(start bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if start {
+		l.waiting++
+		l.queued++
+	} else {
+		l.waiting--
+	}
+}
+
+func (l *rateLimiter) drop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.dropped++
+}
+
+func (l *rateLimiter) status() rateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return rateLimitStatus{
+		Waiting: l.waiting,
+		Queued:  l.queued,
+		Dropped: l.dropped,
+	}
+}
+
+// acquire checks the rate limits of the flow, it returns false if the event flow does not proceed:
+// it is dropped, or with the queue policy it is parked until the limits are checked again
+func (ef *EventFlow) acquire() (proceed bool, dropped bool) {
+	l := ef.flow.limiter
+	if l == nil {
+		return true, false
+	}
+
+	return ef.acquireLimit(l)
+}
+
+// acquireLimit checks the limits of the rate limiter, queued event flows are parked on a timer instead
+// of blocking the event dispatcher, the concurrency slot of the event flow is released when it finishes
+func (ef *EventFlow) acquireLimit(l *rateLimiter) (proceed bool, dropped bool) {
+	if ef.held[l] {
+		return true, false
+	}
+
+	config := l.getConfig()
+
+	key := ""
+	if config.Key != "" {
+		key, _ = ef.event.GetString(config.Key)
+	}
+
+	now := ef.flow.manager.Clock().Now()
+	deadline, queued := ef.queued[l]
+	if l.tryAcquire(key, now) {
+		if queued {
+			l.wait(false)
+			delete(ef.queued, l)
+		}
+
+		var once sync.Once
+		ef.releases = append(ef.releases, func() {
+			once.Do(func() { l.release(key) })
+		})
+		if ef.held == nil {
+			ef.held = make(map[*rateLimiter]bool)
+		}
+		ef.held[l] = true
+
+		return true, false
+	}
+
+	if config.Policy == RateLimitDrop {
+		l.drop()
+		return false, true
+	}
+
+	if !queued {
+		ef.setStatus(EventFlowQueued)
+		l.wait(true)
+
+		if config.QueueTimeout > 0 {
+			deadline = now.Add(config.QueueTimeout)
+		}
+		if ef.queued == nil {
+			ef.queued = make(map[*rateLimiter]time.Time)
+		}
+		ef.queued[l] = deadline
+	}
+
+	interval := rateLimitCheckInterval
+	if !deadline.IsZero() {
+		remaining := deadline.Sub(now)
+		if remaining <= 0 {
+			l.wait(false)
+			delete(ef.queued, l)
+			l.drop()
+			return false, true
+		}
+		if remaining < interval {
+			interval = remaining
+		}
+	}
+
+	ef.park(interval, ef.admit)
+
+	return false, false
+}
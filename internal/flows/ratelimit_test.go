@@ -0,0 +1,124 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"testing"
+	"time"
+)
+
+const rateLimitConfig = `
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+    groupBy: [instance]
+    rateLimit:
+      maxExecutions: 1
+      window: 1h
+      policy: queue
+  scale:
+    name: scale
+    plugins: [scale]
+    groupBy: [instance]
+    rateLimit:
+      maxExecutions: 1
+      window: 1h
+      policy: queue
+      queueTimeout: 10m
+  report:
+    name: report
+    plugins: [report]
+    groupBy: [instance]
+    rateLimit:
+      maxExecutions: 1
+      window: 1h
+      policy: drop
+`
+
+func newRateLimitTestEnv(t *testing.T) (*testEnv, map[string]*testPlugin) {
+	plugins := map[string]*testPlugin{
+		"drain":  {name: "drain"},
+		"scale":  {name: "scale"},
+		"report": {name: "report"},
+	}
+
+	return newTestEnv(t, rateLimitConfig, plugins["drain"], plugins["scale"], plugins["report"]), plugins
+}
+
+func TestRateLimitQueue(t *testing.T) {
+	env, plugins := newRateLimitTestEnv(t)
+	drain := plugins["drain"]
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	env.send("drain", "alert", map[string]string{"instance": "i-2"})
+	if drain.calls() != 1 {
+		t.Fatalf("expected 1 plugin call within the rate limit, got %d", drain.calls())
+	}
+	if env.clock.pending() != 1 {
+		t.Fatalf("expected the queued event flow to be parked on a timer, got %d timers", env.clock.pending())
+	}
+	if status := env.manager.flow("drain").limiter.status(); status.Waiting != 1 || status.Queued != 1 {
+		t.Fatalf("unexpected rate limit status: %+v", status)
+	}
+
+	// the queued event flow stays in the flow store, re-sent alerts do not start another one
+	env.clock.Advance(30 * time.Minute)
+	env.send("drain", "alert", map[string]string{"instance": "i-2"})
+	if env.clock.pending() != 1 {
+		t.Fatalf("expected a single queued event flow, got %d timers", env.clock.pending())
+	}
+
+	env.clock.Advance(30 * time.Minute)
+	if drain.calls() != 2 {
+		t.Fatalf("expected the queued event flow to run once the window passed, got %d plugin calls", drain.calls())
+	}
+	if status := env.manager.flow("drain").limiter.status(); status.Waiting != 0 {
+		t.Fatalf("unexpected rate limit status: %+v", status)
+	}
+	if env.clock.pending() != 0 || env.errors.count() != 0 {
+		t.Fatal("expected the queued event flow to finish")
+	}
+}
+
+func TestRateLimitQueueTimeout(t *testing.T) {
+	env, plugins := newRateLimitTestEnv(t)
+	scale := plugins["scale"]
+
+	env.send("scale", "alert", map[string]string{"instance": "i-1"})
+	env.send("scale", "alert", map[string]string{"instance": "i-2"})
+
+	env.clock.Advance(10 * time.Minute)
+	if scale.calls() != 1 || env.errors.count() != 1 {
+		t.Fatal("expected the queued event flow to be dropped after the queue timeout")
+	}
+	if status := env.manager.flow("scale").limiter.status(); status.Waiting != 0 || status.Dropped != 1 {
+		t.Fatalf("unexpected rate limit status: %+v", status)
+	}
+	if env.clock.pending() != 0 {
+		t.Fatalf("expected no parked event flows, got %d timers", env.clock.pending())
+	}
+}
+
+func TestRateLimitDrop(t *testing.T) {
+	env, plugins := newRateLimitTestEnv(t)
+	report := plugins["report"]
+
+	env.send("report", "alert", map[string]string{"instance": "i-1"})
+	env.send("report", "alert", map[string]string{"instance": "i-2"})
+	if report.calls() != 1 || env.errors.count() != 1 || env.clock.pending() != 0 {
+		t.Fatal("expected the event flow exceeding the rate limit to be dropped")
+	}
+}
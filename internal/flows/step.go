@@ -0,0 +1,155 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// Step is a plugin call of an action flow with its parameter templates, or a group of steps
+// running in parallel; it runs if its condition holds and has steps to run if it fails
+type Step struct {
+	name    string
+	plugin  string
+	params  map[string]*template.Template
+	timeout time.Duration
+
+	when      *template.Template
+	parallel  []Step
+	onFailure []Step
+}
+
+// NewStep returns a Step with its parameters compiled to templates, non-string
+// parameter values are used in their default string format
+func NewStep(pluginName string, params map[string]interface{}) (Step, error) {
+	s := Step{
+		plugin: pluginName,
+		params: make(map[string]*template.Template, len(params)),
+	}
+
+	for name, value := range params {
+		t, err := template.New(name).Option("missingkey=error").Parse(fmt.Sprint(value))
+		if err != nil {
+			return s, emperror.WrapWith(err, "could not parse parameter template", "plugin", pluginName, "param", name)
+		}
+		s.params[name] = t
+	}
+
+	return s, nil
+}
+
+// Plugin returns the name of the plugin to call
+func (s Step) Plugin() string {
+	return s.plugin
+}
+
+// Name returns the name the results of the step are referenced by, the plugin name if not set
+func (s Step) Name() string {
+	if s.name == "" {
+		return s.plugin
+	}
+
+	return s.name
+}
+
+// evaluate renders the condition of the step, steps without a condition always run
+func (s Step) evaluate(data map[string]interface{}) (bool, error) {
+	if s.when == nil {
+		return true, nil
+	}
+
+	var buf bytes.Buffer
+	err := s.when.Execute(&buf, data)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not evaluate condition", "step", s.Name())
+	}
+
+	v := strings.TrimSpace(buf.String())
+	if v == "" {
+		return false, nil
+	}
+
+	run, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, emperror.With(errors.New("condition must render a boolean"), "step", s.Name(), "value", v)
+	}
+
+	return run, nil
+}
+
+// render renders the parameter templates of the step over the template data
+func (s Step) render(data map[string]interface{}) (plugin.Params, error) {
+	if len(s.params) == 0 {
+		return nil, nil
+	}
+
+	params := make(plugin.Params, len(s.params))
+	for name, t := range s.params {
+		var buf bytes.Buffer
+		err := t.Execute(&buf, data)
+		if err != nil {
+			return nil, emperror.WrapWith(err, "could not render parameter", "plugin", s.plugin, "param", name)
+		}
+		params[name] = buf.String()
+	}
+
+	return params, nil
+}
+
+// templateData returns the values available in parameter templates and conditions: the event
+// attributes (eg. `.labels.instance`) and the standard CloudEvent properties
+func templateData(event *ce.Event) (map[string]interface{}, error) {
+	data, err := event.Attributes()
+	if err != nil {
+		return nil, err
+	}
+
+	data["specversion"] = event.SpecVersion
+	data["type"] = event.Type
+	data["source"] = event.Source.String()
+	data["id"] = event.ID
+	data["time"] = ""
+	if event.Time != nil {
+		data["time"] = event.Time.Format(time.RFC3339)
+	}
+	data["data"] = event.Data
+
+	return data, nil
+}
+
+// pluginNames returns the names of the plugins called by the steps and their branches
+func pluginNames(steps []Step) []string {
+	var names []string
+	for _, s := range steps {
+		if s.plugin != "" {
+			names = append(names, s.plugin)
+		}
+		names = append(names, pluginNames(s.parallel)...)
+		names = append(names, pluginNames(s.onFailure)...)
+	}
+
+	return names
+}
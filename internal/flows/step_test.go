@@ -0,0 +1,111 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const paramsConfig = `
+flows:
+  drain:
+    name: drain
+    cooldown: 1h
+    steps:
+    - plugin: drain
+      params:
+        node: "{{ .instance }}"
+        reason: "{{ .type }} in cluster {{ .cluster_id }}"
+        grace: 30
+    - plugin: notify
+`
+
+func TestStepParams(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	notify := &testPlugin{name: "notify"}
+	env := newTestEnv(t, paramsConfig, drain, notify)
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1", "cluster_id": "1"})
+	env.send("drain", "alert", map[string]string{"instance": "i-2", "cluster_id": "2"})
+
+	if drain.calls() != 2 {
+		t.Fatalf("expected 2 drain calls, got %d", drain.calls())
+	}
+
+	// the parameters are rendered for every event
+	for i, node := range []string{"i-1", "i-2"} {
+		params := drain.params[i]
+		if params["node"] != node {
+			t.Fatalf("expected node parameter %q, got %q", node, params["node"])
+		}
+		if reason := fmt.Sprintf("alert in cluster %d", i+1); params["reason"] != reason {
+			t.Fatalf("expected reason parameter %q, got %q", reason, params["reason"])
+		}
+		if params["grace"] != "30" {
+			t.Fatalf("expected non-string parameters in their string format, got %q", params["grace"])
+		}
+	}
+
+	// steps without parameters get none
+	if len(notify.params[0]) != 0 {
+		t.Fatalf("expected no parameters, got %v", notify.params[0])
+	}
+}
+
+func TestStepParamsMissingAttribute(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	notify := &testPlugin{name: "notify"}
+	env := newTestEnv(t, paramsConfig, drain, notify)
+
+	// the event has no cluster to render the reason from
+	event := env.send("drain", "alert", map[string]string{"instance": "i-1"})
+
+	if drain.calls() != 0 {
+		t.Fatal("expected the step not to run with a parameter failing to render")
+	}
+	err := executionError(t, env, "drain", event)
+	if err == nil || !strings.Contains(err.Error(), "could not render parameter") {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+}
+
+func TestStepRender(t *testing.T) {
+	s, err := NewStep("drain", map[string]interface{}{
+		"node":  "{{ .labels.instance }}",
+		"event": "{{ .type }}/{{ .id }}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := s.render(map[string]interface{}{
+		"labels": map[string]interface{}{"instance": "i-1"},
+		"type":   "alert",
+		"id":     "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["node"] != "i-1" || params["event"] != "alert/1" {
+		t.Fatalf("unexpected parameters: %v", params)
+	}
+
+	_, err = NewStep("drain", map[string]interface{}{"node": "{{ .instance "})
+	if err == nil {
+		t.Fatal("expected an invalid template to be rejected")
+	}
+}
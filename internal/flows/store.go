@@ -20,6 +20,9 @@ import (
 	cache "github.com/patrickmn/go-cache"
 )
 
+// storeNoExpiration keeps an event flow in the store until it is set again or deleted
+const storeNoExpiration = cache.NoExpiration
+
 type FlowStore interface {
 	Get(string) (*EventFlow, error)
 	Set(string, *EventFlow, time.Duration) error
@@ -0,0 +1,358 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+const (
+	// DefaultTenantOrgKey is the event attribute identifying the organization of an event
+	DefaultTenantOrgKey = "org_id"
+	// DefaultTenantClusterKey is the event attribute identifying the cluster of an event
+	DefaultTenantClusterKey = "cluster_id"
+)
+
+// ErrTenantRateLimited is returned for event flows dropped by the rate limits of their tenant
+var ErrTenantRateLimited = errors.New("event flow dropped by tenant rate limit") // nolint: gochecknoglobals
+
+// TenancyConfig holds the tenants flows and plugins are scoped to
+type TenancyConfig struct {
+	// Event attributes identifying the tenant of an event
+	OrgKey     string
+	ClusterKey string
+
+	// Tenants by name, the events of a tenant only reach the flows scoped to it,
+	// and the flows without tenants if the tenant opts in to them
+	Tenants map[string]TenantConfig
+}
+
+// TenantConfig describes a tenant by the org and the clusters its events come from
+type TenantConfig struct {
+	OrgID string
+
+	// Clusters of the org belonging to the tenant, every cluster of the org if empty
+	ClusterIDs []string
+
+	// The events of the tenant also reach the flows without tenants, eg. notifications shared by every tenant
+	GlobalFlows bool
+
+	// Limits of the event flows of the tenant across every flow
+	RateLimit RateLimitConfig
+
+	// Flow settings overridden for the events of the tenant by flow ID
+	Overrides map[string]TenantOverrideConfig
+}
+
+// TenantOverrideConfig holds the settings of a flow overridden for the events of a tenant
+type TenantOverrideConfig struct {
+	// The flow does not handle the events of the tenant
+	Disabled bool
+
+	// Filters the events of the tenant must match besides the filters of the flow
+	Filters map[string]string
+
+	// Cooldown of the event flows of the tenant instead of the cooldown of the flow
+	Cooldown *time.Duration
+}
+
+// Validate validates the tenancy configuration
+func (c TenancyConfig) Validate() error {
+	if len(c.Tenants) == 0 {
+		return nil
+	}
+
+	if c.OrgKey == "" {
+		return errors.New("org key must be set")
+	}
+
+	names := make([]string, 0, len(c.Tenants))
+	for name := range c.Tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// an event must not belong to more than one tenant
+	selectors := make(map[string]string)
+	for _, name := range names {
+		t := c.Tenants[name]
+		err := t.Validate()
+		if err != nil {
+			return emperror.WrapWith(err, "invalid tenant", "tenant", name)
+		}
+
+		clusters := t.ClusterIDs
+		if len(clusters) == 0 {
+			clusters = []string{""}
+		}
+		for _, cluster := range clusters {
+			selector := t.OrgID + "/" + cluster
+			if other, ok := selectors[selector]; ok {
+				return emperror.With(errors.New("tenants select the same events"), "tenant", name, "other-tenant", other, "org", t.OrgID, "cluster", cluster)
+			}
+			selectors[selector] = name
+		}
+	}
+
+	return nil
+}
+
+// Validate validates the tenant configuration
+func (c TenantConfig) Validate() error {
+	if c.OrgID == "" {
+		return errors.New("org ID must be set")
+	}
+
+	err := c.RateLimit.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "invalid rate limit")
+	}
+
+	for id, o := range c.Overrides {
+		if o.Cooldown != nil && *o.Cooldown < 0 {
+			return emperror.With(errors.New("cooldown must not be negative"), "flow", id)
+		}
+	}
+
+	return nil
+}
+
+// HasTenant tells whether the tenant is defined, tenant names are case insensitive
+func (c TenancyConfig) HasTenant(name string) bool {
+	for n := range c.Tenants {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tenant is the tenant of an event
+type tenant struct {
+	// Name of the tenant, empty if the event does not belong to a configured tenant
+	name string
+}
+
+// Tenants resolves the tenants of events and authorizes them to reach the flows
+type Tenants struct {
+	config   TenancyConfig
+	names    []string
+	tenants  map[string]TenantConfig
+	limiters map[string]*rateLimiter
+
+	mu     sync.Mutex
+	denied map[string]uint64
+}
+
+// NewTenants returns initialized Tenants
+func NewTenants(config TenancyConfig) *Tenants {
+	if config.OrgKey == "" {
+		config.OrgKey = DefaultTenantOrgKey
+	}
+	if config.ClusterKey == "" {
+		config.ClusterKey = DefaultTenantClusterKey
+	}
+
+	t := &Tenants{
+		config:   config,
+		tenants:  make(map[string]TenantConfig, len(config.Tenants)),
+		limiters: make(map[string]*rateLimiter),
+		denied:   make(map[string]uint64),
+	}
+
+	for name, c := range config.Tenants {
+		name = strings.ToLower(name)
+
+		overrides := make(map[string]TenantOverrideConfig, len(c.Overrides))
+		for id, o := range c.Overrides {
+			overrides[strings.ToLower(id)] = o
+		}
+		c.Overrides = overrides
+
+		t.names = append(t.names, name)
+		t.tenants[name] = c
+		if c.RateLimit.enabled() {
+			t.limiters[name] = newRateLimiter(c.RateLimit)
+		}
+	}
+	sort.Strings(t.names)
+
+	return t
+}
+
+// resolve returns the tenant of the event, tenants of specific clusters take precedence over the ones of the whole org
+func (t *Tenants) resolve(event *ce.Event) tenant {
+	orgID, _ := event.GetString(t.config.OrgKey)
+	if orgID == "" {
+		return tenant{}
+	}
+	clusterID, _ := event.GetString(t.config.ClusterKey)
+
+	var match tenant
+	for _, name := range t.names {
+		c := t.tenants[name]
+		if c.OrgID != orgID {
+			continue
+		}
+
+		if len(c.ClusterIDs) == 0 {
+			if match.name == "" {
+				match.name = name
+			}
+			continue
+		}
+		for _, id := range c.ClusterIDs {
+			if id == clusterID {
+				return tenant{name: name}
+			}
+		}
+	}
+
+	return match
+}
+
+// authorize tells whether the events of the tenant may reach the flow, events which do not
+// belong to a tenant only reach the flows without tenants
+func (t *Tenants) authorize(f *Flow, tn tenant) bool {
+	if len(f.tenants) > 0 {
+		if tn.name == "" || !contains(f.tenants, tn.name) {
+			return false
+		}
+	} else if tn.name != "" && !t.tenants[tn.name].GlobalFlows {
+		return false
+	}
+
+	return !t.override(f.id, tn).Disabled
+}
+
+// override returns the settings of the flow overridden for the tenant
+func (t *Tenants) override(flowID string, tn tenant) TenantOverrideConfig {
+	if tn.name == "" {
+		return TenantOverrideConfig{}
+	}
+
+	return t.tenants[tn.name].Overrides[flowID]
+}
+
+func (t *Tenants) deny(flowID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.denied[flowID]++
+}
+
+func (t *Tenants) deniedCount(flowID string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.denied[flowID]
+}
+
+// resolveTenant returns the tenant of the event and whether its events may reach the flow
+func (f *Flow) resolveTenant(event *ce.Event) (tenant, bool) {
+	tenants := f.manager.Tenants()
+	if tenants == nil {
+		return tenant{}, true
+	}
+
+	tn := tenants.resolve(event)
+	if tenants.authorize(f, tn) {
+		return tn, true
+	}
+
+	// only the events the flow would handle otherwise are counted as denied
+	if f.isEventTypeAllowed(event.Type) {
+		tenants.deny(f.id)
+	}
+
+	return tn, false
+}
+
+// tenantOverride returns the settings of the flow overridden for the tenant
+func (f *Flow) tenantOverride(tn tenant) TenantOverrideConfig {
+	tenants := f.manager.Tenants()
+	if tenants == nil {
+		return TenantOverrideConfig{}
+	}
+
+	return tenants.override(f.id, tn)
+}
+
+// validateTenants checks that the flow is scoped to the tenants of the plugins it calls,
+// flows without tenants may handle the events of any tenant and can not call scoped plugins
+func (c FlowConfig) validateTenants(plugins plugin.PluginManager) error {
+	for _, name := range c.PluginNames() {
+		scope := plugins.Tenants(name)
+		if len(scope) == 0 {
+			continue
+		}
+
+		if len(c.Tenants) == 0 {
+			return emperror.With(errors.New("plugin is scoped to tenants, the flow must be scoped to them too"), "plugin", name)
+		}
+		for _, t := range c.Tenants {
+			if !containsFold(scope, t) {
+				return emperror.With(errors.New("plugin is not available to the tenant"), "plugin", name, "tenant", t)
+			}
+		}
+	}
+
+	return nil
+}
+
+// acquireTenant checks the rate limits of the tenant of the event flow like acquire does for the flow
+func (ef *EventFlow) acquireTenant() (proceed bool, dropped bool) {
+	tenants := ef.flow.manager.Tenants()
+	if tenants == nil || ef.tenant.name == "" {
+		return true, false
+	}
+
+	l, ok := tenants.limiters[ef.tenant.name]
+	if !ok {
+		return true, false
+	}
+
+	return ef.acquireLimit(l)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
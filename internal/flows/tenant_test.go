@@ -0,0 +1,236 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+	_ "github.com/banzaicloud/hollowtrees/internal/plugin/builtin"
+)
+
+const tenantConfig = `
+flowEngine:
+  tenancy:
+    tenants:
+      acme:
+        orgID: "1"
+      globex:
+        orgID: "2"
+        clusterIDs: ["20"]
+        globalFlows: true
+flows:
+  global:
+    name: global
+    plugins: [global]
+  acme:
+    name: acme
+    tenants: [acme]
+    plugins: [acme]
+`
+
+func TestTenantRouting(t *testing.T) {
+	global := &testPlugin{name: "global"}
+	acme := &testPlugin{name: "acme"}
+	env := newTestEnv(t, tenantConfig, global, acme)
+
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		global     bool
+		acme       bool
+	}{
+		// the events of a tenant are isolated unless it opts in to the global flows
+		{name: "tenant", attributes: map[string]string{"org_id": "1", "cluster_id": "10"}, acme: true},
+		{name: "tenant with global flows", attributes: map[string]string{"org_id": "2", "cluster_id": "20"}, global: true},
+		{name: "cluster of no tenant", attributes: map[string]string{"org_id": "2", "cluster_id": "21"}, global: true},
+		{name: "org of no tenant", attributes: map[string]string{"org_id": "3"}, global: true},
+		{name: "no org", attributes: map[string]string{"instance": "i-1"}, global: true},
+	}
+
+	var globalCalls, acmeCalls int
+	for _, test := range tests {
+		env.send("global", "alert", test.attributes)
+		env.send("acme", "alert", test.attributes)
+
+		if test.global {
+			globalCalls++
+		}
+		if test.acme {
+			acmeCalls++
+		}
+		if global.calls() != globalCalls || acme.calls() != acmeCalls {
+			t.Fatalf("%s: expected the global flow to handle the event: %t, the flow of the tenant: %t", test.name, test.global, test.acme)
+		}
+	}
+
+	tenants := env.manager.Tenants()
+	if tenants.deniedCount("global") != 1 || tenants.deniedCount("acme") != 4 {
+		t.Fatalf("expected the denied events to be counted, got %d and %d", tenants.deniedCount("global"), tenants.deniedCount("acme"))
+	}
+}
+
+// loadTenantFlows loads the flows of the config with the plugins of the config
+func loadTenantFlows(t *testing.T, config string) error {
+	t.Helper()
+
+	v := readConfig(t, config)
+	var engine EngineConfig
+	err := v.UnmarshalKey("flowEngine", &engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	pm := plugin.NewManager(logger, emperror.NewNopHandler())
+	err = pm.LoadFromConfig(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(logger, emperror.NewNopHandler(), &testDispatcher{}, pm, WithEngineConfig(engine))
+
+	return m.LoadFlows(v)
+}
+
+func TestTenantPlugins(t *testing.T) {
+	const plugins = `
+flowEngine:
+  tenancy:
+    tenants:
+      acme:
+        orgID: "1"
+      globex:
+        orgID: "2"
+plugins:
+- name: acme-drain
+  type: http
+  tenants: [acme]
+  http:
+    url: http://acme-drain
+`
+
+	tests := map[string]struct {
+		flow  string
+		valid bool
+	}{
+		"scoped to the tenant": {flow: `
+    tenants: [acme]
+    plugins: [acme-drain]`, valid: true},
+		"scoped to another tenant": {flow: `
+    tenants: [globex]
+    plugins: [acme-drain]`},
+		"scoped to more tenants": {flow: `
+    tenants: [acme, globex]
+    steps:
+    - plugin: acme-drain`},
+		"without tenants": {flow: `
+    plugins: [acme-drain]`},
+	}
+
+	for name, test := range tests {
+		err := loadTenantFlows(t, plugins+`
+flows:
+  drain:
+    name: drain`+test.flow+`
+`)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected the flow to be valid: %t, got %v", name, test.valid, err)
+		}
+	}
+}
+
+// eventCollector collects the events published by internal plugins
+type eventCollector struct {
+	mu     sync.Mutex
+	events []*ce.Event
+}
+
+func (c *eventCollector) Publish(event *ce.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = append(c.events, event)
+
+	return nil
+}
+
+func (c *eventCollector) published() []*ce.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*ce.Event(nil), c.events...)
+}
+
+func TestTenantEmit(t *testing.T) {
+	collector := &eventCollector{}
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	pm := plugin.NewManager(logger, emperror.NewNopHandler(), plugin.WithEventPublisher(collector))
+	emit, err := pm.NewInternalPlugin("emit", "emit", map[string]interface{}{"type": "drained"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	global := &testPlugin{name: "global"}
+	acme := &testPlugin{name: "acme"}
+	env := newTestEnv(t, tenantConfig+`
+  drain:
+    name: drain
+    tenants: [acme]
+    allowedEvents: [alert]
+    steps:
+    - plugin: emit
+      params:
+        node: '{{ .instance }}'
+  hijack:
+    name: hijack
+    tenants: [acme]
+    allowedEvents: [alert]
+    steps:
+    - plugin: emit
+      params:
+        org_id: "2"
+`, emit, global, acme)
+
+	env.send("drain", "alert", map[string]string{"org_id": "1", "cluster_id": "10", "instance": "i-1"})
+
+	events := collector.published()
+	if len(events) != 1 {
+		t.Fatalf("expected an emitted event, got %d", len(events))
+	}
+	e := events[0]
+	for k, expected := range map[string]string{"org_id": "1", "cluster_id": "10", "node": "i-1"} {
+		if v, _ := e.GetString(k); v != expected {
+			t.Errorf("expected attribute %s of the emitted event to be %q, got %q", k, expected, v)
+		}
+	}
+
+	// the emitted event stays in the tenant
+	env.manager.flow("global").Handle(e)
+	env.manager.flow("acme").Handle(e)
+	if global.calls() != 0 || acme.calls() != 1 {
+		t.Fatalf("expected the emitted event to reach the flows of the tenant only, got %d and %d", global.calls(), acme.calls())
+	}
+
+	env.send("hijack", "alert", map[string]string{"org_id": "1", "cluster_id": "10"})
+	if len(collector.published()) != 1 || env.errors.count() != 1 {
+		t.Fatal("expected the flow emitting an event of another tenant to fail")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+const unavailableConfig = `
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+    groupBy: [instance]
+    onPluginUnavailable: defer
+    deferTimeout: 1m
+  notify:
+    name: notify
+    plugins: [notify]
+    groupBy: [instance]
+`
+
+// newBreakerTestEnv returns a test environment with plugin circuit breakers opening on the
+// first failure and letting trial calls through after openTimeout
+func newBreakerTestEnv(t *testing.T, openTimeout time.Duration, plugins ...plugin.EventHandlerPlugin) *testEnv {
+	t.Helper()
+
+	return newTestEnvWithPluginOptions(t, unavailableConfig, nil, []plugin.ManagerOption{
+		plugin.WithCircuitBreaker(plugin.BreakerConfig{
+			Enabled:          true,
+			FailureThreshold: 1,
+			OpenTimeout:      openTimeout,
+			SuccessThreshold: 1,
+		}),
+	}, plugins...)
+}
+
+func TestUnavailablePluginDefer(t *testing.T) {
+	drain := &testPlugin{name: "drain", err: errors.New("unavailable")}
+	env := newBreakerTestEnv(t, 30*time.Second, drain, &testPlugin{name: "notify"})
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	drain.err = nil
+
+	// the event flow is parked instead of blocking the dispatcher
+	env.send("drain", "alert", map[string]string{"instance": "i-2"})
+	if drain.calls() != 1 || env.clock.pending() != 1 {
+		t.Fatalf("expected the event flow to be deferred, got %d calls and %d timers", drain.calls(), env.clock.pending())
+	}
+	if s := env.manager.Executions().List(); len(s) != 1 || s[0].Status != EventFlowDeferred {
+		t.Fatalf("expected a deferred execution, got %+v", s)
+	}
+
+	env.clock.Advance(20 * time.Second)
+	if drain.calls() != 1 || env.clock.pending() != 1 {
+		t.Fatal("expected the event flow to wait while the breaker is open")
+	}
+
+	env.clock.Advance(10 * time.Second)
+	if drain.calls() != 2 || env.clock.pending() != 0 {
+		t.Fatalf("expected the event flow to run once the breaker let calls through, got %d calls", drain.calls())
+	}
+	if env.errors.count() != 1 {
+		t.Fatalf("expected only the first call to fail, got %d errors", env.errors.count())
+	}
+}
+
+func TestUnavailablePluginDeferTimeout(t *testing.T) {
+	drain := &testPlugin{name: "drain", err: errors.New("unavailable")}
+	env := newBreakerTestEnv(t, 5*time.Minute, drain, &testPlugin{name: "notify"})
+
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	env.send("drain", "alert", map[string]string{"instance": "i-2"})
+
+	env.clock.Advance(59 * time.Second)
+	if env.errors.count() != 1 {
+		t.Fatal("expected the event flow to wait for the defer timeout")
+	}
+
+	env.clock.Advance(time.Second)
+	if drain.calls() != 1 || env.clock.pending() != 0 || env.errors.count() != 2 {
+		t.Fatalf("expected the event flow to fail after the defer timeout, got %d calls and %d errors", drain.calls(), env.errors.count())
+	}
+	if len(env.manager.Executions().List()) != 0 {
+		t.Fatal("expected no running executions")
+	}
+}
+
+func TestUnavailablePluginFail(t *testing.T) {
+	notify := &testPlugin{name: "notify", err: errors.New("unavailable")}
+	env := newBreakerTestEnv(t, 30*time.Second, &testPlugin{name: "drain"}, notify)
+
+	env.send("notify", "alert", map[string]string{"instance": "i-1"})
+	notify.err = nil
+
+	// the event flow fails right away without calling the plugin
+	env.send("notify", "alert", map[string]string{"instance": "i-2"})
+	if notify.calls() != 1 || env.clock.pending() != 0 || env.errors.count() != 2 {
+		t.Fatalf("expected the event flow to fail without calling the plugin, got %d calls and %d errors", notify.calls(), env.errors.count())
+	}
+
+	env.clock.Advance(30 * time.Second)
+	env.send("notify", "alert", map[string]string{"instance": "i-2"})
+	if notify.calls() != 2 {
+		t.Fatalf("expected the plugin to be called once the breaker let calls through, got %d calls", notify.calls())
+	}
+}
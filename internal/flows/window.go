@@ -0,0 +1,253 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// WindowDefer makes events arriving outside the active windows wait for the next window
+	WindowDefer = "defer"
+	// WindowDrop drops events arriving outside the active windows
+	WindowDrop = "drop"
+
+	// deferred event flows check the windows again this often if the next opening is unknown
+	windowCheckInterval = time.Hour
+)
+
+// ErrOutsideActiveWindows is returned for event flows dropped outside the active windows of their flow
+var ErrOutsideActiveWindows = errors.New("event flow dropped outside active windows") // nolint: gochecknoglobals
+
+// nolint: gochecknoglobals
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ActiveWindowConfig holds configuration values for a time window the flow is active in, the window
+// either opens on a cron schedule for a duration, or is a daily time range on the given days
+type ActiveWindowConfig struct {
+	// Cron expression of the window openings, eg. "0 22 * * 1-5"
+	Schedule string        `mapstructure:"schedule"`
+	Duration time.Duration `mapstructure:"duration"`
+
+	// Daily time range, eg. 22:00-04:00, ranges ending before they start span midnight
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+	// Days of the range by their three letter names, eg. mon, every day if empty
+	Days []string `mapstructure:"days"`
+
+	// Time zone of the window, eg. Europe/Budapest, UTC if empty
+	Timezone string `mapstructure:"timezone"`
+}
+
+// Validate validates the active window configuration
+func (c ActiveWindowConfig) Validate() error {
+	_, err := newActiveWindow(c)
+
+	return err
+}
+
+type activeWindow struct {
+	location *time.Location
+
+	schedule cron.Schedule
+	duration time.Duration
+
+	from time.Duration
+	to   time.Duration
+	days map[time.Weekday]bool
+}
+
+func newActiveWindow(c ActiveWindowConfig) (*activeWindow, error) {
+	w := &activeWindow{
+		location: time.UTC,
+	}
+
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, emperror.WrapWith(err, "invalid time zone", "timezone", c.Timezone)
+		}
+		w.location = loc
+	}
+
+	if (c.Schedule == "") == (c.From == "" && c.To == "") {
+		return nil, errors.New("either schedule or from and to must be set")
+	}
+
+	if c.Schedule != "" {
+		if c.Duration <= 0 {
+			return nil, errors.New("duration must be positive with a schedule")
+		}
+		w.duration = c.Duration
+
+		schedule, err := cron.ParseStandard(c.Schedule)
+		if err != nil {
+			return nil, emperror.WrapWith(err, "invalid cron expression", "schedule", c.Schedule)
+		}
+		s, ok := schedule.(*cron.SpecSchedule)
+		if !ok {
+			return nil, emperror.With(errors.New("schedule must be a cron expression"), "schedule", c.Schedule)
+		}
+		s.Location = w.location
+		w.schedule = s
+
+		return w, nil
+	}
+
+	var err error
+	w.from, err = parseTimeOfDay(c.From)
+	if err != nil {
+		return nil, err
+	}
+	w.to, err = parseTimeOfDay(c.To)
+	if err != nil {
+		return nil, err
+	}
+	if w.from == w.to {
+		return nil, errors.New("from and to must differ")
+	}
+
+	w.days = make(map[time.Weekday]bool, len(c.Days))
+	for _, d := range c.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return nil, emperror.With(errors.New("invalid day"), "day", d)
+		}
+		w.days[day] = true
+	}
+
+	return w, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, emperror.WrapWith(err, "invalid time of day, expected HH:MM", "time", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains checks whether the window is open at t
+func (w *activeWindow) contains(t time.Time) bool {
+	if w.schedule != nil {
+		// the last opening before t is the first one after the window length before t
+		return !w.schedule.Next(t.Add(-w.duration)).After(t)
+	}
+
+	// the time of day is the wall clock time, days are not 24 hours long around DST changes
+	t = t.In(w.location)
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if w.from < w.to {
+		return w.onDay(t.Weekday()) && tod >= w.from && tod < w.to
+	}
+
+	// the range spans midnight, its end belongs to the day it started on
+	if tod >= w.from {
+		return w.onDay(t.Weekday())
+	}
+
+	return tod < w.to && w.onDay((t.Weekday()+6)%7)
+}
+
+// next returns the first time the window opens after t, zero if it never opens
+func (w *activeWindow) next(t time.Time) time.Time {
+	if w.schedule != nil {
+		return w.schedule.Next(t)
+	}
+
+	t = t.In(w.location)
+	hour, minute := int(w.from/time.Hour), int(w.from%time.Hour/time.Minute)
+	for d := 0; d <= 7; d++ {
+		opening := time.Date(t.Year(), t.Month(), t.Day()+d, hour, minute, 0, 0, w.location)
+		if opening.After(t) && w.onDay(opening.Weekday()) {
+			return opening
+		}
+	}
+
+	return time.Time{}
+}
+
+func (w *activeWindow) onDay(d time.Weekday) bool {
+	return len(w.days) == 0 || w.days[d]
+}
+
+// activeWindows holds the active windows of a flow, the flow is active if any of them is open
+type activeWindows []*activeWindow
+
+func (ws activeWindows) contains(t time.Time) bool {
+	if len(ws) == 0 {
+		return true
+	}
+
+	for _, w := range ws {
+		if w.contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// next returns the first time any of the windows opens after t, zero if none of them opens
+func (ws activeWindows) next(t time.Time) time.Time {
+	var next time.Time
+	for _, w := range ws {
+		n := w.next(t)
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+
+	return next
+}
+
+// waitForWindow checks the active windows of the flow, it returns false if the event flow arrived
+// outside them and does not proceed: it is dropped, or with the defer policy it is parked until
+// the next window opens instead of blocking the event dispatcher
+func (ef *EventFlow) waitForWindow() (proceed bool, dropped bool) {
+	now := ef.flow.manager.Clock().Now()
+	if ef.flow.activeWindows.contains(now) {
+		return true, false
+	}
+
+	if ef.flow.windowPolicy == WindowDrop {
+		return false, true
+	}
+
+	wait := windowCheckInterval
+	if next := ef.flow.activeWindows.next(now); !next.IsZero() {
+		wait = next.Sub(now)
+	}
+
+	ef.setStatus(EventFlowDeferred)
+	ef.park(wait, ef.proceed)
+
+	return false, false
+}
@@ -0,0 +1,109 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flows
+
+import (
+	"testing"
+	"time"
+)
+
+const windowConfig = `
+flows:
+  drain:
+    name: drain
+    plugins: [drain]
+    groupBy: [instance]
+    activeWindows:
+    - from: "22:00"
+      to: "04:00"
+      days: [mon, tue, wed, thu, fri]
+  report:
+    name: report
+    plugins: [report]
+    windowPolicy: drop
+    activeWindows:
+    - schedule: "0 22 * * *"
+      duration: 1h
+`
+
+func TestWindowDefersUntilOpening(t *testing.T) {
+	drain := &testPlugin{name: "drain"}
+	env := newTestEnv(t, windowConfig, drain, &testPlugin{name: "report"})
+
+	// the fake clock starts on a Thursday at noon
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if drain.calls() != 0 {
+		t.Fatal("plugin called outside the active window")
+	}
+	if env.clock.pending() != 1 {
+		t.Fatalf("expected the event flow to be parked on a timer, got %d timers", env.clock.pending())
+	}
+
+	// the in-flight event flow is kept in the flow store beyond the usual expiration,
+	// so re-sent alerts do not start another event flow for the group key
+	env.clock.Advance(9 * time.Hour)
+	env.send("drain", "alert", map[string]string{"instance": "i-1"})
+	if env.clock.pending() != 1 {
+		t.Fatalf("expected a single deferred event flow, got %d timers", env.clock.pending())
+	}
+
+	env.clock.Advance(59 * time.Minute)
+	if drain.calls() != 0 {
+		t.Fatal("plugin called before the window opened")
+	}
+
+	env.clock.Advance(time.Minute)
+	if drain.calls() != 1 {
+		t.Fatalf("expected 1 plugin call once the window opened, got %d", drain.calls())
+	}
+}
+
+func TestWindowDrop(t *testing.T) {
+	report := &testPlugin{name: "report"}
+	env := newTestEnv(t, windowConfig, &testPlugin{name: "drain"}, report)
+
+	env.send("report", "alert", nil)
+	if report.calls() != 0 || env.clock.pending() != 0 || env.errors.count() != 1 {
+		t.Fatal("expected the event flow to be dropped outside the window")
+	}
+
+	env.clock.Advance(10*time.Hour + 30*time.Minute)
+	env.send("report", "alert", nil)
+	if report.calls() != 1 {
+		t.Fatalf("expected 1 plugin call within the window, got %d", report.calls())
+	}
+}
+
+func TestActiveWindowNext(t *testing.T) {
+	budapest, err := time.LoadLocation("Europe/Budapest")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+
+	w, err := newActiveWindow(ActiveWindowConfig{From: "02:30", To: "03:30", Days: []string{"sun"}, Timezone: "Europe/Budapest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the opening is a wall clock time on the day clocks are turned back
+	next := w.next(time.Date(2019, 10, 26, 12, 0, 0, 0, budapest))
+	expected := time.Date(2019, 10, 27, 2, 30, 0, 0, budapest)
+	if !next.Equal(expected) {
+		t.Fatalf("expected %s, got %s", expected, next)
+	}
+	if !w.contains(next) {
+		t.Fatal("expected the window to be open at its opening")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goph/emperror"
+)
+
+// API serves the history query endpoints
+type API struct {
+	store        Store
+	errorHandler emperror.Handler
+}
+
+// NewAPI returns an initialized API
+func NewAPI(store Store, errorHandler emperror.Handler) *API {
+	return &API{
+		store:        store,
+		errorHandler: errorHandler,
+	}
+}
+
+// RegisterRoutes registers the history endpoints
+func (a *API) RegisterRoutes(r gin.IRouter) {
+	r.GET("/history/events", a.listEvents)
+	r.GET("/history/executions", a.listExecutions)
+}
+
+func (a *API) listEvents(c *gin.Context) {
+	q, ok := a.query(c)
+	if !ok {
+		return
+	}
+
+	records, err := a.store.Events(q)
+	if err != nil {
+		a.internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   records,
+	})
+}
+
+func (a *API) listExecutions(c *gin.Context) {
+	q, ok := a.query(c)
+	if !ok {
+		return
+	}
+
+	records, err := a.store.Executions(q)
+	if err != nil {
+		a.internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   records,
+	})
+}
+
+// query parses the query parameters, it responds with an error if they are invalid
+func (a *API) query(c *gin.Context) (Query, bool) {
+	q := Query{
+		Type:          c.Query("type"),
+		FlowID:        c.Query("flow"),
+		ClusterID:     c.Query("cluster_id"),
+		CorrelationID: c.Query("correlation_id"),
+	}
+
+	var err error
+	for param, t := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := c.Query(param); v != "" {
+			*t, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				a.badRequest(c, "invalid '"+param+"' parameter, RFC3339 time expected", err)
+				return q, false
+			}
+		}
+	}
+
+	if v := c.Query("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit < 0 {
+			a.badRequest(c, "invalid 'limit' parameter", err)
+			return q, false
+		}
+	}
+
+	return q, true
+}
+
+func (a *API) badRequest(c *gin.Context, message string, err error) {
+	body := gin.H{
+		"status":  http.StatusBadRequest,
+		"message": message,
+	}
+	if err != nil {
+		body["error"] = err.Error()
+	}
+
+	c.AbortWithStatusJSON(http.StatusBadRequest, body)
+}
+
+func (a *API) internalError(c *gin.Context, err error) {
+	a.errorHandler.Handle(err)
+
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		"status":  http.StatusInternalServerError,
+		"message": "could not query history",
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,310 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// nolint: gochecknoglobals
+var (
+	eventsBucket     = []byte("events")
+	executionsBucket = []byte("executions")
+	cooldownsBucket  = []byte("cooldowns")
+
+	// how long opening a database waits for the lock of another process
+	lockTimeout = 5 * time.Second
+)
+
+// ErrDatabaseLocked is returned by NewReadOnlyBoltStore when another process, like the running daemon, holds the database
+var ErrDatabaseLocked = errors.New("database is locked by another process")
+
+// BoltStore is a Store implementation backed by a bbolt database,
+// records are keyed by their timestamp so time range queries are cheap
+type BoltStore struct {
+	db        *bolt.DB
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewBoltStore opens or creates the database at path
+func NewBoltStore(path string, retention time.Duration) (*BoltStore, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not create database directory")
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not open database", "path", path)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{eventsBucket, executionsBucket, cooldownsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, emperror.Wrap(err, "could not initialize database")
+	}
+
+	return &BoltStore{
+		db:        db,
+		retention: retention,
+		now:       time.Now,
+	}, nil
+}
+
+// NewReadOnlyBoltStore opens an existing database at path for querying, the database is
+// locked while the daemon is running, so it gives up with ErrDatabaseLocked after a few seconds
+func NewReadOnlyBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err == bolt.ErrTimeout {
+		return nil, emperror.With(ErrDatabaseLocked, "path", path)
+	}
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not open database", "path", path)
+	}
+
+	return &BoltStore{
+		db:  db,
+		now: time.Now,
+	}, nil
+}
+
+// RecordEvent stores an ingested event
+func (s *BoltStore) RecordEvent(event *ce.Event) error {
+	r, err := NewEventRecord(event, s.now())
+	if err != nil {
+		return emperror.Wrap(err, "could not create event record")
+	}
+
+	return s.put(eventsBucket, r.ReceivedAt, r.ID, r)
+}
+
+// RecordExecution stores an event flow execution
+func (s *BoltStore) RecordExecution(r *ExecutionRecord) error {
+	return s.put(executionsBucket, r.StartedAt, r.ID, r)
+}
+
+// Events returns the event records matching the query in chronological order
+func (s *BoltStore) Events(q Query) ([]EventRecord, error) {
+	records := []EventRecord{}
+
+	err := s.scan(eventsBucket, q, func(v []byte) (bool, error) {
+		var r EventRecord
+		if err := json.Unmarshal(v, &r); err != nil {
+			return false, err
+		}
+		if !q.matchesEvent(&r) {
+			return false, nil
+		}
+		records = append(records, r)
+		return true, nil
+	})
+
+	return records, err
+}
+
+// Executions returns the event flow execution records matching the query in chronological order
+func (s *BoltStore) Executions(q Query) ([]ExecutionRecord, error) {
+	records := []ExecutionRecord{}
+
+	err := s.scan(executionsBucket, q, func(v []byte) (bool, error) {
+		var r ExecutionRecord
+		if err := json.Unmarshal(v, &r); err != nil {
+			return false, err
+		}
+		if !q.matchesExecution(&r) {
+			return false, nil
+		}
+		records = append(records, r)
+		return true, nil
+	})
+
+	return records, err
+}
+
+// SetCooldown stores the expiry of the cooldown of an event flow, so it survives restarts
+func (s *BoltStore) SetCooldown(key string, until time.Time) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(cooldownsBucket).Put([]byte(key), timeKey(until))
+	})
+	if err != nil {
+		return emperror.WrapWith(err, "could not store cooldown", "key", key)
+	}
+
+	return nil
+}
+
+// Cooldowns returns the expiry of the cooldowns not expired at now by their keys
+func (s *BoltStore) Cooldowns(now time.Time) (map[string]time.Time, error) {
+	cooldowns := make(map[string]time.Time)
+	limit := timeKey(now)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cooldownsBucket)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			if bytes.Compare(v, limit) > 0 {
+				cooldowns[string(k)] = time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not read cooldowns")
+	}
+
+	return cooldowns, nil
+}
+
+// Cleanup deletes the records older than the retention time and the expired cooldowns
+func (s *BoltStore) Cleanup() (int, error) {
+	now := s.now()
+	limit := timeKey(now.Add(-s.retention))
+	deleted := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{eventsBucket, executionsBucket} {
+			c := tx.Bucket(b).Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, limit) < 0; k, _ = c.Next() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				deleted++
+			}
+		}
+
+		// cooldowns are keyed by event flow, their values are the expiry
+		expired := timeKey(now)
+		c := tx.Bucket(cooldownsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if bytes.Compare(v, expired) > 0 {
+				continue
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			deleted++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, emperror.Wrap(err, "could not delete expired records")
+	}
+
+	return deleted, nil
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltStore) put(bucket []byte, t time.Time, id string, record interface{}) error {
+	v, err := json.Marshal(record)
+	if err != nil {
+		return emperror.Wrap(err, "could not marshal record")
+	}
+
+	key := append(timeKey(t), []byte(id)...)
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(key, v)
+	})
+	if err != nil {
+		return emperror.WrapWith(err, "could not store record", "id", id)
+	}
+
+	return nil
+}
+
+// scan calls fn for the values in the time range of the query until the limit of matches is reached
+func (s *BoltStore) scan(bucket []byte, q Query, fn func(v []byte) (bool, error)) error {
+	var to []byte
+	if !q.To.IsZero() {
+		to = timeKey(q.To)
+	}
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		matched := 0
+		for k, v := c.Seek(timeKey(q.From)); k != nil; k, v = c.Next() {
+			if to != nil && bytes.Compare(k[:8], to) > 0 {
+				break
+			}
+
+			ok, err := fn(v)
+			if err != nil {
+				return emperror.Wrap(err, "could not unmarshal record")
+			}
+			if ok {
+				matched++
+			}
+			if q.Limit > 0 && matched >= q.Limit {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+func timeKey(t time.Time) []byte {
+	b := make([]byte, 8)
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	}
+
+	return b
+}
+
+// RunCleanup periodically deletes the expired records until the process exits
+func (s *BoltStore) RunCleanup(interval time.Duration, logger log.Logger, errorHandler emperror.Handler) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := s.Cleanup()
+		if err != nil {
+			errorHandler.Handle(err)
+			continue
+		}
+
+		if deleted > 0 {
+			logger.WithField("count", deleted).Debug("expired history records deleted")
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestBoltStoreCooldowns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cooldowns.db")
+	s, err := NewBoltStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	for key, until := range map[string]time.Time{"drain/i-1": now.Add(time.Minute), "drain/i-2": now.Add(-time.Minute)} {
+		err = s.SetCooldown(key, until)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := s.Cleanup()
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected the expired cooldown to be deleted, got %d, %v", deleted, err)
+	}
+	s.Close()
+
+	// the cooldowns survive reopening the database
+	s, err = NewBoltStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	cooldowns, err := s.Cooldowns(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cooldowns) != 1 || !cooldowns["drain/i-1"].Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected cooldowns: %v", cooldowns)
+	}
+}
+
+func TestReadOnlyBoltStoreLocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	timeout := lockTimeout
+	lockTimeout = 100 * time.Millisecond
+	defer func() { lockTimeout = timeout }()
+
+	path := filepath.Join(dir, "history.db")
+	s, err := NewBoltStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewReadOnlyBoltStore(path)
+	if errors.Cause(err) != ErrDatabaseLocked {
+		t.Fatalf("expected ErrDatabaseLocked while the database is open, got %v", err)
+	}
+	s.Close()
+
+	r, err := NewReadOnlyBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+}
@@ -0,0 +1,105 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+)
+
+// Client queries the history API of a running instance
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient returns a Client for the admin API at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Events returns the event records matching the query
+func (c *Client) Events(q Query) ([]EventRecord, error) {
+	var records []EventRecord
+
+	err := c.get("/api/v1/history/events", q, &records)
+
+	return records, err
+}
+
+// Executions returns the event flow execution records matching the query
+func (c *Client) Executions(q Query) ([]ExecutionRecord, error) {
+	var records []ExecutionRecord
+
+	err := c.get("/api/v1/history/executions", q, &records)
+
+	return records, err
+}
+
+func (c *Client) get(path string, q Query, data interface{}) error {
+	params := url.Values{}
+	for param, value := range map[string]string{
+		"type":           q.Type,
+		"flow":           q.FlowID,
+		"cluster_id":     q.ClusterID,
+		"correlation_id": q.CorrelationID,
+	} {
+		if value != "" {
+			params.Set(param, value)
+		}
+	}
+	if !q.From.IsZero() {
+		params.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		params.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	u := c.baseURL + path + "?" + params.Encode()
+	resp, err := c.client.Get(u)
+	if err != nil {
+		return emperror.WrapWith(err, "could not query history", "url", u)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Message string          `json:"message"`
+		Error   string          `json:"error"`
+		Data    json.RawMessage `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return emperror.WrapWith(err, "could not decode history response", "url", u)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return emperror.With(errors.Errorf("%s: %s", body.Message, body.Error), "url", u, "status", resp.StatusCode)
+	}
+
+	return json.Unmarshal(body.Data, data)
+}
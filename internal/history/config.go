@@ -0,0 +1,50 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config holds configuration values for the event history store
+type Config struct {
+	// Records incoming events and event flow executions
+	Enabled bool
+
+	// Path of the database file
+	Path string
+
+	// Time records are kept for
+	Retention time.Duration
+}
+
+// Validate checks that the configuration is valid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Path == "" {
+		return errors.New("path must not be empty")
+	}
+
+	if c.Retention <= 0 {
+		return errors.New("retention must be positive")
+	}
+
+	return nil
+}
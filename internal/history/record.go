@@ -0,0 +1,126 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+// EventRecord describes an ingested event
+type EventRecord struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Source        string          `json:"source"`
+	CorrelationID string          `json:"correlationID,omitempty"`
+	ClusterID     string          `json:"clusterID,omitempty"`
+	ReceivedAt    time.Time       `json:"receivedAt"`
+	Event         json.RawMessage `json:"event"`
+}
+
+// NewEventRecord returns an EventRecord describing the given event
+func NewEventRecord(event *ce.Event, receivedAt time.Time) (*EventRecord, error) {
+	raw, err := event.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	cid, _ := event.GetString("correlationid")
+	clusterID, _ := event.GetString("cluster_id")
+
+	return &EventRecord{
+		ID:            event.ID,
+		Type:          event.Type,
+		Source:        event.Source.String(),
+		CorrelationID: cid,
+		ClusterID:     clusterID,
+		ReceivedAt:    receivedAt,
+		Event:         raw,
+	}, nil
+}
+
+// ExecutionRecord describes an event flow execution
+type ExecutionRecord struct {
+	ID            string         `json:"id"`
+	FlowID        string         `json:"flowID"`
+	FlowName      string         `json:"flowName"`
+	GroupKey      string         `json:"groupKey"`
+	EventID       string         `json:"eventID"`
+	EventType     string         `json:"eventType"`
+	CorrelationID string         `json:"correlationID,omitempty"`
+	ClusterID     string         `json:"clusterID,omitempty"`
+	StartedAt     time.Time      `json:"startedAt"`
+	FinishedAt    time.Time      `json:"finishedAt"`
+	Status        string         `json:"status"`
+	Error         string         `json:"error,omitempty"`
+	Plugins       []PluginRecord `json:"plugins"`
+}
+
+// PluginRecord describes a plugin call of an event flow execution
+type PluginRecord struct {
+	Name      string            `json:"name"`
+	Step      string            `json:"step,omitempty"`
+	StartedAt time.Time         `json:"startedAt"`
+	Duration  time.Duration     `json:"duration"`
+	Params    map[string]string `json:"params,omitempty"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Result    string            `json:"result,omitempty"`
+	Output    map[string]string `json:"output,omitempty"`
+
+	// ID of the long-running operation started by the plugin
+	OperationID string `json:"operationId,omitempty"`
+
+	// Progress updates reported by the plugin
+	Progress []ProgressRecord `json:"progress,omitempty"`
+}
+
+// ProgressRecord describes a progress update of a plugin call
+type ProgressRecord struct {
+	Time       time.Time         `json:"time"`
+	Percentage int               `json:"percentage"`
+	Message    string            `json:"message,omitempty"`
+	Output     map[string]string `json:"output,omitempty"`
+}
+
+// Query filters records, zero values match everything, FlowID only applies to executions
+type Query struct {
+	From          time.Time
+	To            time.Time
+	Type          string
+	FlowID        string
+	ClusterID     string
+	CorrelationID string
+	Limit         int
+}
+
+func (q Query) matchesEvent(r *EventRecord) bool {
+	return match(q.Type, r.Type) &&
+		match(q.ClusterID, r.ClusterID) &&
+		match(q.CorrelationID, r.CorrelationID)
+}
+
+func (q Query) matchesExecution(r *ExecutionRecord) bool {
+	return match(q.Type, r.EventType) &&
+		match(q.FlowID, r.FlowID) &&
+		match(q.ClusterID, r.ClusterID) &&
+		match(q.CorrelationID, r.CorrelationID)
+}
+
+func match(filter string, value string) bool {
+	return filter == "" || filter == value
+}
@@ -0,0 +1,58 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+// Recorder records ingested events and event flow executions to a Store
+type Recorder struct {
+	store        Store
+	errorHandler emperror.Handler
+}
+
+// NewRecorder returns an initialized Recorder
+func NewRecorder(store Store, errorHandler emperror.Handler) *Recorder {
+	return &Recorder{
+		store:        store,
+		errorHandler: errorHandler,
+	}
+}
+
+// Handle records an ingested event, it is meant to be subscribed to the event bus
+func (r *Recorder) Handle(event interface{}) {
+	e, ok := event.(*ce.Event)
+	if !ok {
+		r.errorHandler.Handle(emperror.With(errors.Errorf("invalid event value: %#v", event)))
+		return
+	}
+
+	err := r.store.RecordEvent(e)
+	if err != nil {
+		r.errorHandler.Handle(emperror.WrapWith(err, "could not record event", "type", e.Type, "id", e.ID))
+	}
+}
+
+// RecordExecution records an event flow execution
+func (r *Recorder) RecordExecution(record *ExecutionRecord) {
+	err := r.store.RecordExecution(record)
+	if err != nil {
+		r.errorHandler.Handle(emperror.WrapWith(err, "could not record event flow execution", "flow", record.FlowID, "event-id", record.EventID))
+	}
+}
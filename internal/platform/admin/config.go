@@ -0,0 +1,32 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import "errors"
+
+// Config holds configuration values for the admin API
+type Config struct {
+	Enabled       bool
+	ListenAddress string
+}
+
+// Validate checks that the configuration is valid.
+func (c Config) Validate() error {
+	if c.Enabled && c.ListenAddress == "" {
+		return errors.New("listen address must not be empty")
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/goph/emperror"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/gin/correlationid"
+	ginlog "github.com/banzaicloud/hollowtrees/internal/platform/gin/log"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// RouteRegistrar registers its routes on the admin API
+type RouteRegistrar interface {
+	RegisterRoutes(r gin.IRouter)
+}
+
+// Server serves the admin API
+type Server struct {
+	listenAddress string
+	engine        *gin.Engine
+
+	logger       log.Logger
+	errorHandler emperror.Handler
+}
+
+// New returns an initialized Server
+func New(config Config, logger log.Logger, errorHandler emperror.Handler) *Server {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(correlationid.Middleware())
+	r.Use(ginlog.Middleware(logger))
+
+	// metrics of the collectors registered in the default Prometheus registry
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	return &Server{
+		listenAddress: config.ListenAddress,
+		engine:        r,
+
+		logger:       logger,
+		errorHandler: errorHandler,
+	}
+}
+
+// Register registers the routes of the given components under /api/v1
+func (s *Server) Register(registrars ...RouteRegistrar) {
+	group := s.engine.Group("/api/v1")
+	for _, r := range registrars {
+		r.RegisterRoutes(group)
+	}
+}
+
+// Run runs the admin API HTTP listener
+func (s *Server) Run() {
+	s.logger.WithField("addr", s.listenAddress).Info("starting admin API")
+
+	err := s.engine.Run(s.listenAddress)
+	if err != nil {
+		s.errorHandler.Handle(err)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import "time"
+
+// Clock provides the current time, sleeping and timers, it makes time dependent code testable
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function call scheduled by AfterFunc
+type Timer interface {
+	// Stop prevents the call, it returns false if the call already started or the timer was stopped
+	Stop() bool
+}
+
+type realClock struct{}
+
+// New returns a Clock backed by the time package
+func New() Clock {
+	return realClock{}
+}
+
+// Now returns the current local time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep pauses the current goroutine for at least the duration d
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// AfterFunc calls f in its own goroutine after the duration d
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
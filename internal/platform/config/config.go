@@ -15,6 +15,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -23,9 +24,18 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
+	"github.com/banzaicloud/hollowtrees/internal/dedup"
+	"github.com/banzaicloud/hollowtrees/internal/flows"
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/platform/admin"
+	"github.com/banzaicloud/hollowtrees/internal/platform/eventbus"
 	"github.com/banzaicloud/hollowtrees/internal/platform/healthcheck"
 	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
 	"github.com/banzaicloud/hollowtrees/internal/promalert"
+	"github.com/banzaicloud/hollowtrees/internal/scheduler"
+	"github.com/banzaicloud/hollowtrees/internal/sources"
+	"github.com/banzaicloud/hollowtrees/internal/spotpoller"
 )
 
 const (
@@ -54,8 +64,38 @@ type Config struct {
 	// Healthcheck configuration
 	Healthcheck healthcheck.Config
 
+	// Internal event bus configuration
+	EventBus eventbus.Config
+
+	// Event deduplication configuration
+	Dedup dedup.Config
+
+	// Event history configuration
+	History history.Config
+
+	// Plugin circuit breaker configuration
+	CircuitBreaker plugin.BreakerConfig
+
+	// Long-running plugin operation configuration
+	Operations plugin.OperationConfig
+
+	// Configuration common to every action flow
+	FlowEngine flows.EngineConfig
+
+	// Admin API configuration
+	Admin admin.Config
+
 	// Prometheus alert handler configuration
 	Promalert promalert.Config
+
+	// Spot interruption notice poller configuration
+	SpotPoller spotpoller.Config
+
+	// Scheduled event configuration
+	Scheduler scheduler.Config
+
+	// Flow and plugin definitions besides the config file
+	Sources sources.Config
 }
 
 // Validate validates the configuration
@@ -65,6 +105,45 @@ func (c Config) Validate() error {
 		return emperror.Wrap(err, "could not validate log config")
 	}
 
+	err = c.EventBus.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate event bus config")
+	}
+
+	err = c.Dedup.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate dedup config")
+	}
+
+	err = c.History.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate history config")
+	}
+
+	err = c.CircuitBreaker.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate circuit breaker config")
+	}
+
+	err = c.Operations.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate operations config")
+	}
+
+	if c.Operations.CallbackURL != "" && !c.Admin.Enabled {
+		return errors.New("the admin API must be enabled to receive operation callbacks")
+	}
+
+	err = c.FlowEngine.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate flow engine config")
+	}
+
+	err = c.Admin.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate admin config")
+	}
+
 	err = c.Promalert.Validate()
 	if err != nil {
 		return emperror.Wrap(err, "could not validate promalert config")
@@ -75,6 +154,21 @@ func (c Config) Validate() error {
 		return emperror.Wrap(err, "could not validate healthcheck config")
 	}
 
+	err = c.SpotPoller.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate spot poller config")
+	}
+
+	err = c.Scheduler.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate scheduler config")
+	}
+
+	err = c.Sources.Validate()
+	if err != nil {
+		return emperror.Wrap(err, "could not validate sources config")
+	}
+
 	return nil
 }
 
@@ -101,8 +195,77 @@ func Configure(v *viper.Viper, p *pflag.FlagSet) {
 	v.SetDefault("healthcheck.listenAddress", ":8082")
 	v.SetDefault("healthcheck.endpoint", "/healthz")
 
+	// Internal event bus
+	v.SetDefault("eventBus.workers", 10)
+	v.SetDefault("eventBus.queueSize", 1000)
+	v.SetDefault("eventBus.overflowPolicy", eventbus.OverflowReject)
+	v.SetDefault("eventBus.wal.enabled", false)
+	v.SetDefault("eventBus.wal.path", "data/events.wal")
+	v.SetDefault("eventBus.wal.sync", false)
+
+	// Event deduplication
+	v.SetDefault("dedup.enabled", true)
+	v.SetDefault("dedup.ttl", "5m")
+
+	// Event history
+	v.SetDefault("history.enabled", false)
+	v.SetDefault("history.path", "data/history.db")
+	v.SetDefault("history.retention", "168h")
+
+	// Plugin circuit breakers
+	v.SetDefault("circuitBreaker.enabled", true)
+	v.SetDefault("circuitBreaker.failureThreshold", 5)
+	v.SetDefault("circuitBreaker.openTimeout", "30s")
+	v.SetDefault("circuitBreaker.successThreshold", 1)
+
+	// Long-running plugin operations
+	v.SetDefault("operations.pollInterval", "10s")
+	v.SetDefault("operations.timeout", "1h")
+	v.SetDefault("operations.callbackURL", "")
+
+	// Action flows
+	v.SetDefault("flowEngine.lifecycleEvents", true)
+	v.SetDefault("flowEngine.maxHops", flows.DefaultMaxHops)
+	v.SetDefault("flowEngine.disruptionBudget.enabled", false)
+	v.SetDefault("flowEngine.disruptionBudget.key", flows.DefaultBudgetKey)
+	v.SetDefault("flowEngine.disruptionBudget.window", "1h")
+	v.SetDefault("flowEngine.disruptionBudget.nodeKey", flows.DefaultBudgetNodeKey)
+	v.SetDefault("flowEngine.disruptionBudget.nodeRetention", "24h")
+	v.SetDefault("flowEngine.tenancy.orgKey", flows.DefaultTenantOrgKey)
+	v.SetDefault("flowEngine.tenancy.clusterKey", flows.DefaultTenantClusterKey)
+	v.SetDefault("flowEngine.cooldowns.persist", true)
+	v.SetDefault("flowEngine.cooldowns.path", "data/cooldowns.db")
+
+	// Admin API
+	v.SetDefault("admin.enabled", false)
+	v.SetDefault("admin.listenAddress", ":8083")
+
 	// Prometheus alert handler
 	v.SetDefault("promalert.listenAddress", ":8081")
 	v.SetDefault("promalert.useJWTAuth", false)
 	v.SetDefault("promalert.jwtSigningKey", "")
+
+	// Spot interruption notice poller
+	v.SetDefault("spotPoller.enabled", false)
+	v.SetDefault("spotPoller.interval", "5s")
+	v.SetDefault("spotPoller.timeout", "2s")
+	v.SetDefault("spotPoller.aws.enabled", false)
+	v.SetDefault("spotPoller.aws.endpoint", spotpoller.DefaultAWSEndpoint)
+	v.SetDefault("spotPoller.gcp.enabled", false)
+	v.SetDefault("spotPoller.gcp.endpoint", spotpoller.DefaultGCPEndpoint)
+	v.SetDefault("spotPoller.azure.enabled", false)
+	v.SetDefault("spotPoller.azure.endpoint", spotpoller.DefaultAzureEndpoint)
+
+	// Scheduled events
+	v.SetDefault("scheduler.enabled", false)
+
+	// Flow and plugin definitions
+	v.SetDefault("sources.flowsDir", "")
+	v.SetDefault("sources.pluginsDir", "")
+	v.SetDefault("sources.kubernetes.enabled", false)
+	v.SetDefault("sources.kubernetes.namespace", "")
+	v.SetDefault("sources.kubernetes.interval", "30s")
+	v.SetDefault("sources.kubernetes.host", "")
+	v.SetDefault("sources.kubernetes.tokenFile", sources.DefaultKubernetesTokenFile)
+	v.SetDefault("sources.kubernetes.caFile", sources.DefaultKubernetesCAFile)
 }
@@ -0,0 +1,733 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/banzaicloud/hollowtrees/internal/flows"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// ValidationError is a problem of the configuration at a location of a config file
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", e.Line, e.Column)
+		}
+		b.WriteString(": ")
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+
+	return b.String()
+}
+
+// ValidationErrors holds every problem found in the configuration
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return "invalid configuration:\n" + strings.Join(messages, "\n")
+}
+
+// document describes the keys allowed in the config file
+type document struct {
+	Config `mapstructure:",squash"`
+
+	Flows   flows.FlowConfigs    `mapstructure:"flows"`
+	Plugins plugin.PluginConfigs `mapstructure:"plugins"`
+}
+
+// nolint: gochecknoglobals
+var (
+	documentType = reflect.TypeOf(document{})
+	flowType     = reflect.TypeOf(flows.FlowConfig{})
+	pluginType   = reflect.TypeOf(plugin.PluginConfig{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// ValidateStrict validates the configuration read by the Viper instance and the flow and plugin
+// definitions of the source directories, unlike unmarshaling it rejects unknown keys and values
+// of the wrong type, every problem found is returned in ValidationErrors
+func ValidateStrict(v *viper.Viper) error {
+	val := &validator{
+		flows:   make(map[string]location),
+		plugins: make(map[string]location),
+		tenants: make(map[string]bool),
+	}
+	for name := range v.GetStringMap("flowEngine.tenancy.tenants") {
+		val.tenants[strings.ToLower(name)] = true
+	}
+
+	file := v.ConfigFileUsed()
+	var root *yaml.Node
+	if file != "" && isYAML(file) {
+		root = val.parse(file)
+	}
+	if root != nil {
+		val.walk(file, root, "", documentType)
+	}
+
+	var c Config
+	err := v.Unmarshal(&c)
+	if err != nil {
+		// type mismatches have already been reported with their location
+		if len(val.errs) == 0 {
+			val.add(location{file: file}, "", err.Error())
+		}
+	} else if err := c.Validate(); err != nil {
+		val.add(location{file: file}, "", err.Error())
+	}
+
+	var configs []flowDefinition
+	if root != nil {
+		configs = val.fileDefinitions(file, root)
+	} else {
+		configs = val.viperDefinitions(v, file)
+	}
+
+	if dir := v.GetString("sources.pluginsDir"); dir != "" {
+		val.directory(dir, func(name, path string, node *yaml.Node) {
+			val.walk(path, node, "", pluginType)
+
+			if n := stringValue(node, "name"); n != "" {
+				name = n
+			}
+			val.definePlugin(name, val.location(path, node), "")
+
+			var config plugin.PluginConfig
+			if val.decode(path, node, "", &config) {
+				config.Name = name
+				val.plugin(config, val.location(path, node), "")
+			}
+		})
+	}
+	if dir := v.GetString("sources.flowsDir"); dir != "" {
+		val.directory(dir, func(name, path string, node *yaml.Node) {
+			val.walk(path, node, "", flowType)
+			val.defineFlow(name, val.location(path, node), "")
+
+			configs = append(configs, val.flowNode(name, path, node, node, ""))
+		})
+	}
+
+	// plugin references are checked once every plugin is known
+	for _, f := range configs {
+		for _, r := range f.plugins {
+			if _, ok := val.plugins[r.name]; !ok && r.name != plugin.DemoPluginName {
+				val.add(r.location, r.path, fmt.Sprintf("unknown plugin %q", r.name))
+			}
+		}
+	}
+
+	// tenant overrides are checked once every flow is known
+	for name, t := range c.FlowEngine.Tenancy.Tenants {
+		for id := range t.Overrides {
+			if _, ok := val.flows[strings.ToLower(id)]; !ok {
+				val.add(location{file: file}, joinPath("flowEngine.tenancy.tenants."+name+".overrides", id), fmt.Sprintf("unknown flow %q", id))
+			}
+		}
+	}
+
+	if len(val.errs) > 0 {
+		return val.errs
+	}
+
+	return nil
+}
+
+// location is a position in a config file
+type location struct {
+	file   string
+	line   int
+	column int
+}
+
+// flowDefinition holds the plugins referenced by a flow
+type flowDefinition struct {
+	plugins []pluginReference
+}
+
+type pluginReference struct {
+	name     string
+	location location
+	path     string
+}
+
+type validator struct {
+	errs ValidationErrors
+
+	// where the flows and plugins were first defined
+	flows   map[string]location
+	plugins map[string]location
+
+	// names of the defined tenants
+	tenants map[string]bool
+}
+
+func (val *validator) add(l location, path string, message string) {
+	val.errs = append(val.errs, ValidationError{
+		File:    l.file,
+		Line:    l.line,
+		Column:  l.column,
+		Path:    path,
+		Message: message,
+	})
+}
+
+func (val *validator) location(file string, node *yaml.Node) location {
+	return location{file: file, line: node.Line, column: node.Column}
+}
+
+func (val *validator) parse(file string) *yaml.Node {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		val.add(location{file: file}, "", err.Error())
+		return nil
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(b, &doc)
+	if err != nil {
+		val.add(location{file: file}, "", err.Error())
+		return nil
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	return doc.Content[0]
+}
+
+// walk checks the node against the type it is decoded to
+func (val *validator) walk(file string, node *yaml.Node, path string, t reflect.Type) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	if t == durationType {
+		if val.scalar(file, node, path, "a duration") && node.Tag != "!!int" {
+			if _, err := time.ParseDuration(node.Value); err != nil {
+				val.add(val.location(file, node), path, fmt.Sprintf("invalid duration %q", node.Value))
+			}
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+	case reflect.Ptr:
+		val.walk(file, node, path, t.Elem())
+	case reflect.Struct:
+		val.mapping(file, node, path, func(key *yaml.Node, value *yaml.Node, path string) {
+			if key.Value == "<<" {
+				val.walk(file, value, path, t)
+				return
+			}
+
+			ft, ok := fieldByKey(t, key.Value)
+			if !ok {
+				val.add(val.location(file, key), path, "unknown key")
+				return
+			}
+			val.walk(file, value, path, ft)
+		})
+	case reflect.Map:
+		val.mapping(file, node, path, func(key *yaml.Node, value *yaml.Node, path string) {
+			val.walk(file, value, path, t.Elem())
+		})
+	case reflect.Slice:
+		// a single string is accepted for a list of strings
+		if node.Kind == yaml.ScalarNode && t.Elem().Kind() == reflect.String {
+			return
+		}
+		if node.Kind != yaml.SequenceNode {
+			val.add(val.location(file, node), path, fmt.Sprintf("expected a list, got %s", kindName(node)))
+			return
+		}
+		for i, item := range node.Content {
+			val.walk(file, item, fmt.Sprintf("%s[%d]", path, i), t.Elem())
+		}
+	case reflect.String:
+		val.scalar(file, node, path, "a string")
+	case reflect.Bool:
+		if val.scalar(file, node, path, "a boolean") {
+			if _, err := strconv.ParseBool(node.Value); err != nil {
+				val.add(val.location(file, node), path, fmt.Sprintf("invalid boolean %q", node.Value))
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val.scalar(file, node, path, "an integer") {
+			if _, err := strconv.ParseInt(node.Value, 0, 64); err != nil {
+				val.add(val.location(file, node), path, fmt.Sprintf("invalid integer %q", node.Value))
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val.scalar(file, node, path, "an unsigned integer") {
+			if _, err := strconv.ParseUint(node.Value, 0, 64); err != nil {
+				val.add(val.location(file, node), path, fmt.Sprintf("invalid unsigned integer %q", node.Value))
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if val.scalar(file, node, path, "a number") {
+			if _, err := strconv.ParseFloat(node.Value, 64); err != nil {
+				val.add(val.location(file, node), path, fmt.Sprintf("invalid number %q", node.Value))
+			}
+		}
+	}
+}
+
+func (val *validator) scalar(file string, node *yaml.Node, path string, expected string) bool {
+	if node.Kind != yaml.ScalarNode {
+		val.add(val.location(file, node), path, fmt.Sprintf("expected %s, got %s", expected, kindName(node)))
+		return false
+	}
+
+	return true
+}
+
+// mapping calls fn for the keys of the mapping node, keys are case insensitive like in Viper
+func (val *validator) mapping(file string, node *yaml.Node, path string, fn func(key *yaml.Node, value *yaml.Node, path string)) {
+	if node.Kind != yaml.MappingNode {
+		val.add(val.location(file, node), path, fmt.Sprintf("expected a mapping, got %s", kindName(node)))
+		return
+	}
+
+	seen := make(map[string]bool, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		p := joinPath(path, key.Value)
+
+		k := strings.ToLower(key.Value)
+		if seen[k] {
+			val.add(val.location(file, key), p, "duplicate key")
+			continue
+		}
+		seen[k] = true
+
+		fn(key, value, p)
+	}
+}
+
+// fileDefinitions validates the flows and plugins defined in the config file
+func (val *validator) fileDefinitions(file string, root *yaml.Node) []flowDefinition {
+	var configs []flowDefinition
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch strings.ToLower(key.Value) {
+		case "plugins":
+			if value.Kind != yaml.SequenceNode {
+				continue
+			}
+			for j, node := range value.Content {
+				path := fmt.Sprintf("plugins[%d]", j)
+				val.definePlugin(stringValue(node, "name"), val.location(file, node), path)
+
+				var config plugin.PluginConfig
+				if val.decode(file, node, path, &config) {
+					val.plugin(config, val.location(file, node), path)
+				}
+			}
+		case "flows":
+			if value.Kind != yaml.MappingNode {
+				continue
+			}
+			seen := make(map[string]bool, len(value.Content)/2)
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				id, node := value.Content[j], value.Content[j+1]
+				// duplicate keys have already been reported by walking the node
+				key := strings.ToLower(id.Value)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				path := joinPath("flows", id.Value)
+				val.defineFlow(key, val.location(file, id), path)
+				configs = append(configs, val.flowNode(key, file, id, node, path))
+			}
+		}
+	}
+
+	return configs
+}
+
+// viperDefinitions validates the flows and plugins of config files which are not YAML or JSON,
+// the errors have no line information
+func (val *validator) viperDefinitions(v *viper.Viper, file string) []flowDefinition {
+	var pluginConfigs plugin.PluginConfigs
+	err := v.UnmarshalKey("plugins", &pluginConfigs)
+	if err != nil {
+		val.add(location{file: file}, "plugins", err.Error())
+	}
+	for i, config := range pluginConfigs {
+		val.definePlugin(config.Name, location{file: file}, fmt.Sprintf("plugins[%d]", i))
+		val.plugin(config, location{file: file}, fmt.Sprintf("plugins[%d]", i))
+	}
+
+	var flowConfigs flows.FlowConfigs
+	err = v.UnmarshalKey("flows", &flowConfigs)
+	if err != nil {
+		val.add(location{file: file}, "flows", err.Error())
+	}
+	ids := make([]string, 0, len(flowConfigs))
+	for id := range flowConfigs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	configs := make([]flowDefinition, 0, len(ids))
+	for _, id := range ids {
+		path := joinPath("flows", id)
+		val.defineFlow(id, location{file: file}, path)
+		val.flow(id, flowConfigs[id], location{file: file}, path)
+
+		var definition flowDefinition
+		for _, name := range flowConfigs[id].PluginNames() {
+			definition.plugins = append(definition.plugins, pluginReference{name: name, location: location{file: file}, path: path})
+		}
+		configs = append(configs, definition)
+	}
+
+	return configs
+}
+
+// directory calls fn with the YAML files of the directory, named after the files like the sources do
+func (val *validator) directory(dir string, fn func(name string, path string, node *yaml.Node)) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		val.add(location{file: dir}, "", err.Error())
+		return
+	}
+
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+		node := val.parse(path)
+		if node == nil {
+			continue
+		}
+		fn(strings.TrimSuffix(f.Name(), ext), path, node)
+	}
+}
+
+// decode decodes the node the same way Viper unmarshals the configuration
+func (val *validator) decode(file string, node *yaml.Node, path string, result interface{}) bool {
+	var raw interface{}
+	err := node.Decode(&raw)
+	if err != nil {
+		val.add(val.location(file, node), path, err.Error())
+		return false
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		val.add(val.location(file, node), path, err.Error())
+		return false
+	}
+
+	// type mismatches have already been reported by walking the node
+	return decoder.Decode(raw) == nil
+}
+
+func (val *validator) definePlugin(name string, l location, path string) {
+	if name == "" {
+		return
+	}
+	if first, ok := val.plugins[name]; ok {
+		val.add(l, path, fmt.Sprintf("plugin %q is already defined at %s", name, first))
+		return
+	}
+	val.plugins[name] = l
+}
+
+func (val *validator) defineFlow(id string, l location, path string) {
+	key := strings.ToLower(id)
+	if first, ok := val.flows[key]; ok {
+		val.add(l, path, fmt.Sprintf("flow %q is already defined at %s", id, first))
+		return
+	}
+	val.flows[key] = l
+}
+
+func (val *validator) plugin(config plugin.PluginConfig, l location, path string) {
+	err := config.Validate()
+	if err != nil {
+		val.add(l, path, err.Error())
+	}
+	val.checkTenants(config.Tenants, l, path)
+}
+
+func (val *validator) checkTenants(tenants []string, l location, path string) {
+	for _, t := range tenants {
+		if !val.tenants[strings.ToLower(t)] {
+			val.add(l, path, fmt.Sprintf("unknown tenant %q", t))
+		}
+	}
+}
+
+func (val *validator) flow(id string, config flows.FlowConfig, l location, path string) {
+	err := config.ValidateDefinition(id)
+	if err != nil {
+		val.add(l, path, err.Error())
+	}
+	val.checkTenants(config.Tenants, l, path)
+}
+
+// flowNode validates the flow defined by the node, the plugin references are collected
+// from the node so they are checked even if the flow has values of the wrong type
+func (val *validator) flowNode(id string, file string, key *yaml.Node, node *yaml.Node, path string) flowDefinition {
+	var config flows.FlowConfig
+	if val.decode(file, node, path, &config) {
+		val.flow(id, config, val.location(file, key), path)
+	}
+
+	return flowDefinition{plugins: val.pluginReferences(file, node, path)}
+}
+
+// pluginReferences returns the plugins named in the plugins list and the steps of a flow node
+func (val *validator) pluginReferences(file string, node *yaml.Node, path string) []pluginReference {
+	var refs []pluginReference
+	for _, kv := range mappingValues(node, path) {
+		switch strings.ToLower(kv.key) {
+		case "plugins":
+			refs = append(refs, val.pluginList(file, kv.value, kv.path)...)
+		case "steps":
+			refs = append(refs, val.stepReferences(file, kv.value, kv.path)...)
+		}
+	}
+
+	return refs
+}
+
+func (val *validator) pluginList(file string, node *yaml.Node, path string) []pluginReference {
+	node = resolve(node)
+
+	var refs []pluginReference
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			break
+		}
+		// a single string is split like Viper does
+		for _, name := range strings.Split(node.Value, ",") {
+			if name != "" {
+				refs = append(refs, pluginReference{name: name, location: val.location(file, node), path: path})
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			item = resolve(item)
+			if item.Kind == yaml.ScalarNode && item.Value != "" {
+				refs = append(refs, pluginReference{name: item.Value, location: val.location(file, item), path: fmt.Sprintf("%s[%d]", path, i)})
+			}
+		}
+	}
+
+	return refs
+}
+
+func (val *validator) stepReferences(file string, node *yaml.Node, path string) []pluginReference {
+	node = resolve(node)
+	if node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var refs []pluginReference
+	for i, step := range node.Content {
+		for _, kv := range mappingValues(step, fmt.Sprintf("%s[%d]", path, i)) {
+			value := resolve(kv.value)
+			switch strings.ToLower(kv.key) {
+			case "plugin":
+				if value.Kind == yaml.ScalarNode && value.Value != "" {
+					refs = append(refs, pluginReference{name: value.Value, location: val.location(file, value), path: kv.path})
+				}
+			case "parallel", "onfailure":
+				refs = append(refs, val.stepReferences(file, value, kv.path)...)
+			}
+		}
+	}
+
+	return refs
+}
+
+func (l location) String() string {
+	if l.line == 0 {
+		return l.file
+	}
+
+	return fmt.Sprintf("%s:%d:%d", l.file, l.line, l.column)
+}
+
+// fieldByKey returns the type of the struct field the key is decoded to, fields are matched
+// case insensitively by their mapstructure tag or name
+func fieldByKey(t reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		tag := strings.Split(f.Tag.Get("mapstructure"), ",")
+		if tag[0] != "" {
+			name = tag[0]
+		}
+
+		if f.Anonymous && len(tag) > 1 && tag[1] == "squash" {
+			if ft, ok := fieldByKey(f.Type, key); ok {
+				return ft, true
+			}
+			continue
+		}
+
+		if strings.EqualFold(name, key) {
+			return f.Type, true
+		}
+	}
+
+	return nil, false
+}
+
+type keyValue struct {
+	key   string
+	value *yaml.Node
+	path  string
+}
+
+// mappingValues returns the entries of a mapping node including the merged ones,
+// keys of the node override the merged ones and the first of duplicate keys wins
+func mappingValues(node *yaml.Node, path string) []keyValue {
+	node = resolve(node)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var values, merged []keyValue
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "<<" {
+			merged = append(merged, mappingValues(value, path)...)
+			continue
+		}
+		values = append(values, keyValue{key: key.Value, value: value, path: joinPath(path, key.Value)})
+	}
+
+	unique := make([]keyValue, 0, len(values)+len(merged))
+	seen := make(map[string]bool, len(values)+len(merged))
+	for _, kv := range append(values, merged...) {
+		k := strings.ToLower(kv.key)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, kv)
+	}
+
+	return unique
+}
+
+func resolve(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		return node.Alias
+	}
+
+	return node
+}
+
+// stringValue returns the scalar value of the key of a mapping node
+func stringValue(node *yaml.Node, key string) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value
+		}
+	}
+
+	return ""
+}
+
+func kindName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func isYAML(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
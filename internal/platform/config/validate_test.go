@@ -0,0 +1,370 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// newViper returns a Viper instance of the config file with the default configuration
+func newViper(file string) *viper.Viper {
+	v := viper.New()
+	Configure(v, pflag.NewFlagSet("test", pflag.ContinueOnError))
+	v.SetConfigFile(file)
+
+	return v
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+
+	file := filepath.Join(dir, name)
+	err := ioutil.WriteFile(file, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func mkdirs(t *testing.T, dirs ...string) {
+	t.Helper()
+
+	for _, dir := range dirs {
+		err := os.Mkdir(dir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// validate validates the config file strictly, the file is not read by Viper, so files
+// Viper would reject, eg. with duplicate keys, are validated too
+func validate(t *testing.T, file string, settings map[string]interface{}) ValidationErrors {
+	t.Helper()
+
+	v := newViper(file)
+	for key, value := range settings {
+		v.Set(key, value)
+	}
+
+	err := ValidateStrict(v)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	return errs
+}
+
+// expectErrors checks that every expected error is found, errors are matched by their suffix
+func expectErrors(t *testing.T, errs ValidationErrors, expected ...string) {
+	t.Helper()
+
+	for _, message := range expected {
+		found := false
+		for _, e := range errs {
+			if strings.HasSuffix(e.Error(), message) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected error %q in:\n%v", message, errs)
+		}
+	}
+}
+
+func TestValidateStrictValid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, "config.yaml", `plugins:
+- name: "drain"
+  type: "grpc"
+  address: "localhost:9091"
+flows:
+  drain: &flow
+    name: "drain"
+    plugins: "drain"
+    cooldown: "5m"
+    deferTimeout: 300
+    rateLimit:
+      maxConcurrent: 2
+  drain-copy:
+    <<: *flow
+    disruptive: true
+`)
+
+	v := newViper(file)
+	err = v.ReadInConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ValidateStrict(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateStrictUnknownKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, "config.yaml", `logging:
+  level: "debug"
+plugins:
+- name: "notify"
+  type: "http"
+  http:
+    url: "http://notify"
+    retries: 3
+flows:
+  notify:
+    name: "notify"
+    plugin: "notify"
+    rateLimit:
+      maxConcurrent: 1
+      burst: 2
+`)
+
+	expectErrors(t, validate(t, file, nil),
+		file+":1:1: logging: unknown key",
+		file+":8:5: plugins[0].http.retries: unknown key",
+		file+":12:5: flows.notify.plugin: unknown key",
+		file+":15:7: flows.notify.rateLimit.burst: unknown key",
+	)
+}
+
+func TestValidateStrictDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pluginsDir := filepath.Join(dir, "plugins")
+	flowsDir := filepath.Join(dir, "flows")
+	mkdirs(t, pluginsDir, flowsDir)
+
+	file := writeFile(t, dir, "config.yaml", `plugins:
+- name: "notify"
+  type: "http"
+  http:
+    url: "http://notify"
+- name: "notify"
+  type: "http"
+  http:
+    url: "http://notify"
+    url: "http://other"
+flows:
+  notify:
+    name: "notify"
+    plugins: ["notify"]
+  Notify:
+    name: "notify"
+    plugins: ["notify"]
+`)
+	plugin := writeFile(t, pluginsDir, "drain.yaml", `name: "notify"
+type: "grpc"
+address: "localhost:9091"
+`)
+	flow := writeFile(t, flowsDir, "notify.yml", `name: "notify"
+plugins: ["notify"]
+`)
+
+	expectErrors(t, validate(t, file, map[string]interface{}{"sources.pluginsDir": pluginsDir, "sources.flowsDir": flowsDir}),
+		file+`:6:3: plugins[1]: plugin "notify" is already defined at `+file+":2:3",
+		file+":10:5: plugins[1].http.url: duplicate key",
+		file+":15:3: flows.Notify: duplicate key",
+		plugin+`:1:1: plugin "notify" is already defined at `+file+":2:3",
+		flow+`:1:1: flow "notify" is already defined at `+file+":12:3",
+	)
+}
+
+func TestValidateStrictInvalidValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, "config.yaml", `circuitBreaker:
+  enabled: "maybe"
+  failureThreshold: "many"
+  openTimeout: "a minute"
+plugins:
+  name: "notify"
+flows:
+  notify:
+    name: "notify"
+    cooldown: "5 minutes"
+    plugins:
+      notify: true
+    aggregate:
+      count: 1.5
+      window: [1m]
+`)
+
+	expectErrors(t, validate(t, file, nil),
+		file+`:2:12: circuitBreaker.enabled: invalid boolean "maybe"`,
+		file+`:3:21: circuitBreaker.failureThreshold: invalid integer "many"`,
+		file+`:4:16: circuitBreaker.openTimeout: invalid duration "a minute"`,
+		file+":6:3: plugins: expected a list, got a mapping",
+		file+`:10:15: flows.notify.cooldown: invalid duration "5 minutes"`,
+		file+":12:7: flows.notify.plugins: expected a list, got a mapping",
+		file+`:14:14: flows.notify.aggregate.count: invalid integer "1.5"`,
+		file+":15:15: flows.notify.aggregate.window: expected a duration, got a list",
+	)
+}
+
+func TestValidateStrictDirectories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pluginsDir := filepath.Join(dir, "plugins")
+	flowsDir := filepath.Join(dir, "flows")
+	mkdirs(t, pluginsDir, flowsDir)
+
+	file := writeFile(t, dir, "config.yaml", `plugins:
+- name: "notify"
+  type: "http"
+  http:
+    url: "http://notify"
+`)
+	flow := writeFile(t, flowsDir, "drain.yaml", `name: "drain"
+steps:
+- plugin: "notify"
+- plugin: "scale"
+  timeout: "soon"
+retries: 3
+`)
+	plugin := writeFile(t, pluginsDir, "drain.yml", `type: "grpc"
+grpc:
+  loadBalancing: "random"
+`)
+	writeFile(t, flowsDir, "README.md", "not a flow")
+
+	errs := validate(t, file, map[string]interface{}{"sources.flowsDir": flowsDir, "sources.pluginsDir": pluginsDir})
+	expectErrors(t, errs,
+		flow+`:4:11: steps[1].plugin: unknown plugin "scale"`,
+		flow+`:5:12: steps[1].timeout: invalid duration "soon"`,
+		flow+":6:1: retries: unknown key",
+		plugin+":1:1: address must not be empty for a GRPC plugin",
+	)
+	for _, e := range errs {
+		if strings.Contains(e.File, "README") {
+			t.Errorf("expected only YAML files to be validated, got %v", e)
+		}
+	}
+}
+
+func TestValidateStrictViper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, "config.toml", `[[plugins]]
+name = "notify"
+type = "http"
+
+[flows.notify]
+name = "notify"
+plugins = ["notify", "missing"]
+`)
+
+	v := newViper(file)
+	err = v.ReadInConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ValidateStrict(v)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	// config files which are not YAML are validated once they are unmarshaled, without line information
+	expectErrors(t, errs,
+		file+": plugins[0]: url must not be empty for an HTTP plugin",
+		file+`: flows.notify: unknown plugin "missing"`,
+	)
+}
+
+func TestValidateStrictUnknownPlugins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(file, []byte(`
+plugins:
+  - name: "known"
+    address: "localhost:9091"
+    type: "grpc"
+
+flows:
+  valid:
+    plugins:
+    - "known"
+    - "missing-1"
+  mistyped:
+    cooldown: [1m]
+    steps:
+    - plugin: "known"
+    - parallel:
+      - plugin: "missing-2"
+      onFailure:
+      - plugin: "missing-3"
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := validate(t, file, nil)
+	expectErrors(t, errs,
+		`flows.valid.plugins[1]: unknown plugin "missing-1"`,
+		`flows.mistyped.cooldown: expected a duration, got a list`,
+		`flows.mistyped.steps[1].parallel[0].plugin: unknown plugin "missing-2"`,
+		`flows.mistyped.steps[1].onFailure[0].plugin: unknown plugin "missing-3"`,
+	)
+
+	for _, e := range errs {
+		if strings.Contains(e.Error(), `"known"`) {
+			t.Errorf("unexpected error: %v", e)
+		}
+	}
+}
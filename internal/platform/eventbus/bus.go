@@ -0,0 +1,260 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// ErrQueueFull is returned by Publish when the queue is full and the overflow policy is reject
+var ErrQueueFull = errors.New("event queue is full")
+
+type item struct {
+	topic string
+	args  []interface{}
+
+	// sequence number in the write-ahead log, zero if the item is not persisted
+	seq uint64
+}
+
+type handler struct {
+	fn            reflect.Value
+	transactional bool
+	mu            sync.Mutex
+}
+
+// Bus is an event bus which queues published events and dispatches them with a bounded
+// number of workers, handlers which are not transactional run on their own goroutines
+// so a slow subscriber doesn't hold up the dispatching of other events
+type Bus struct {
+	workers        int
+	queueSize      int
+	overflowPolicy string
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []*item
+	handlers map[string][]*handler
+	wal      *wal
+	started  bool
+
+	logger       log.Logger
+	errorHandler emperror.Handler
+}
+
+// New returns an initialized Bus, events found in the write-ahead log are queued
+// and processed once the bus is started, all of them even if there are more than
+// the queue size, in which case new events are subject to the overflow policy
+// until the queue shrinks
+func New(config Config, logger log.Logger, errorHandler emperror.Handler) (*Bus, error) {
+	b := &Bus{
+		workers:        config.Workers,
+		queueSize:      config.QueueSize,
+		overflowPolicy: config.OverflowPolicy,
+		handlers:       make(map[string][]*handler),
+
+		logger:       logger,
+		errorHandler: errorHandler,
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	if config.WAL.Enabled {
+		wal, records, err := openWAL(config.WAL.Path, config.WAL.Sync)
+		if err != nil {
+			return nil, emperror.WrapWith(err, "could not open write-ahead log", "path", config.WAL.Path)
+		}
+		b.wal = wal
+
+		for _, r := range records {
+			b.queue = append(b.queue, &item{topic: r.Topic, args: []interface{}{r.Event}, seq: r.Seq})
+		}
+
+		if len(records) > 0 {
+			logger.WithField("count", len(records)).Info("replaying events from write-ahead log")
+		}
+	}
+
+	return b, nil
+}
+
+// Start starts the workers
+func (b *Bus) Start() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.started {
+		return
+	}
+	b.started = true
+
+	b.logger.WithFields(log.Fields{"workers": b.workers, "queue-size": b.queueSize, "durable": b.wal != nil}).Info("starting event bus")
+
+	for i := 0; i < b.workers; i++ {
+		go b.work()
+	}
+}
+
+// SubscribeAsync subscribes fn to a topic, fn is called for every event published to the topic,
+// transactional handlers are called one at a time by the worker dispatching the event, others
+// on a goroutine of their own
+func (b *Bus) SubscribeAsync(topic string, fn interface{}, transactional bool) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return emperror.With(errors.New("handler must be a function"), "topic", topic, "type", fmt.Sprintf("%T", fn))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[topic] = append(b.handlers[topic], &handler{
+		fn:            v,
+		transactional: transactional,
+	})
+
+	return nil
+}
+
+// Publish queues an event for the subscribers of the topic, when the queue is full
+// it returns ErrQueueFull or drops the oldest queued event depending on the overflow policy
+func (b *Bus) Publish(topic string, args ...interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.queue) >= b.queueSize {
+		if b.overflowPolicy != OverflowDropOldest {
+			return ErrQueueFull
+		}
+
+		dropped := b.queue[0]
+		b.queue = b.queue[1:]
+		b.logger.WithField("topic", dropped.topic).Warn("event queue is full, dropping oldest event")
+		b.ack(dropped)
+	}
+
+	i := &item{
+		topic: topic,
+		args:  args,
+	}
+
+	if event, ok := durableEvent(args); ok && b.wal != nil {
+		seq, err := b.wal.append(topic, event)
+		if err != nil {
+			return emperror.Wrap(err, "could not persist event")
+		}
+		i.seq = seq
+	}
+
+	b.queue = append(b.queue, i)
+	b.cond.Signal()
+
+	return nil
+}
+
+func (b *Bus) work() {
+	for {
+		b.mu.Lock()
+		for len(b.queue) == 0 {
+			b.cond.Wait()
+		}
+		i := b.queue[0]
+		b.queue = b.queue[1:]
+		handlers := b.handlers[i.topic]
+		b.mu.Unlock()
+
+		b.dispatch(i, handlers)
+	}
+}
+
+// dispatch calls the handlers with the item and acknowledges it once all of them returned
+func (b *Bus) dispatch(i *item, handlers []*handler) {
+	var wg sync.WaitGroup
+	for _, h := range handlers {
+		if h.transactional {
+			b.call(h, i)
+			continue
+		}
+
+		wg.Add(1)
+		go func(h *handler) {
+			defer wg.Done()
+			b.call(h, i)
+		}(h)
+	}
+
+	go func() {
+		wg.Wait()
+		b.ack(i)
+	}()
+}
+
+func (b *Bus) call(h *handler, i *item) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.errorHandler.Handle(emperror.With(errors.Errorf("event handler panicked: %v", r), "topic", i.topic))
+		}
+	}()
+
+	t := h.fn.Type()
+	if t.NumIn() != len(i.args) {
+		b.errorHandler.Handle(emperror.With(errors.New("event handler argument count mismatch"), "topic", i.topic, "expected", t.NumIn(), "got", len(i.args)))
+		return
+	}
+
+	args := make([]reflect.Value, len(i.args))
+	for n, arg := range i.args {
+		if arg == nil {
+			args[n] = reflect.Zero(t.In(n))
+			continue
+		}
+		args[n] = reflect.ValueOf(arg)
+	}
+
+	if h.transactional {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+
+	h.fn.Call(args)
+}
+
+func (b *Bus) ack(i *item) {
+	if i.seq == 0 || b.wal == nil {
+		return
+	}
+
+	err := b.wal.ack(i.seq)
+	if err != nil {
+		b.errorHandler.Handle(emperror.WrapWith(err, "could not acknowledge event", "topic", i.topic))
+	}
+}
+
+// durableEvent returns the event if the arguments can be persisted in the write-ahead log
+func durableEvent(args []interface{}) (*ce.Event, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+
+	event, ok := args[0].(*ce.Event)
+
+	return event, ok && event != nil
+}
@@ -0,0 +1,257 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+func newTestBus(t *testing.T, config Config) *Bus {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+
+	b, err := New(config, logger, emperror.NewNopHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func testEvent(id string) *ce.Event {
+	event := &ce.Event{}
+	event.Set("id", id)
+	event.Set("type", "test")
+
+	return event
+}
+
+// receiver collects the IDs of the handled events
+type receiver struct {
+	mu     sync.Mutex
+	ids    []string
+	events chan string
+}
+
+func newReceiver() *receiver {
+	return &receiver{events: make(chan string, 100)}
+}
+
+func (r *receiver) Handle(event *ce.Event) {
+	r.mu.Lock()
+	r.ids = append(r.ids, event.ID)
+	r.mu.Unlock()
+
+	r.events <- event.ID
+}
+
+func (r *receiver) wait(t *testing.T, count int) []string {
+	for i := 0; i < count; i++ {
+		select {
+		case <-r.events:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d events, got %d", count, i)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// non-transactional handlers run concurrently, so the events are handled in any order
+	ids := append([]string(nil), r.ids...)
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestSlowHandlerDoesNotBlockWorkers(t *testing.T) {
+	b := newTestBus(t, Config{Workers: 1, QueueSize: 10, OverflowPolicy: OverflowReject})
+
+	release := make(chan struct{})
+	defer close(release)
+	err := b.SubscribeAsync("test", func(event *ce.Event) {
+		if event.ID == "slow" {
+			<-release
+		}
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newReceiver()
+	err = b.SubscribeAsync("test", r.Handle, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Start()
+
+	for _, id := range []string{"slow", "1", "2"} {
+		err = b.Publish("test", testEvent(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the single worker dispatches every event while the slow handler is still running
+	ids := r.wait(t, 3)
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 handled events, got %v", ids)
+	}
+}
+
+func TestTransactionalHandler(t *testing.T) {
+	b := newTestBus(t, Config{Workers: 4, QueueSize: 100, OverflowPolicy: OverflowReject})
+
+	var mu sync.Mutex
+	running, overlaps := 0, 0
+	r := newReceiver()
+	err := b.SubscribeAsync("test", func(event *ce.Event) {
+		mu.Lock()
+		running++
+		if running > 1 {
+			overlaps++
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		r.Handle(event)
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Start()
+
+	for i := 0; i < 20; i++ {
+		err = b.Publish("test", testEvent(fmt.Sprint(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.wait(t, 20)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if overlaps > 0 {
+		t.Fatalf("expected the transactional handler to be called one at a time, it overlapped %d times", overlaps)
+	}
+}
+
+func TestOverflowReject(t *testing.T) {
+	b := newTestBus(t, Config{Workers: 1, QueueSize: 2, OverflowPolicy: OverflowReject})
+	r := newReceiver()
+	err := b.SubscribeAsync("test", r.Handle, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		err = b.Publish("test", testEvent(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = b.Publish("test", testEvent("3"))
+	if err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	b.Start()
+	ids := r.wait(t, 2)
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Fatalf("expected the queued events to be handled, got %v", ids)
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	b := newTestBus(t, Config{Workers: 1, QueueSize: 2, OverflowPolicy: OverflowDropOldest})
+	r := newReceiver()
+	err := b.SubscribeAsync("test", r.Handle, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		err = b.Publish("test", testEvent(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b.Start()
+	ids := r.wait(t, 2)
+	if fmt.Sprint(ids) != "[2 3]" {
+		t.Fatalf("expected the oldest event to be dropped, got %v", ids)
+	}
+}
+
+func TestReplayFromWAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := Config{
+		Workers:        1,
+		QueueSize:      1,
+		OverflowPolicy: OverflowDropOldest,
+		WAL:            WALConfig{Enabled: true, Path: filepath.Join(dir, "events.wal")},
+	}
+
+	// events published before a restart without being processed
+	b := newTestBus(t, config)
+	for _, id := range []string{"1", "2"} {
+		_, err = b.wal.append("test", testEvent(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = b.wal.file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every replayed event is queued regardless of the queue size
+	b = newTestBus(t, config)
+	defer b.wal.file.Close()
+	r := newReceiver()
+	err = b.SubscribeAsync("test", r.Handle, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Start()
+
+	ids := r.wait(t, 2)
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Fatalf("expected the logged events to be replayed, got %v", ids)
+	}
+}
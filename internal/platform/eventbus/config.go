@@ -0,0 +1,75 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// OverflowReject rejects new events when the queue is full
+	OverflowReject = "reject"
+	// OverflowDropOldest drops the oldest queued event to make room for the new one
+	OverflowDropOldest = "dropOldest"
+)
+
+// Config holds configuration values for the internal event bus
+type Config struct {
+	// Number of workers processing queued events
+	Workers int
+
+	// Maximum number of queued events
+	QueueSize int
+
+	// What to do with a new event when the queue is full.
+	// Accepted values are: reject, dropOldest
+	OverflowPolicy string
+
+	// Write-ahead log configuration
+	WAL WALConfig
+}
+
+// WALConfig holds configuration values for the write-ahead log
+type WALConfig struct {
+	// Persist accepted events so they are replayed after restart
+	Enabled bool
+
+	// Path of the log file
+	Path string
+
+	// Sync the log file to disk after every write
+	Sync bool
+}
+
+// Validate checks that the configuration is valid.
+func (c Config) Validate() error {
+	if c.Workers < 1 {
+		return errors.New("number of workers must be positive")
+	}
+
+	if c.QueueSize < 1 {
+		return errors.New("queue size must be positive")
+	}
+
+	if c.OverflowPolicy != OverflowReject && c.OverflowPolicy != OverflowDropOldest {
+		return errors.New("invalid overflow policy: " + c.OverflowPolicy)
+	}
+
+	if c.WAL.Enabled && c.WAL.Path == "" {
+		return errors.New("WAL path must be set if WAL is enabled")
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+// compactThreshold is the number of records written after which the log gets
+// rewritten with the pending records only
+const compactThreshold = 1000
+
+type walRecord struct {
+	Seq   uint64    `json:"seq"`
+	Topic string    `json:"topic,omitempty"`
+	Event *ce.Event `json:"event,omitempty"`
+	Ack   bool      `json:"ack,omitempty"`
+}
+
+// wal is an append-only log of accepted and processed events
+type wal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	sync bool
+	seq  uint64
+	// pending holds the encoded records of the not yet acknowledged events
+	pending map[uint64][]byte
+	written int
+}
+
+// openWAL opens the log file at path and returns the records which were
+// accepted but not processed before the last shutdown in the order of acceptance
+func openWAL(path string, sync bool) (*wal, []walRecord, error) {
+	records, err := readWAL(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &wal{
+		path:    path,
+		sync:    sync,
+		pending: make(map[uint64][]byte, len(records)),
+	}
+
+	for _, r := range records {
+		line, err := encodeRecord(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		w.pending[r.Seq] = line
+		if r.Seq > w.seq {
+			w.seq = r.Seq
+		}
+	}
+
+	// rewrite the log with the pending records only
+	err = w.rewrite()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return w, records, nil
+}
+
+// readWAL reads the not yet acknowledged records from the log file at path
+func readWAL(path string) ([]walRecord, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not create WAL directory")
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not open WAL file")
+	}
+	defer f.Close()
+
+	accepted := make(map[uint64]walRecord)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r walRecord
+		// a partially written last line is expected after a crash
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+
+		if r.Ack {
+			delete(accepted, r.Seq)
+			continue
+		}
+
+		if r.Event != nil {
+			accepted[r.Seq] = r
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, emperror.Wrap(err, "could not read WAL file")
+	}
+
+	records := make([]walRecord, 0, len(accepted))
+	for _, r := range accepted {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Seq < records[j].Seq
+	})
+
+	return records, nil
+}
+
+// append persists an accepted event and returns its sequence number
+func (w *wal) append(topic string, event *ce.Event) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line, err := encodeRecord(walRecord{Seq: w.seq + 1, Topic: topic, Event: event})
+	if err != nil {
+		return 0, err
+	}
+
+	err = w.write(line)
+	if err != nil {
+		return 0, err
+	}
+	w.seq++
+	w.pending[w.seq] = line
+
+	return w.seq, nil
+}
+
+// ack marks an event as processed
+func (w *wal) ack(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line, err := encodeRecord(walRecord{Seq: seq, Ack: true})
+	if err != nil {
+		return err
+	}
+
+	err = w.write(line)
+	if err != nil {
+		return err
+	}
+	delete(w.pending, seq)
+
+	// events held back for long keep a few records pending at all times, so the
+	// log is compacted once most of it consists of processed events
+	if w.written >= compactThreshold && w.written >= 2*len(w.pending) {
+		return w.compact()
+	}
+
+	return nil
+}
+
+func (w *wal) write(line []byte) error {
+	_, err := w.file.Write(line)
+	if err != nil {
+		return emperror.Wrap(err, "could not write WAL record")
+	}
+	w.written++
+
+	if w.sync {
+		err = w.file.Sync()
+		if err != nil {
+			return emperror.Wrap(err, "could not sync WAL file")
+		}
+	}
+
+	return nil
+}
+
+// compact replaces the log with one holding the pending records only
+func (w *wal) compact() error {
+	if len(w.pending) == 0 {
+		err := w.file.Truncate(0)
+		if err != nil {
+			return emperror.Wrap(err, "could not truncate WAL file")
+		}
+		w.written = 0
+
+		return nil
+	}
+
+	err := w.file.Close()
+	if err != nil {
+		return emperror.Wrap(err, "could not close WAL file")
+	}
+
+	return w.rewrite()
+}
+
+// rewrite writes the pending records in the order of acceptance to a new file,
+// replaces the log file with it and opens it for appending
+func (w *wal) rewrite() error {
+	seqs := make([]uint64, 0, len(w.pending))
+	for seq := range w.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i] < seqs[j]
+	})
+
+	tmp := w.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return emperror.Wrap(err, "could not create WAL file")
+	}
+
+	buf := bufio.NewWriter(f)
+	for _, seq := range seqs {
+		_, err = buf.Write(w.pending[seq])
+		if err != nil {
+			f.Close()
+			return emperror.WrapWith(err, "could not write WAL record", "seq", seq)
+		}
+	}
+	err = buf.Flush()
+	if err == nil && w.sync {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return emperror.Wrap(err, "could not write WAL file")
+	}
+	err = f.Close()
+	if err != nil {
+		return emperror.Wrap(err, "could not write WAL file")
+	}
+
+	err = os.Rename(tmp, w.path)
+	if err != nil {
+		return emperror.Wrap(err, "could not replace WAL file")
+	}
+
+	w.file, err = os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return emperror.Wrap(err, "could not open WAL file")
+	}
+	w.written = len(seqs)
+
+	return nil
+}
+
+// encodeRecord returns the log line of a record
+func encodeRecord(r walRecord) ([]byte, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not marshal WAL record", "seq", r.Seq)
+	}
+
+	return append(b, '\n'), nil
+}
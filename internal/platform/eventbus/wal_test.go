@@ -0,0 +1,100 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+func TestWALCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.wal")
+	w, records, err := openWAL(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected an empty log, got %d records", len(records))
+	}
+
+	// the first events stay pending while many others get processed
+	var held []uint64
+	for i := 0; i < 3*compactThreshold; i++ {
+		event := &ce.Event{}
+		event.Set("id", fmt.Sprintf("event-%d", i))
+		event.Set("type", "test")
+
+		seq, err := w.append("test", event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 3 {
+			held = append(held, seq)
+			continue
+		}
+
+		err = w.ack(seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w.written >= compactThreshold {
+		t.Errorf("expected the log to be compacted, it has %d records", w.written)
+	}
+
+	err = w.file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, records, err = openWAL(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.file.Close()
+
+	if len(records) != len(held) {
+		t.Fatalf("expected %d pending records, got %d", len(held), len(records))
+	}
+	for i, r := range records {
+		if r.Seq != held[i] {
+			t.Errorf("expected record %d to have seq %d, got %d", i, held[i], r.Seq)
+		}
+		if id := r.Event.ID; id != fmt.Sprintf("event-%d", i) {
+			t.Errorf("expected record %d to hold event-%d, got %s", i, i, id)
+		}
+	}
+
+	// new events don't reuse the sequence numbers of pending ones
+	seq, err := w.append("test", &ce.Event{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq <= held[len(held)-1] {
+		t.Errorf("expected seq above %d, got %d", held[len(held)-1], seq)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// API serves the plugin status endpoints
+type API struct {
+	manager *Manager
+}
+
+// NewAPI returns an initialized API
+func NewAPI(manager *Manager) *API {
+	return &API{
+		manager: manager,
+	}
+}
+
+// RegisterRoutes registers the plugin endpoints
+func (a *API) RegisterRoutes(r gin.IRouter) {
+	r.GET("/plugins", a.listPlugins)
+	r.POST("/plugins/:name/operations/:token/:id", a.completeOperation)
+}
+
+func (a *API) listPlugins(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   a.manager.Status(),
+	})
+}
+
+// completeOperation receives the final state of a long-running operation from a plugin at the callback URL of the plugin call
+func (a *API) completeOperation(c *gin.Context) {
+	name := c.Param("name")
+	if _, err := a.manager.GetByName(name); err != nil {
+		a.abort(c, http.StatusNotFound, "plugin not found", err)
+		return
+	}
+
+	var s OperationStatus
+	err := c.ShouldBindJSON(&s)
+	if err == nil {
+		err = a.manager.CompleteOperation(name, c.Param("token"), c.Param("id"), s)
+	}
+	if errors.Cause(err) == ErrInvalidCallbackToken {
+		a.abort(c, http.StatusForbidden, "invalid callback token", err)
+		return
+	}
+	if err != nil {
+		a.abort(c, http.StatusBadRequest, "invalid operation state", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": http.StatusAccepted,
+	})
+}
+
+func (a *API) abort(c *gin.Context, code int, message string, err error) {
+	c.AbortWithStatusJSON(code, gin.H{
+		"status":  code,
+		"message": message,
+		"error":   err.Error(),
+	})
+}
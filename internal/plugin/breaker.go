@@ -0,0 +1,247 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+// BreakerState is the state of a plugin circuit breaker
+type BreakerState string
+
+const (
+	// BreakerClosed lets every call through
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects every call until the open timeout passes
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets trial calls through to decide whether the plugin recovered
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// ErrCircuitOpen is returned for calls of a plugin whose circuit breaker is open
+var ErrCircuitOpen = errors.New("plugin circuit breaker is open")
+
+// actionError marks the error of a call which reached the plugin, eg. a command exiting
+// with a non-zero code or a webhook rejecting the event, it fails the flow step but the
+// plugin is healthy, so it does not count as a failure of the circuit breaker
+type actionError struct {
+	error
+}
+
+func (e *actionError) Cause() error {
+	return e.error
+}
+
+// actionFailed marks the error as a failed action of a healthy plugin
+func actionFailed(err error) error {
+	return &actionError{err}
+}
+
+// isActionFailure checks whether the error or any of its causes is a failed action
+func isActionFailure(err error) bool {
+	for err != nil {
+		if _, ok := err.(*actionError); ok {
+			return true
+		}
+
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = cause.Cause()
+	}
+
+	return false
+}
+
+// BreakerConfig holds the thresholds of the plugin circuit breakers
+type BreakerConfig struct {
+	Enabled bool
+
+	// Consecutive failures opening the breaker
+	FailureThreshold int
+
+	// Time after an open breaker lets trial calls through
+	OpenTimeout time.Duration
+
+	// Consecutive successful trial calls closing a half-open breaker
+	SuccessThreshold int
+}
+
+// Validate validates the circuit breaker configuration
+func (c BreakerConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.FailureThreshold < 1 {
+		return errors.New("failure threshold must be at least 1")
+	}
+
+	if c.OpenTimeout <= 0 {
+		return errors.New("open timeout must be positive")
+	}
+
+	if c.SuccessThreshold < 1 {
+		return errors.New("success threshold must be at least 1")
+	}
+
+	return nil
+}
+
+// BreakerStatus describes the current state of a plugin circuit breaker
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	OpenedAt  *time.Time   `json:"openedAt,omitempty"`
+	RetryAt   *time.Time   `json:"retryAt,omitempty"`
+	Successes uint64       `json:"successes"`
+	Errors    uint64       `json:"errors"`
+	Rejected  uint64       `json:"rejected"`
+}
+
+// breaker is a circuit breaker of a single plugin
+type breaker struct {
+	config BreakerConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	probing   bool
+	openedAt  time.Time
+
+	// call counters exposed as metrics
+	succeeded uint64
+	failed    uint64
+	rejected  uint64
+}
+
+func newBreaker(config BreakerConfig, now func() time.Time) *breaker {
+	return &breaker{
+		config: config,
+		now:    now,
+		state:  BreakerClosed,
+	}
+}
+
+// allow reports whether a call can go through, a half-open breaker lets one trial call through at a time
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.config.OpenTimeout)) {
+		b.state = BreakerHalfOpen
+		b.successes = 0
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		b.rejected++
+		return false
+	case BreakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			return false
+		}
+		b.probing = true
+	}
+
+	return true
+}
+
+// available reports whether a call would be let through without counting it
+func (b *breaker) available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state != BreakerOpen || !b.now().Before(b.openedAt.Add(b.config.OpenTimeout))
+}
+
+// done records the outcome of a call which was let through, failed actions
+// of the plugin count as successful calls
+func (b *breaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err != nil && !isActionFailure(err) {
+		b.failed++
+		b.failures++
+		b.successes = 0
+		if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+		return
+	}
+
+	b.succeeded++
+	b.failures = 0
+	if b.state == BreakerHalfOpen {
+		b.successes++
+		if b.successes >= b.config.SuccessThreshold {
+			b.state = BreakerClosed
+		}
+	}
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerStatus{
+		State:     b.state,
+		Failures:  b.failures,
+		Successes: b.succeeded,
+		Errors:    b.failed,
+		Rejected:  b.rejected,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		retryAt := b.openedAt.Add(b.config.OpenTimeout)
+		s.OpenedAt = &openedAt
+		s.RetryAt = &retryAt
+	}
+
+	return s
+}
+
+// breakerPlugin guards a plugin with a circuit breaker
+type breakerPlugin struct {
+	EventHandlerPlugin
+	breaker *breaker
+}
+
+// Handle calls the plugin unless its circuit breaker is open
+func (p *breakerPlugin) Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error) {
+	if !p.breaker.allow() {
+		return nil, emperror.With(ErrCircuitOpen, "plugin", p.GetName())
+	}
+
+	result, err := p.EventHandlerPlugin.Handle(ctx, event, params)
+	p.breaker.done(err)
+
+	return result, err
+}
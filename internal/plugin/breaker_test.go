@@ -0,0 +1,160 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+// manualClock is the time of the breakers under test, it only moves when advanced
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+var testBreakerConfig = BreakerConfig{ // nolint: gochecknoglobals
+	Enabled:          true,
+	FailureThreshold: 2,
+	OpenTimeout:      time.Minute,
+	SuccessThreshold: 2,
+}
+
+func TestBreakerOpens(t *testing.T) {
+	c := &manualClock{now: time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)}
+	b := newBreaker(testBreakerConfig, c.Now)
+
+	b.allow()
+	b.done(errors.New("unavailable"))
+	if b.status().State != BreakerClosed {
+		t.Fatal("expected the breaker to stay closed below the failure threshold")
+	}
+
+	// a success resets the consecutive failures
+	b.allow()
+	b.done(nil)
+	b.allow()
+	b.done(errors.New("unavailable"))
+	if b.status().State != BreakerClosed {
+		t.Fatal("expected only consecutive failures to open the breaker")
+	}
+
+	b.allow()
+	b.done(errors.New("unavailable"))
+	s := b.status()
+	if s.State != BreakerOpen {
+		t.Fatalf("expected the breaker to open, got %s", s.State)
+	}
+	if !s.RetryAt.Equal(c.now.Add(time.Minute)) {
+		t.Fatalf("expected a retry after the open timeout, got %s", s.RetryAt)
+	}
+
+	if b.allow() || b.available() {
+		t.Fatal("expected an open breaker to reject calls")
+	}
+	if s := b.status(); s.Rejected != 1 || s.Errors != 3 || s.Successes != 1 {
+		t.Fatalf("unexpected call counters: %+v", s)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	c := &manualClock{now: time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)}
+	b := newBreaker(testBreakerConfig, c.Now)
+	for i := 0; i < 2; i++ {
+		b.allow()
+		b.done(errors.New("unavailable"))
+	}
+
+	c.now = c.now.Add(time.Minute)
+	if !b.available() {
+		t.Fatal("expected the breaker to be available after the open timeout")
+	}
+
+	// a failed trial call opens the breaker again
+	if !b.allow() {
+		t.Fatal("expected a trial call to be let through")
+	}
+	if b.status().State != BreakerHalfOpen {
+		t.Fatalf("expected a half-open breaker, got %s", b.status().State)
+	}
+	if b.allow() {
+		t.Fatal("expected a single trial call at a time")
+	}
+	b.done(errors.New("unavailable"))
+	if b.status().State != BreakerOpen || b.available() {
+		t.Fatal("expected a failed trial call to open the breaker again")
+	}
+
+	c.now = c.now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if !b.allow() {
+			t.Fatalf("expected trial call %d to be let through", i+1)
+		}
+		b.done(nil)
+	}
+	if s := b.status(); s.State != BreakerClosed || s.OpenedAt != nil {
+		t.Fatalf("expected successful trial calls to close the breaker, got %+v", s)
+	}
+}
+
+type failingPlugin struct {
+	err error
+}
+
+func (p *failingPlugin) GetName() string {
+	return "failing"
+}
+
+func (p *failingPlugin) Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error) {
+	return nil, p.err
+}
+
+func TestBreakerActionFailures(t *testing.T) {
+	c := &manualClock{now: time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)}
+	failing := &failingPlugin{err: actionFailed(errors.New("command failed"))}
+	p := &breakerPlugin{EventHandlerPlugin: failing, breaker: newBreaker(testBreakerConfig, c.Now)}
+
+	for i := 0; i < 3; i++ {
+		_, err := p.Handle(context.Background(), testEvent(nil), nil)
+		if err == nil || errors.Cause(err) == ErrCircuitOpen {
+			t.Fatalf("expected the error of the plugin, got %v", err)
+		}
+	}
+	if s := p.breaker.status(); s.State != BreakerClosed || s.Errors != 0 {
+		t.Fatalf("expected failed actions not to count as failures, got %+v", s)
+	}
+
+	// the failed action is recognized with context added to the error
+	failing.err = emperror.With(emperror.Wrap(actionFailed(errors.New("command failed")), "step failed"), "plugin", "failing")
+	_, _ = p.Handle(context.Background(), testEvent(nil), nil)
+
+	failing.err = errors.New("connection refused")
+	for i := 0; i < 2; i++ {
+		_, _ = p.Handle(context.Background(), testEvent(nil), nil)
+	}
+	_, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err == nil || errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("expected the breaker to open, got %v", err)
+	}
+}
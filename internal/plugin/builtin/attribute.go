@@ -0,0 +1,62 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// nolint: gochecknoinits
+func init() {
+	plugin.Register("set-attribute", newSetAttributePlugin)
+}
+
+type setAttributeConfig struct {
+	Attributes map[string]string `mapstructure:"attributes"`
+}
+
+type setAttributePlugin struct {
+	plugin.BasePlugin
+	config setAttributeConfig
+}
+
+func newSetAttributePlugin(name string, config map[string]interface{}, deps plugin.Dependencies) (plugin.EventHandlerPlugin, error) {
+	var c setAttributeConfig
+	err := plugin.DecodeConfig(config, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &setAttributePlugin{
+		BasePlugin: plugin.NewBasePlugin(name),
+		config:     c,
+	}, nil
+}
+
+// Handle sets the configured attributes and the step parameters as attributes
+// of the event, so the subsequent steps of the flow can use them
+func (p *setAttributePlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	for k, v := range p.config.Attributes {
+		event.Set(k, v)
+	}
+	for k, v := range params {
+		event.Set(k, v)
+	}
+
+	return &plugin.Result{Status: "ok"}, nil
+}
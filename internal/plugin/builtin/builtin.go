@@ -0,0 +1,40 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package builtin contains internal plugins compiled into Hollowtrees, they are
+// registered in the plugin registry when the package is imported.
+package builtin
+
+import (
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// setTenant copies the attributes identifying the tenant of the handled event to the published
+// event, the parameters must not set them, so flows can not publish events of other tenants
+func setTenant(keys []string, event *ce.Event, e *ce.Event, params plugin.Params) error {
+	for _, k := range keys {
+		if _, ok := params[k]; ok {
+			return emperror.With(errors.New("parameters must not set the tenant of the event"), "param", k)
+		}
+		if v, ok := event.Get(k); ok {
+			e.Set(k, v)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// testPublisher collects the published events
+type testPublisher struct {
+	mu     sync.Mutex
+	events []*ce.Event
+}
+
+func (p *testPublisher) Publish(event *ce.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+
+	return nil
+}
+
+func newTestManager(publisher plugin.EventPublisher) *plugin.Manager {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+
+	return plugin.NewManager(logger, emperror.NewNopHandler(), plugin.WithEventPublisher(publisher))
+}
+
+func newTestPlugin(t *testing.T, implementation string, config map[string]interface{}) plugin.EventHandlerPlugin {
+	t.Helper()
+
+	p, err := newTestManager(&testPublisher{}).NewInternalPlugin(implementation, implementation, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func testEvent(attributes map[string]interface{}) *ce.Event {
+	now := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	source, _ := url.Parse("http://prometheus")
+
+	event := &ce.Event{}
+	event.Set("id", "event-1")
+	event.Set("type", "prometheus.server.alert.NodeNotReady")
+	event.Set("specversion", "0.2")
+	event.Set("source", *source)
+	event.Set("time", &now)
+	for k, v := range attributes {
+		event.Set(k, v)
+	}
+
+	return event
+}
+
+func TestRegisteredImplementations(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, name := range plugin.Implementations() {
+		registered[name] = true
+	}
+
+	for _, name := range []string{"log", "delay", "set-attribute", "publish-event"} {
+		if !registered[name] {
+			t.Errorf("expected the %s implementation to be registered", name)
+		}
+	}
+}
+
+func TestSetAttributePlugin(t *testing.T) {
+	p := newTestPlugin(t, "set-attribute", map[string]interface{}{
+		"attributes": map[string]interface{}{"drained": "true", "team": "ops"},
+	})
+
+	event := testEvent(nil)
+	_, err := p.Handle(context.Background(), event, plugin.Params{"team": "sre"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// parameters override the configured attributes
+	for k, expected := range map[string]string{"drained": "true", "team": "sre"} {
+		if v, _ := event.GetString(k); v != expected {
+			t.Errorf("expected attribute %s to be %q, got %q", k, expected, v)
+		}
+	}
+}
+
+func TestDelayPlugin(t *testing.T) {
+	p := newTestPlugin(t, "delay", map[string]interface{}{"duration": "10ms"})
+
+	start := time.Now()
+	_, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Fatalf("expected the configured delay, it took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Handle(ctx, testEvent(nil), plugin.Params{"duration": "1h"})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the delay of the parameter to be cut by the context, got %v", err)
+	}
+
+	_, err = p.Handle(context.Background(), testEvent(nil), plugin.Params{"duration": "soon"})
+	if err == nil {
+		t.Fatal("expected an invalid duration parameter to fail")
+	}
+
+	_, err = newTestManager(&testPublisher{}).NewInternalPlugin("delay", "delay", map[string]interface{}{"duration": "-1s"})
+	if err == nil {
+		t.Fatal("expected a negative duration to be rejected")
+	}
+}
+
+func TestLogPlugin(t *testing.T) {
+	p := newTestPlugin(t, "log", map[string]interface{}{"level": "warn"})
+
+	result, err := p.Handle(context.Background(), testEvent(nil), plugin.Params{"message": "draining"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "ok" {
+		t.Fatalf("unexpected status: %q", result.Status)
+	}
+
+	_, err = newTestManager(&testPublisher{}).NewInternalPlugin("log", "log", map[string]interface{}{"level": "trace"})
+	if err == nil {
+		t.Fatal("expected an invalid level to be rejected")
+	}
+}
+
+func TestPublishEventPlugin(t *testing.T) {
+	publisher := &testPublisher{}
+	p, err := newTestManager(publisher).NewInternalPlugin("publish", "publish-event", map[string]interface{}{
+		"type": "hollowtrees.node.drained",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := testEvent(map[string]interface{}{
+		"cluster_id":        "1",
+		ce.CorrelationIDKey: "cid",
+		ce.FingerprintKey:   "fingerprint",
+	})
+	result, err := p.Handle(context.Background(), event, plugin.Params{"node": "i-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(publisher.events) != 1 {
+		t.Fatalf("expected 1 published event, got %d", len(publisher.events))
+	}
+	e := publisher.events[0]
+	if e.Type != "hollowtrees.node.drained" || e.ID != result.Output["id"] || e.ID == event.ID {
+		t.Fatalf("unexpected event: %s %s", e.Type, e.ID)
+	}
+	for k, expected := range map[string]string{"cluster_id": "1", "node": "i-1", ce.CorrelationIDKey: "cid"} {
+		if v, _ := e.GetString(k); v != expected {
+			t.Errorf("expected attribute %s to be %q, got %q", k, expected, v)
+		}
+	}
+	if _, ok := e.Get(ce.FingerprintKey); ok {
+		t.Error("expected the fingerprint not to be copied")
+	}
+	if e.Hops() != 1 {
+		t.Errorf("expected the event to be one hop away, got %d", e.Hops())
+	}
+
+	_, err = newTestManager(publisher).NewInternalPlugin("publish", "publish-event", nil)
+	if err == nil {
+		t.Fatal("expected the type to be required")
+	}
+}
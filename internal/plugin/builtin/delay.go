@@ -0,0 +1,80 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// nolint: gochecknoinits
+func init() {
+	plugin.Register("delay", newDelayPlugin)
+}
+
+type delayConfig struct {
+	Duration time.Duration `mapstructure:"duration"`
+}
+
+type delayPlugin struct {
+	plugin.BasePlugin
+	config delayConfig
+}
+
+func newDelayPlugin(name string, config map[string]interface{}, deps plugin.Dependencies) (plugin.EventHandlerPlugin, error) {
+	var c delayConfig
+	err := plugin.DecodeConfig(config, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Duration < 0 {
+		return nil, errors.New("duration must not be negative")
+	}
+
+	return &delayPlugin{
+		BasePlugin: plugin.NewBasePlugin(name),
+		config:     c,
+	}, nil
+}
+
+// Handle waits for the configured duration before the next step of the flow,
+// the `duration` parameter overrides the configured value
+func (p *delayPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	d := p.config.Duration
+	if v, ok := params["duration"]; ok {
+		var err error
+		d, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, emperror.WrapWith(err, "invalid duration parameter", "duration", v)
+		}
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return &plugin.Result{Status: "ok"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
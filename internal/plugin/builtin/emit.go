@@ -0,0 +1,109 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// nolint: gochecknoinits
+func init() {
+	plugin.Register("emit", newEmitPlugin)
+}
+
+type emitConfig struct {
+	Type   string `mapstructure:"type"`
+	Source string `mapstructure:"source"`
+}
+
+type emitPlugin struct {
+	plugin.BasePlugin
+	config     emitConfig
+	source     *url.URL
+	publisher  plugin.EventPublisher
+	clock      clock.Clock
+	tenantKeys []string
+}
+
+func newEmitPlugin(name string, config map[string]interface{}, deps plugin.Dependencies) (plugin.EventHandlerPlugin, error) {
+	c := emitConfig{
+		Source: "/hollowtrees/flows",
+	}
+	err := plugin.DecodeConfig(config, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := url.Parse(c.Source)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "invalid source", "source", c.Source)
+	}
+
+	return &emitPlugin{
+		BasePlugin: plugin.NewBasePlugin(name),
+		config:     c,
+		source:     source,
+		publisher:  deps.Publisher,
+		clock:      deps.Clock,
+		tenantKeys: deps.TenantKeys,
+	}, nil
+}
+
+// Handle emits an event to chain flows, the type can be overridden by the `type`
+// parameter, the other parameters become attributes of the emitted event which
+// refers to the handled event and keeps its correlation ID and tenant
+func (p *emitPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	eventType := p.config.Type
+	if t, ok := params["type"]; ok {
+		eventType = t
+	}
+	if eventType == "" {
+		return nil, errors.New("event type must be set in config or params")
+	}
+
+	e := event.Derive(eventType, *p.source, p.clock.Now())
+	err := setTenant(p.tenantKeys, event, e, params)
+	if err != nil {
+		return nil, err
+	}
+	e.Set("origin_event_id", event.ID)
+	e.Set("origin_event_type", event.Type)
+	for k, v := range params {
+		if k == "type" {
+			continue
+		}
+		e.Set(k, v)
+	}
+
+	err = p.publisher.Publish(e)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not emit event", "type", e.Type)
+	}
+
+	return &plugin.Result{
+		Status: "ok",
+		Output: map[string]string{
+			"id": e.ID,
+		},
+	}, nil
+}
@@ -0,0 +1,156 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// fixedClock is a clock stopped at a point in time
+type fixedClock struct {
+	clock.Clock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestEmitPlugin(t *testing.T) {
+	publisher := &testPublisher{}
+	p, err := newTestManager(publisher).NewInternalPlugin("emit", "emit", map[string]interface{}{
+		"type":   "hollowtrees.node.drained",
+		"source": "/drainer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := testEvent(map[string]interface{}{ce.CorrelationIDKey: "cid", ce.HopsKey: 2})
+	result, err := p.Handle(context.Background(), event, plugin.Params{"node": "i-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(publisher.events) != 1 {
+		t.Fatalf("expected 1 emitted event, got %d", len(publisher.events))
+	}
+	e := publisher.events[0]
+	if e.Type != "hollowtrees.node.drained" || e.Source.String() != "/drainer" {
+		t.Fatalf("unexpected event: %s from %s", e.Type, e.Source.String())
+	}
+	if e.ID == event.ID || e.ID != result.Output["id"] {
+		t.Fatalf("expected a new event ID in the result, got %s", e.ID)
+	}
+	for k, expected := range map[string]string{
+		"node":              "i-1",
+		"origin_event_id":   event.ID,
+		"origin_event_type": event.Type,
+		ce.CorrelationIDKey: "cid",
+	} {
+		if v, _ := e.GetString(k); v != expected {
+			t.Errorf("expected attribute %s to be %q, got %q", k, expected, v)
+		}
+	}
+	if e.Hops() != 3 {
+		t.Errorf("expected the hop counter to be incremented, got %d", e.Hops())
+	}
+}
+
+func TestEmitPluginType(t *testing.T) {
+	publisher := &testPublisher{}
+	p, err := newTestManager(publisher).NewInternalPlugin("emit", "emit", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Handle(context.Background(), testEvent(nil), nil)
+	if err == nil {
+		t.Fatal("expected an event without a type to fail")
+	}
+
+	_, err = p.Handle(context.Background(), testEvent(nil), plugin.Params{"type": "hollowtrees.custom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := publisher.events[0]
+	if e.Type != "hollowtrees.custom" {
+		t.Fatalf("expected the type of the parameter, got %s", e.Type)
+	}
+	attributes, err := e.Attributes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := attributes["type"]; ok {
+		t.Fatal("expected the type parameter not to become an attribute")
+	}
+	if _, ok := e.GetString(ce.CorrelationIDKey); !ok {
+		t.Fatal("expected a correlation ID to be generated")
+	}
+}
+
+func TestEmitPluginTenant(t *testing.T) {
+	publisher := &testPublisher{}
+	now := time.Date(2019, 8, 1, 12, 30, 0, 0, time.UTC)
+	m := plugin.NewManager(log.NewLogger(log.Config{Format: "logfmt", Level: "error"}), emperror.NewNopHandler(),
+		plugin.WithEventPublisher(publisher),
+		plugin.WithClock(fixedClock{Clock: clock.New(), now: now}),
+		plugin.WithTenantKeys("org", "cluster"),
+	)
+
+	configs := map[string]map[string]interface{}{
+		"emit":          {"type": "hollowtrees.node.drained"},
+		"publish-event": {"type": "hollowtrees.node.drained", "copyAttributes": false},
+	}
+	for implementation, config := range configs {
+		publisher.events = nil
+		p, err := m.NewInternalPlugin(implementation, implementation, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event := testEvent(map[string]interface{}{"org": "1", "cluster": "10", "node": "i-1"})
+		_, err = p.Handle(context.Background(), event, plugin.Params{"org": "2"})
+		if err == nil || len(publisher.events) != 0 {
+			t.Fatalf("%s: expected parameters setting the tenant to be rejected", implementation)
+		}
+
+		_, err = p.Handle(context.Background(), event, plugin.Params{"reason": "drained"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := publisher.events[0]
+		for k, expected := range map[string]string{"org": "1", "cluster": "10", "reason": "drained"} {
+			if v, _ := e.GetString(k); v != expected {
+				t.Errorf("%s: expected attribute %s to be %q, got %q", implementation, k, expected, v)
+			}
+		}
+		if _, ok := e.Get("node"); ok {
+			t.Errorf("%s: expected only the tenant attributes to be copied", implementation)
+		}
+		if e.Time == nil || !e.Time.Equal(now) {
+			t.Errorf("%s: expected the time of the manager clock, got %v", implementation, e.Time)
+		}
+	}
+}
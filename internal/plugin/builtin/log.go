@@ -0,0 +1,100 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// nolint: gochecknoinits
+func init() {
+	plugin.Register("log", newLogPlugin)
+}
+
+type logConfig struct {
+	// debug, info, warn or error
+	Level   string `mapstructure:"level"`
+	Message string `mapstructure:"message"`
+}
+
+type logPlugin struct {
+	plugin.BasePlugin
+	config logConfig
+	logger log.Logger
+}
+
+func newLogPlugin(name string, config map[string]interface{}, deps plugin.Dependencies) (plugin.EventHandlerPlugin, error) {
+	c := logConfig{
+		Level:   "info",
+		Message: "event received",
+	}
+	err := plugin.DecodeConfig(config, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return nil, emperror.With(errors.New("invalid log level"), "level", c.Level)
+	}
+
+	return &logPlugin{
+		BasePlugin: plugin.NewBasePlugin(name),
+		config:     c,
+		logger:     deps.Logger,
+	}, nil
+}
+
+// Handle logs the event, the `message` parameter overrides the configured message
+func (p *logPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	fields := log.Fields{
+		"type":     event.Type,
+		"event-id": event.ID,
+	}
+	if cid, ok := event.GetString("correlationid"); ok {
+		fields["correlation-id"] = cid
+	}
+
+	message := p.config.Message
+	for k, v := range params {
+		if k == "message" {
+			message = v
+			continue
+		}
+		fields[k] = v
+	}
+
+	logger := p.logger.WithFields(fields)
+	switch p.config.Level {
+	case "debug":
+		logger.Debug(message)
+	case "warn":
+		logger.Warn(message)
+	case "error":
+		logger.Error(message)
+	default:
+		logger.Info(message)
+	}
+
+	return &plugin.Result{Status: "ok"}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// nolint: gochecknoinits
+func init() {
+	plugin.Register("publish-event", newPublishEventPlugin)
+}
+
+type publishEventConfig struct {
+	Type   string `mapstructure:"type"`
+	Source string `mapstructure:"source"`
+	// Copy the attributes of the handled event to the new event
+	CopyAttributes bool `mapstructure:"copyAttributes"`
+}
+
+type publishEventPlugin struct {
+	plugin.BasePlugin
+	config     publishEventConfig
+	source     *url.URL
+	publisher  plugin.EventPublisher
+	clock      clock.Clock
+	tenantKeys []string
+}
+
+func newPublishEventPlugin(name string, config map[string]interface{}, deps plugin.Dependencies) (plugin.EventHandlerPlugin, error) {
+	c := publishEventConfig{
+		Source:         "/hollowtrees/flows",
+		CopyAttributes: true,
+	}
+	err := plugin.DecodeConfig(config, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Type == "" {
+		return nil, errors.New("type must be set")
+	}
+
+	source, err := url.Parse(c.Source)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "invalid source", "source", c.Source)
+	}
+
+	return &publishEventPlugin{
+		BasePlugin: plugin.NewBasePlugin(name),
+		config:     c,
+		source:     source,
+		publisher:  deps.Publisher,
+		clock:      deps.Clock,
+		tenantKeys: deps.TenantKeys,
+	}, nil
+}
+
+// Handle publishes a new event of the configured type to be processed by the action
+// flows, the step parameters are set as attributes of the new event, which keeps the tenant of the handled event
+func (p *publishEventPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	e := event.Derive(p.config.Type, *p.source, p.clock.Now())
+	err := setTenant(p.tenantKeys, event, e, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.CopyAttributes {
+		attributes, err := event.Attributes()
+		if err != nil {
+			return nil, emperror.Wrap(err, "could not get event attributes")
+		}
+		for k := range attributes {
+			// the new event describes a different occurrence
+			if k == ce.FingerprintKey || k == ce.HopsKey || k == ce.CorrelationIDKey {
+				continue
+			}
+			if v, ok := event.Get(k); ok {
+				e.Set(k, v)
+			}
+		}
+	}
+	for k, v := range params {
+		e.Set(k, v)
+	}
+
+	err = p.publisher.Publish(e)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not publish event", "type", e.Type)
+	}
+
+	return &plugin.Result{
+		Status: "ok",
+		Output: map[string]string{
+			"id": e.ID,
+		},
+	}, nil
+}
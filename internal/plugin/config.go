@@ -15,6 +15,8 @@
 package plugin
 
 import (
+	"time"
+
 	"github.com/goph/emperror"
 	"github.com/pkg/errors"
 )
@@ -24,22 +26,142 @@ type PluginConfig struct {
 	Name    string `mapstructure:"name"`
 	Type    string `mapstructure:"type"`
 	Address string `mapstructure:"address"`
+
+	// Addresses of the replicas of a GRPC plugin
+	Addresses []string `mapstructure:"addresses"`
+
+	GRPC GRPCPluginConfig `mapstructure:"grpc"`
+	HTTP HTTPPluginConfig `mapstructure:"http"`
+	Exec ExecPluginConfig `mapstructure:"exec"`
+
+	// Registered implementation and configuration of an internal plugin
+	Implementation string                 `mapstructure:"implementation"`
+	Config         map[string]interface{} `mapstructure:"config"`
+
+	// Tenants whose event flows may call the plugin, any tenant if empty
+	Tenants []string `mapstructure:"tenants"`
 }
 
 type PluginConfigs []PluginConfig
 
+// GRPCPluginConfig describes the client side load balancing of a GRPC plugin
+type GRPCPluginConfig struct {
+	// round_robin or pick_first
+	LoadBalancing string `mapstructure:"loadBalancing"`
+	// Use the standard GRPC health checking protocol to skip unhealthy replicas
+	HealthCheck        bool   `mapstructure:"healthCheck"`
+	HealthCheckService string `mapstructure:"healthCheckService"`
+}
+
+// HTTPPluginConfig describes the configuration of an HTTP webhook plugin
+type HTTPPluginConfig struct {
+	URL    string `mapstructure:"url"`
+	Method string `mapstructure:"method"`
+	// CloudEvents HTTP content mode, structured or binary
+	Mode    string            `mapstructure:"mode"`
+	Headers map[string]string `mapstructure:"headers"`
+	Auth    HTTPAuthConfig    `mapstructure:"auth"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+	// Response status codes treated as success, any 2xx if empty
+	ExpectedStatusCodes []int `mapstructure:"expectedStatusCodes"`
+	// Maps result output keys to dot separated paths in the JSON response body,
+	// the value of the status key becomes the result status
+	Result map[string]string `mapstructure:"result"`
+}
+
+// HTTPAuthConfig describes the authentication of an HTTP webhook plugin
+type HTTPAuthConfig struct {
+	// bearer, basic or hmac
+	Type     string `mapstructure:"type"`
+	Token    string `mapstructure:"token"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// HMAC-SHA256 signing key and the header of the signature
+	Secret string `mapstructure:"secret"`
+	Header string `mapstructure:"header"`
+}
+
+// ExecPluginConfig describes the configuration of a plugin running a local command
+type ExecPluginConfig struct {
+	Command    string            `mapstructure:"command"`
+	Args       []string          `mapstructure:"args"`
+	WorkingDir string            `mapstructure:"workingDir"`
+	Env        map[string]string `mapstructure:"env"`
+	Timeout    time.Duration     `mapstructure:"timeout"`
+}
+
 // Validate validates plugin configuration
 func (c PluginConfig) Validate() error {
 	if c.Name == "" {
 		return errors.New("name must be set")
 	}
 
-	if c.Type != "grpc" {
+	switch c.Type {
+	case "grpc":
+		if len(c.GetAddresses()) == 0 {
+			return errors.New("address must not be empty for a GRPC plugin")
+		}
+		switch c.GRPC.LoadBalancing {
+		case "", GRPCRoundRobin, GRPCPickFirst:
+		default:
+			return emperror.With(errors.New("invalid GRPC load balancing policy"), "policy", c.GRPC.LoadBalancing)
+		}
+	case "http":
+		return c.HTTP.Validate()
+	case "exec":
+		if c.Exec.Command == "" {
+			return errors.New("command must not be empty for an exec plugin")
+		}
+	case "internal":
+		if c.Implementation == "" {
+			return errors.New("implementation must not be empty for an internal plugin")
+		}
+		if _, err := getFactory(c.Implementation); err != nil {
+			return err
+		}
+	default:
 		return emperror.With(errors.New("invalid plugin type"), "type", c.Type)
 	}
 
-	if c.Type == "grpc" && c.Address == "" {
-		return errors.New("address must not be empty for a GRPC plugin")
+	return nil
+}
+
+// GetAddresses returns the address and the addresses of the plugin
+func (c PluginConfig) GetAddresses() []string {
+	var addresses []string
+	if c.Address != "" {
+		addresses = append(addresses, c.Address)
+	}
+
+	return append(addresses, c.Addresses...)
+}
+
+// Validate validates HTTP plugin configuration
+func (c HTTPPluginConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("url must not be empty for an HTTP plugin")
+	}
+
+	if c.Mode != "" && c.Mode != HTTPModeStructured && c.Mode != HTTPModeBinary {
+		return emperror.With(errors.New("invalid HTTP content mode"), "mode", c.Mode)
+	}
+
+	switch c.Auth.Type {
+	case "":
+	case HTTPAuthBearer:
+		if c.Auth.Token == "" {
+			return errors.New("token must be set for bearer auth")
+		}
+	case HTTPAuthBasic:
+		if c.Auth.Username == "" {
+			return errors.New("username must be set for basic auth")
+		}
+	case HTTPAuthHMAC:
+		if c.Auth.Secret == "" {
+			return errors.New("secret must be set for hmac auth")
+		}
+	default:
+		return emperror.With(errors.New("invalid HTTP auth type"), "type", c.Auth.Type)
 	}
 
 	return nil
@@ -0,0 +1,108 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin"
+)
+
+const describeTimeout = 5 * time.Second
+
+// Describer is implemented by plugins which can tell their capabilities
+type Describer interface {
+	Describe(ctx context.Context) (*Description, error)
+}
+
+// Description describes the capabilities of a plugin
+type Description struct {
+	Name            string      `json:"name"`
+	Version         string      `json:"version"`
+	EventTypes      []string    `json:"eventTypes,omitempty"`
+	Params          []ParamSpec `json:"params,omitempty"`
+	ProtocolVersion uint32      `json:"protocolVersion"`
+}
+
+// ParamSpec describes a flow step parameter accepted by a plugin
+type ParamSpec struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SupportsEventType reports whether the event type matches any of the supported patterns,
+// a plugin without event type patterns supports every event
+func (d Description) SupportsEventType(eventType string) bool {
+	if len(d.EventTypes) == 0 {
+		return true
+	}
+
+	for _, pattern := range d.EventTypes {
+		if ok, _ := path.Match(pattern, eventType); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateParams checks that every parameter is known, the required ones are set and
+// the values have the declared type, templated values are only checked when rendered
+func (d Description) ValidateParams(params map[string]interface{}) error {
+	specs := make(map[string]ParamSpec, len(d.Params))
+	for _, s := range d.Params {
+		specs[s.Name] = s
+		if _, ok := params[s.Name]; s.Required && !ok {
+			return emperror.With(errors.New("missing required parameter"), "param", s.Name)
+		}
+	}
+
+	for name, value := range params {
+		spec, ok := specs[name]
+		if !ok {
+			return emperror.With(errors.New("unknown parameter"), "param", name)
+		}
+
+		v := fmt.Sprint(value)
+		if strings.Contains(v, "{{") {
+			continue
+		}
+
+		var err error
+		switch spec.Type {
+		case grpcplugin.ParamTypeInt:
+			_, err = strconv.Atoi(v)
+		case grpcplugin.ParamTypeBool:
+			_, err = strconv.ParseBool(v)
+		case grpcplugin.ParamTypeDuration:
+			_, err = time.ParseDuration(v)
+		}
+		if err != nil {
+			return emperror.WrapWith(err, "invalid parameter value", "param", name, "type", spec.Type)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin"
+)
+
+// describedReplica is a GRPC plugin replica describing its capabilities
+type describedReplica struct {
+	replica
+}
+
+func (r *describedReplica) Describe() *grpcplugin.Description {
+	return &grpcplugin.Description{
+		Name:       "drainer",
+		Version:    "1.2.0",
+		EventTypes: []string{"prometheus.server.alert.*"},
+		Params: []grpcplugin.ParamSpec{
+			{Name: "node", Type: grpcplugin.ParamTypeString, Required: true},
+			{Name: "grace", Type: grpcplugin.ParamTypeDuration},
+		},
+	}
+}
+
+func newDescribeTestManager() *Manager {
+	return NewManager(log.NewLogger(log.Config{Format: "logfmt", Level: "error"}), emperror.NewNopHandler())
+}
+
+func TestDescribeHandshake(t *testing.T) {
+	addr, _, stop := startReplica(t, &describedReplica{replica{name: "replica-1"}})
+	defer stop()
+
+	p, err := NewGrpcPlugin("drainer", []string{addr}, GRPCPluginConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	m := newDescribeTestManager()
+	d, err := m.describe(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil {
+		t.Fatal("expected the plugin to be described")
+	}
+	if d.Name != "drainer" || d.Version != "1.2.0" || d.ProtocolVersion != grpcplugin.ProtocolVersion {
+		t.Fatalf("unexpected description: %+v", d)
+	}
+	if len(d.Params) != 2 || d.Params[0].Name != "node" || !d.Params[0].Required || d.Params[1].Type != grpcplugin.ParamTypeDuration {
+		t.Fatalf("unexpected parameters: %+v", d.Params)
+	}
+}
+
+func TestDescribeUnimplemented(t *testing.T) {
+	addr, _, stop := startReplica(t, &replica{name: "replica-1"})
+	defer stop()
+
+	p, err := NewGrpcPlugin("drainer", []string{addr}, GRPCPluginConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	m := newDescribeTestManager()
+	d, err := m.describe(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != nil {
+		t.Fatal("expected plugins without Describe not to be described")
+	}
+}
+
+// futurePlugin describes itself with a protocol version newer than the supported one
+type futurePlugin struct {
+	failingPlugin
+}
+
+func (p *futurePlugin) Describe(ctx context.Context) (*Description, error) {
+	return &Description{Name: "future", ProtocolVersion: grpcplugin.ProtocolVersion + 1}, nil
+}
+
+func TestDescribeProtocolVersion(t *testing.T) {
+	m := newDescribeTestManager()
+	_, err := m.describe(&futurePlugin{})
+	if err == nil {
+		t.Fatal("expected plugins requiring a newer protocol version to be rejected")
+	}
+}
+
+func TestSupportsEventType(t *testing.T) {
+	d := Description{EventTypes: []string{"prometheus.server.alert.*", "hollowtrees.flow.drain.completed"}}
+
+	tests := map[string]bool{
+		"prometheus.server.alert.NodeNotReady": true,
+		"hollowtrees.flow.drain.completed":     true,
+		"hollowtrees.flow.drain.failed":        false,
+		"prometheus.server.alert":              false,
+	}
+	for eventType, expected := range tests {
+		if d.SupportsEventType(eventType) != expected {
+			t.Errorf("expected support of %s to be %t", eventType, expected)
+		}
+	}
+
+	if !(Description{}).SupportsEventType("any") {
+		t.Error("expected plugins without event types to support any event")
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	d := Description{
+		Params: []ParamSpec{
+			{Name: "node", Type: grpcplugin.ParamTypeString, Required: true},
+			{Name: "count", Type: grpcplugin.ParamTypeInt},
+			{Name: "force", Type: grpcplugin.ParamTypeBool},
+			{Name: "grace", Type: grpcplugin.ParamTypeDuration},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		valid  bool
+	}{
+		{"valid", map[string]interface{}{"node": "node-1", "count": 3, "force": "true", "grace": "30s"}, true},
+		{"templated", map[string]interface{}{"node": "{{ .instance }}", "count": "{{ .count }}"}, true},
+		{"missing required", map[string]interface{}{"count": 3}, false},
+		{"unknown", map[string]interface{}{"node": "node-1", "zone": "a"}, false},
+		{"invalid int", map[string]interface{}{"node": "node-1", "count": "three"}, false},
+		{"invalid bool", map[string]interface{}{"node": "node-1", "force": "maybe"}, false},
+		{"invalid duration", map[string]interface{}{"node": "node-1", "grace": "30"}, false},
+	}
+	for _, test := range tests {
+		err := d.ValidateParams(test.params)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
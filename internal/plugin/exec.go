@@ -0,0 +1,193 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+const (
+	defaultExecTimeout = time.Minute
+	maxExecOutputSize  = 64 * 1024
+)
+
+// inheritedExecEnv are the variables of the daemon passed to commands, the rest of its
+// environment, eg. the HT_ configuration variables holding secrets, is not
+// nolint: gochecknoglobals
+var inheritedExecEnv = []string{"PATH", "HOME", "LANG", "LC_ALL", "TZ", "TMPDIR"}
+
+type execPlugin struct {
+	BasePlugin
+	config ExecPluginConfig
+}
+
+// NewExecPlugin initializes an execPlugin which runs a local command for every event
+func NewExecPlugin(name string, config ExecPluginConfig) *execPlugin {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultExecTimeout
+	}
+
+	return &execPlugin{
+		BasePlugin: BasePlugin{
+			name: name,
+		},
+		config: config,
+	}
+}
+
+// Handle runs the command with the event as JSON on stdin and its attributes and the
+// step parameters as environment variables, a non-zero exit code is returned as an error
+func (p *execPlugin) Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error) {
+	input, err := event.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := p.environment(event, params)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not create command environment")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.config.Command, p.config.Args...)
+	cmd.Dir = p.config.WorkingDir
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &limitedBuffer{buf: &stdout, limit: maxExecOutputSize}
+	cmd.Stderr = &limitedBuffer{buf: &stderr, limit: maxExecOutputSize}
+
+	err = cmd.Run()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	result := &Result{
+		Status: strconv.Itoa(exitCode),
+		Output: map[string]string{
+			"stdout": stdout.String(),
+			"stderr": stderr.String(),
+		},
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, emperror.With(ctx.Err(), "command", p.config.Command, "timeout", p.config.Timeout.String())
+	}
+
+	if _, ok := err.(*exec.ExitError); ok {
+		return result, actionFailed(emperror.WrapWith(err, "command failed", "command", p.config.Command, "exit-code", exitCode, "stderr", strings.TrimSpace(stderr.String())))
+	}
+
+	if err != nil {
+		return result, emperror.WrapWith(err, "command failed", "command", p.config.Command, "exit-code", exitCode, "stderr", strings.TrimSpace(stderr.String()))
+	}
+
+	return result, nil
+}
+
+// environment returns the environment of the command: a few variables of the daemon like PATH,
+// the configured variables, the event attributes and the step parameters with an HT_ prefix
+func (p *execPlugin) environment(event *ce.Event, params Params) ([]string, error) {
+	var env []string
+	for _, k := range inheritedExecEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+
+	for k, v := range p.config.Env {
+		env = append(env, k+"="+v)
+	}
+
+	env = append(env,
+		"HT_EVENT_SPECVERSION="+event.SpecVersion,
+		"HT_EVENT_TYPE="+event.Type,
+		"HT_EVENT_SOURCE="+event.Source.String(),
+		"HT_EVENT_ID="+event.ID,
+	)
+	if event.Time != nil {
+		env = append(env, "HT_EVENT_TIME="+event.Time.Format(time.RFC3339))
+	}
+
+	attributes, err := event.Attributes()
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range attributes {
+		value, ok := v.(string)
+		if !ok {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			value = string(b)
+		}
+		env = append(env, "HT_ATTR_"+envName(k)+"="+value)
+	}
+
+	for k, v := range params {
+		env = append(env, "HT_PARAM_"+envName(k)+"="+v)
+	}
+
+	return env, nil
+}
+
+// envName converts an attribute name to an environment variable name
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// limitedBuffer discards writes beyond its limit
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
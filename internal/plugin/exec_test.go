@@ -0,0 +1,124 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecPluginEnvironment(t *testing.T) {
+	err := os.Setenv("HT_TEST_SECRET", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("HT_TEST_SECRET")
+
+	p := NewExecPlugin("script", ExecPluginConfig{
+		Command: "sh",
+		Args:    []string{"-c", `echo "$1|$HT_EVENT_ID|$HT_ATTR_CLUSTER_ID|$HT_PARAM_NODE|$CUSTOM|$HT_TEST_SECRET"`, "script", "first arg"},
+		Env:     map[string]string{"CUSTOM": "custom"},
+	})
+
+	result, err := p.Handle(context.Background(), testEvent(map[string]string{"cluster_id": "1"}), Params{"node": "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.TrimSpace(result.Output["stdout"]); got != "first arg|event-1|1|node-1|custom|" {
+		t.Fatalf("unexpected arguments or environment: %q", got)
+	}
+	if result.Status != "0" {
+		t.Fatalf("expected the exit code as status, got %q", result.Status)
+	}
+}
+
+func TestExecPluginStdin(t *testing.T) {
+	p := NewExecPlugin("script", ExecPluginConfig{Command: "cat"})
+
+	result, err := p.Handle(context.Background(), testEvent(map[string]string{"cluster_id": "1"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event map[string]interface{}
+	err = json.Unmarshal([]byte(result.Output["stdout"]), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event["id"] != "event-1" || event["cluster_id"] != "1" {
+		t.Fatalf("expected the event on stdin, got %s", result.Output["stdout"])
+	}
+}
+
+func TestExecPluginExitCode(t *testing.T) {
+	p := NewExecPlugin("script", ExecPluginConfig{
+		Command: "sh",
+		Args:    []string{"-c", "echo out; echo err >&2; exit 3"},
+	})
+
+	result, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err == nil {
+		t.Fatal("expected a non-zero exit code to fail")
+	}
+	if !isActionFailure(err) {
+		t.Fatal("expected a non-zero exit code not to count as a failure of the plugin")
+	}
+	if result.Status != "3" {
+		t.Fatalf("expected the exit code as status, got %q", result.Status)
+	}
+	if result.Output["stdout"] != "out\n" || result.Output["stderr"] != "err\n" {
+		t.Fatalf("expected the output to be captured, got %v", result.Output)
+	}
+}
+
+func TestExecPluginOutputLimit(t *testing.T) {
+	p := NewExecPlugin("script", ExecPluginConfig{
+		Command: "sh",
+		Args:    []string{"-c", "head -c 100000 /dev/zero"},
+	})
+
+	result, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Output["stdout"]) != maxExecOutputSize {
+		t.Fatalf("expected the output to be cut at %d bytes, got %d", maxExecOutputSize, len(result.Output["stdout"]))
+	}
+}
+
+func TestExecPluginTimeout(t *testing.T) {
+	p := NewExecPlugin("script", ExecPluginConfig{
+		Command: "sleep",
+		Args:    []string{"10"},
+		Timeout: 100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	_, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Fatalf("expected the command to time out, got %v", err)
+	}
+	if isActionFailure(err) {
+		t.Fatal("expected a timeout to count as a failure of the plugin")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the command to be killed at the timeout, it took %s", elapsed)
+	}
+}
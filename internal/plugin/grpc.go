@@ -16,57 +16,228 @@ package plugin
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
 
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/health" // registers the client side health checking
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
 
 	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin"
 	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin/proto"
 )
 
+const (
+	GRPCRoundRobin = "round_robin"
+	GRPCPickFirst  = "pick_first"
+)
+
+// resolverCount makes the schemes of the static address resolvers unique
+var resolverCount uint64 // nolint: gochecknoglobals
+
 type grpcPlugin struct {
 	BasePlugin
-	address string
+	conn      *grpc.ClientConn
+	client    proto.EventHandlerClient
+	callbacks callbackIssuer
+
+	// set if the plugin does not implement the streaming Handle call
+	unary uint32
 }
 
-// NewGrpcPlugin initializes a grpcPlugin
-func NewGrpcPlugin(name string, address string) *grpcPlugin {
+// NewGrpcPlugin initializes a grpcPlugin with a connection shared by its calls, the calls
+// are balanced between the addresses or the addresses a DNS target (dns:///host:port) resolves to,
+// the plugin reports its finished long-running operations to the callback URLs issued for its calls if set
+func NewGrpcPlugin(name string, addresses []string, config GRPCPluginConfig, callbacks callbackIssuer) (*grpcPlugin, error) {
+	if config.LoadBalancing == "" {
+		config.LoadBalancing = GRPCRoundRobin
+	}
+
+	var target string
+	switch len(addresses) {
+	case 0:
+		return nil, emperror.With(errors.New("no plugin address"), "plugin", name)
+	case 1:
+		target = addresses[0]
+	default:
+		target = staticTarget(addresses)
+	}
+
+	serviceConfig := fmt.Sprintf(`{"loadBalancingPolicy":%q}`, config.LoadBalancing)
+	if config.HealthCheck {
+		serviceConfig = fmt.Sprintf(`{"loadBalancingPolicy":%q,"healthCheckConfig":{"serviceName":%q}}`, config.LoadBalancing, config.HealthCheckService)
+	}
+
+	conn, err := grpc.Dial(target, grpc.WithInsecure(), grpc.WithDefaultServiceConfig(serviceConfig))
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not dial plugin", "plugin", name, "target", target)
+	}
+
 	return &grpcPlugin{
 		BasePlugin: BasePlugin{
 			name: name,
 		},
-		address: address,
-	}
+		conn:      conn,
+		client:    proto.NewEventHandlerClient(conn),
+		callbacks: callbacks,
+	}, nil
 }
 
-// Handle sends the CloudEvent to a GRPC plugin endpoint
-func (p *grpcPlugin) Handle(event *ce.Event) error {
-	conn, err := grpc.Dial(p.address, grpc.WithInsecure())
-	if err != nil {
-		return err
+// staticTarget registers a resolver returning the given addresses and returns its dial target
+func staticTarget(addresses []string) string {
+	r := manual.NewBuilderWithScheme("hollowtrees-static-" + strconv.FormatUint(atomic.AddUint64(&resolverCount, 1), 10))
+
+	state := resolver.State{}
+	for _, a := range addresses {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: a})
 	}
-	defer conn.Close()
+	r.InitialState(state)
+	resolver.Register(r)
+
+	return r.Scheme() + ":///plugin"
+}
 
+// Handle sends the CloudEvent to a GRPC plugin endpoint, the progress updates of plugins
+// implementing the streaming call are reported to the listener of the context
+func (p *grpcPlugin) Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error) {
 	j, err := event.MarshalJSON()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var callbackURL, token string
+	if p.callbacks != nil {
+		callbackURL, token = p.callbacks()
+	}
+
+	// the time of the event is optional
+	var eventTime string
+	if event.Time != nil {
+		eventTime = event.Time.String()
 	}
 
-	client := proto.NewEventHandlerClient(conn)
 	ez := &proto.CloudEvent{
 		Specversion: event.SpecVersion,
 		Type:        event.Type,
 		Source:      event.Source.String(),
 		Id:          event.ID,
-		Time:        event.Time.String(),
+		Time:        eventTime,
 		Schemaurl:   event.SchemaURL.String(),
 		Contenttype: "application/cloudevents+json",
 		Extensions:  event.GetExtensions(),
 		Data:        j,
+		Params:      params,
+		CallbackUrl: callbackURL,
+	}
+
+	if atomic.LoadUint32(&p.unary) == 0 {
+		result, err := p.handleStream(ctx, ez)
+		if status.Code(err) != codes.Unimplemented {
+			if result != nil {
+				result.callbackToken = token
+			}
+			return result, err
+		}
+		atomic.StoreUint32(&p.unary, 1)
 	}
-	_, err = client.Handle(context.Background(), ez)
+
+	result, err := p.client.Handle(ctx, ez)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{Status: result.Status, OperationID: result.OperationId, callbackToken: token}, nil
+}
+
+// handleStream sends the CloudEvent to the streaming Handle call and reports the progress
+// updates until the result arrives, the outputs of the updates are merged into the result
+func (p *grpcPlugin) handleStream(ctx context.Context, event *proto.CloudEvent) (*Result, error) {
+	stream, err := p.client.HandleStream(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(map[string]string)
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			return nil, errors.New("plugin closed the stream without result")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if update.Progress != nil {
+			for k, v := range update.Progress.Output {
+				output[k] = v
+			}
+			ReportProgress(ctx, Progress{
+				Percentage: int(update.Progress.Percentage),
+				Message:    update.Progress.Message,
+				Output:     update.Progress.Output,
+			})
+		}
+
+		if update.Result != nil {
+			result := &Result{Status: update.Result.Status, OperationID: update.Result.OperationId}
+			if len(output) > 0 {
+				result.Output = output
+			}
+			return result, nil
+		}
+	}
+}
+
+// GetStatus asks the plugin for the state of a long-running operation, plugins not
+// implementing the GetStatus call return an Unimplemented status
+func (p *grpcPlugin) GetStatus(ctx context.Context, operationID string) (*OperationStatus, error) {
+	resp, err := p.client.GetStatus(ctx, &proto.OperationRequest{OperationId: operationID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OperationStatus{
+		State:  resp.State,
+		Status: resp.Status,
+		Error:  resp.Error,
+	}, nil
+}
+
+// Describe asks the plugin for its capabilities, plugins not implementing
+// the Describe call return an Unimplemented status
+func (p *grpcPlugin) Describe(ctx context.Context) (*Description, error) {
+	resp, err := p.client.Describe(ctx, &proto.DescribeRequest{ProtocolVersion: grpcplugin.ProtocolVersion})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	d := &Description{
+		Name:            resp.Name,
+		Version:         resp.Version,
+		EventTypes:      resp.EventTypes,
+		ProtocolVersion: resp.ProtocolVersion,
 	}
+	for _, s := range resp.Params {
+		d.Params = append(d.Params, ParamSpec{
+			Name:        s.Name,
+			Type:        s.Type,
+			Required:    s.Required,
+			Description: s.Description,
+		})
+	}
+
+	return d, nil
+}
 
-	return nil
+// Close closes the connection of the plugin
+func (p *grpcPlugin) Close() error {
+	return p.conn.Close()
 }
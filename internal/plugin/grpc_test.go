@@ -0,0 +1,269 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin"
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin/proto"
+)
+
+// replica is a GRPC plugin replica answering with its name as result status
+type replica struct {
+	name string
+}
+
+func (r *replica) Handle(event *grpcplugin.CloudEvent) (*grpcplugin.Result, error) {
+	return &grpcplugin.Result{Status: r.name}, nil
+}
+
+// startReplica serves the event handler like grpcplugin.Serve on a random local port,
+// it returns the address and the health service of the server
+func startReplica(t *testing.T, handler grpcplugin.EventHandler) (string, *health.Server, func()) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	proto.RegisterEventHandlerServer(server, grpcplugin.NewHandler(handler))
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return listener.Addr().String(), healthServer, server.Stop
+}
+
+// handledBy calls the plugin n times and counts the calls handled by each replica
+func handledBy(t *testing.T, p *grpcPlugin, n int) map[string]int {
+	t.Helper()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		result, err := p.Handle(ctx, testEvent(nil), nil)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[result.Status]++
+	}
+
+	return counts
+}
+
+func TestGRPCPluginRoundRobin(t *testing.T) {
+	addr1, _, stop1 := startReplica(t, &replica{name: "replica-1"})
+	defer stop1()
+	addr2, _, stop2 := startReplica(t, &replica{name: "replica-2"})
+	defer stop2()
+
+	p, err := NewGrpcPlugin("plugin", []string{addr1, addr2}, GRPCPluginConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// the connections of the replicas are established in the background
+	var counts map[string]int
+	for i := 0; i < 50; i++ {
+		counts = handledBy(t, p, 4)
+		if counts["replica-1"] == 2 && counts["replica-2"] == 2 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected the calls to be balanced between the replicas, got %v", counts)
+}
+
+func TestGRPCPluginPickFirst(t *testing.T) {
+	addr1, _, stop1 := startReplica(t, &replica{name: "replica-1"})
+	defer stop1()
+	addr2, _, stop2 := startReplica(t, &replica{name: "replica-2"})
+	defer stop2()
+
+	p, err := NewGrpcPlugin("plugin", []string{addr1, addr2}, GRPCPluginConfig{LoadBalancing: GRPCPickFirst}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	counts := handledBy(t, p, 10)
+	if counts["replica-1"] != 10 {
+		t.Fatalf("expected every call to be sent to the first replica, got %v", counts)
+	}
+
+	// the next replica takes over if the first one goes away
+	stop1()
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		result, err := p.Handle(ctx, testEvent(nil), nil)
+		cancel()
+		if err == nil && result.Status == "replica-2" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("expected the calls to be sent to the second replica")
+}
+
+func TestGRPCPluginHealthCheck(t *testing.T) {
+	addr1, health1, stop1 := startReplica(t, &replica{name: "replica-1"})
+	defer stop1()
+	addr2, _, stop2 := startReplica(t, &replica{name: "replica-2"})
+	defer stop2()
+
+	health1.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	p, err := NewGrpcPlugin("plugin", []string{addr1, addr2}, GRPCPluginConfig{HealthCheck: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	counts := handledBy(t, p, 10)
+	if counts["replica-2"] != 10 {
+		t.Fatalf("expected the unhealthy replica to be skipped, got %v", counts)
+	}
+
+	// the replica gets calls again once it reports serving
+	health1.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	for i := 0; i < 50; i++ {
+		if handledBy(t, p, 4)["replica-1"] > 0 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("expected the recovered replica to get calls")
+}
+
+func TestGRPCPluginNoAddress(t *testing.T) {
+	_, err := NewGrpcPlugin("plugin", nil, GRPCPluginConfig{}, nil)
+	if err == nil {
+		t.Fatal("expected an error without addresses")
+	}
+}
+
+// recordingReplica records the time of the events it handles
+type recordingReplica struct {
+	times chan string
+}
+
+func (r *recordingReplica) Handle(event *grpcplugin.CloudEvent) (*grpcplugin.Result, error) {
+	r.times <- event.Time
+	return &grpcplugin.Result{Status: "ok"}, nil
+}
+
+func TestGRPCPluginEventWithoutTime(t *testing.T) {
+	r := &recordingReplica{times: make(chan string, 2)}
+	addr, _, stop := startReplica(t, r)
+	defer stop()
+
+	p, err := NewGrpcPlugin("plugin", []string{addr}, GRPCPluginConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	event := testEvent(nil)
+	_, err = p.Handle(context.Background(), event, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-r.times; got != event.Time.String() {
+		t.Fatalf("expected the time of the event, got %q", got)
+	}
+
+	event.Time = nil
+	_, err = p.Handle(context.Background(), event, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-r.times; got != "" {
+		t.Fatalf("expected no time, got %q", got)
+	}
+}
+
+// streamingReplica reports progress while handling events
+type streamingReplica struct {
+	replica
+}
+
+func (r *streamingReplica) HandleWithProgress(event *grpcplugin.CloudEvent, progress grpcplugin.ProgressReporter) (*grpcplugin.Result, error) {
+	_ = progress.Report(20, "cordoned", map[string]string{"node": "node-1"})
+	_ = progress.Report(150, "evicted", map[string]string{"pods": "3"})
+
+	return &grpcplugin.Result{Status: r.name}, nil
+}
+
+func TestGRPCPluginProgress(t *testing.T) {
+	addr, _, stop := startReplica(t, &streamingReplica{replica{name: "replica-1"}})
+	defer stop()
+
+	p, err := NewGrpcPlugin("plugin", []string{addr}, GRPCPluginConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var updates []Progress
+	ctx := WithProgressFunc(context.Background(), func(p Progress) {
+		updates = append(updates, p)
+	})
+	result, err := p.Handle(ctx, testEvent(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 2 || updates[0].Message != "cordoned" || updates[0].Percentage != 20 {
+		t.Fatalf("expected the progress updates to be reported, got %+v", updates)
+	}
+	if updates[1].Percentage != 100 {
+		t.Fatalf("expected the percentage to be capped at 100, got %d", updates[1].Percentage)
+	}
+	if result.Status != "replica-1" || result.Output["node"] != "node-1" || result.Output["pods"] != "3" {
+		t.Fatalf("expected the outputs of the updates in the result, got %+v", result)
+	}
+
+	// handlers without progress reporting are called through the stream as well
+	addr, _, stop = startReplica(t, &replica{name: "replica-2"})
+	defer stop()
+	p, err = NewGrpcPlugin("plugin", []string{addr}, GRPCPluginConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	updates = nil
+	result, err = p.Handle(ctx, testEvent(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "replica-2" || len(updates) != 0 || result.Output != nil {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
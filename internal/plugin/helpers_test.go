@@ -1,4 +1,4 @@
-// Copyright © 2018 Banzai Cloud
+// Copyright © 2019 Banzai Cloud
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,28 +15,26 @@
 package plugin
 
 import (
+	"net/url"
+	"time"
+
 	"github.com/banzaicloud/hollowtrees/internal/ce"
-	"github.com/banzaicloud/hollowtrees/internal/platform/log"
 )
 
-type internalPlugin struct {
-	BasePlugin
-	logger log.Logger
-}
+// testEvent returns an alert event of a node with the attributes
+func testEvent(attributes map[string]string) *ce.Event {
+	now := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	source, _ := url.Parse("http://prometheus")
 
-// NewInternalPlugin returns an initialized internalPlugin
-func NewInternalPlugin(name string, logger log.Logger) *internalPlugin {
-	return &internalPlugin{
-		BasePlugin: BasePlugin{
-			name: name,
-		},
-		logger: logger,
+	event := &ce.Event{}
+	event.Set("id", "event-1")
+	event.Set("type", "prometheus.server.alert.NodeNotReady")
+	event.Set("specversion", "0.2")
+	event.Set("source", *source)
+	event.Set("time", &now)
+	for k, v := range attributes {
+		event.Set(k, v)
 	}
-}
-
-// Handle handles
-func (p *internalPlugin) Handle(event *ce.Event) error {
-	p.logger.Infof("internal-demo-plugin: %s", event.Type)
 
-	return nil
+	return event
 }
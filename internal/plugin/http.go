@@ -0,0 +1,279 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+const (
+	HTTPModeStructured = "structured"
+	HTTPModeBinary     = "binary"
+
+	HTTPAuthBearer = "bearer"
+	HTTPAuthBasic  = "basic"
+	HTTPAuthHMAC   = "hmac"
+
+	defaultHTTPTimeout         = 30 * time.Second
+	defaultHTTPSignatureHeader = "X-Hollowtrees-Signature"
+	httpParamHeaderPrefix      = "X-Hollowtrees-Param-"
+	maxHTTPResponseSize        = 1 << 20
+)
+
+type httpPlugin struct {
+	BasePlugin
+	config HTTPPluginConfig
+	client *http.Client
+}
+
+// NewHTTPPlugin initializes an httpPlugin which sends events to a webhook
+func NewHTTPPlugin(name string, config HTTPPluginConfig) *httpPlugin {
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.Mode == "" {
+		config.Mode = HTTPModeStructured
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHTTPTimeout
+	}
+	if config.Auth.Header == "" {
+		config.Auth.Header = defaultHTTPSignatureHeader
+	}
+
+	return &httpPlugin{
+		BasePlugin: BasePlugin{
+			name: name,
+		},
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Handle sends the CloudEvent to the webhook and maps the response to a result
+func (p *httpPlugin) Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error) {
+	req, err := p.newRequest(ctx, event, params)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not create request")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not call webhook", "url", p.config.URL)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxHTTPResponseSize))
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not read response", "url", p.config.URL)
+	}
+
+	if !p.isExpectedStatus(resp.StatusCode) {
+		err := emperror.With(errors.New("unexpected response status"), "url", p.config.URL, "status", resp.StatusCode)
+		if resp.StatusCode < http.StatusInternalServerError {
+			return nil, actionFailed(err)
+		}
+
+		return nil, err
+	}
+
+	return p.result(resp.StatusCode, body)
+}
+
+func (p *httpPlugin) newRequest(ctx context.Context, event *ce.Event, params Params) (*http.Request, error) {
+	var body []byte
+	header := http.Header{}
+
+	switch p.config.Mode {
+	case HTTPModeBinary:
+		var err error
+		body, err = binaryBody(event, header)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		body, err = event.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Content-Type", "application/cloudevents+json")
+	}
+
+	req, err := http.NewRequest(p.config.Method, p.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range params {
+		req.Header.Set(httpParamHeaderPrefix+k, v)
+	}
+
+	switch p.config.Auth.Type {
+	case HTTPAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+p.config.Auth.Token)
+	case HTTPAuthBasic:
+		req.SetBasicAuth(p.config.Auth.Username, p.config.Auth.Password)
+	case HTTPAuthHMAC:
+		mac := hmac.New(sha256.New, []byte(p.config.Auth.Secret))
+		mac.Write(body)
+		req.Header.Set(p.config.Auth.Header, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return req, nil
+}
+
+// binaryBody sets the event attributes as ce- headers and returns the event data as the body
+func binaryBody(event *ce.Event, header http.Header) ([]byte, error) {
+	header.Set("ce-specversion", event.SpecVersion)
+	header.Set("ce-type", event.Type)
+	header.Set("ce-source", event.Source.String())
+	header.Set("ce-id", event.ID)
+	if event.Time != nil {
+		header.Set("ce-time", event.Time.Format(time.RFC3339))
+	}
+
+	attributes, err := event.Attributes()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range attributes {
+		if s, ok := v.(string); ok {
+			header.Set("ce-"+k, s)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("ce-"+k, string(b))
+	}
+
+	contentType := event.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	header.Set("Content-Type", contentType)
+
+	switch data := event.Data.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return data, nil
+	case string:
+		return []byte(data), nil
+	default:
+		return json.Marshal(data)
+	}
+}
+
+func (p *httpPlugin) isExpectedStatus(status int) bool {
+	if len(p.config.ExpectedStatusCodes) == 0 {
+		return status >= 200 && status < 300
+	}
+
+	for _, s := range p.config.ExpectedStatusCodes {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// result maps the response body to a result, the status defaults to the HTTP status code
+func (p *httpPlugin) result(status int, body []byte) (*Result, error) {
+	result := &Result{
+		Status: fmt.Sprintf("%d", status),
+	}
+
+	if len(p.config.Result) == 0 {
+		return result, nil
+	}
+
+	var doc interface{}
+	err := json.Unmarshal(body, &doc)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not parse response body")
+	}
+
+	result.Output = make(map[string]string, len(p.config.Result))
+	for key, path := range p.config.Result {
+		value, ok := lookupJSONPath(doc, path)
+		if !ok {
+			continue
+		}
+
+		if key == "status" {
+			result.Status = value
+			continue
+		}
+		result.Output[key] = value
+	}
+
+	return result, nil
+}
+
+// lookupJSONPath returns the value at a dot separated path of a decoded JSON document
+func lookupJSONPath(doc interface{}, path string) (string, bool) {
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		current, ok = m[segment]
+		if !ok {
+			return "", false
+		}
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", true
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	default:
+		return fmt.Sprintf("%v", v), true
+	}
+}
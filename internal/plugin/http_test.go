@@ -0,0 +1,213 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhook records the last request it received and responds with a fixed status and body
+type webhook struct {
+	status   int
+	response string
+	delay    time.Duration
+
+	method string
+	header http.Header
+	body   []byte
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.method = r.Method
+	h.header = r.Header
+	h.body, _ = ioutil.ReadAll(r.Body)
+
+	if h.delay > 0 {
+		time.Sleep(h.delay)
+	}
+
+	w.WriteHeader(h.status)
+	_, _ = w.Write([]byte(h.response))
+}
+
+func TestHTTPPluginStructured(t *testing.T) {
+	hook := &webhook{status: http.StatusOK}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	p := NewHTTPPlugin("webhook", HTTPPluginConfig{
+		URL:     server.URL,
+		Method:  http.MethodPut,
+		Headers: map[string]string{"X-Team": "ops"},
+		Auth:    HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "secret"},
+	})
+
+	// the step parameters are rendered by the flow before the call
+	result, err := p.Handle(context.Background(), testEvent(map[string]string{"cluster_id": "1"}), Params{"node": "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "200" {
+		t.Fatalf("expected the HTTP status as result status, got %q", result.Status)
+	}
+
+	if hook.method != http.MethodPut {
+		t.Fatalf("expected a PUT request, got %s", hook.method)
+	}
+	if got := hook.header.Get("X-Team"); got != "ops" {
+		t.Fatalf("expected the configured header, got %q", got)
+	}
+	if got := hook.header.Get("X-Hollowtrees-Param-Node"); got != "node-1" {
+		t.Fatalf("expected the step parameter as header, got %q", got)
+	}
+	if got := hook.header.Get("Content-Type"); got != "application/cloudevents+json" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(hook.body)
+	if got := hook.header.Get(defaultHTTPSignatureHeader); got != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("expected the body to be signed, got %q", got)
+	}
+
+	var body map[string]interface{}
+	err = json.Unmarshal(hook.body, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["id"] != "event-1" || body["type"] != "prometheus.server.alert.NodeNotReady" {
+		t.Fatalf("expected the event as body, got %s", hook.body)
+	}
+}
+
+func TestHTTPPluginBinary(t *testing.T) {
+	hook := &webhook{status: http.StatusNoContent}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	p := NewHTTPPlugin("webhook", HTTPPluginConfig{
+		URL:  server.URL,
+		Mode: HTTPModeBinary,
+		Auth: HTTPAuthConfig{Type: HTTPAuthBearer, Token: "token"},
+	})
+
+	event := testEvent(map[string]string{"cluster_id": "1"})
+	event.Data = map[string]string{"node": "node-1"}
+	_, err := p.Handle(context.Background(), event, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hook.method != http.MethodPost {
+		t.Fatalf("expected a POST request by default, got %s", hook.method)
+	}
+	for header, expected := range map[string]string{
+		"ce-id":         "event-1",
+		"ce-type":       "prometheus.server.alert.NodeNotReady",
+		"ce-cluster_id": "1",
+		"Authorization": "Bearer token",
+	} {
+		if got := hook.header.Get(header); got != expected {
+			t.Errorf("expected header %s to be %q, got %q", header, expected, got)
+		}
+	}
+	if strings.TrimSpace(string(hook.body)) != `{"node":"node-1"}` {
+		t.Fatalf("expected the event data as body, got %s", hook.body)
+	}
+}
+
+func TestHTTPPluginResult(t *testing.T) {
+	hook := &webhook{status: http.StatusAccepted, response: `{"state": {"phase": "drained"}, "nodes": 3}`}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	p := NewHTTPPlugin("webhook", HTTPPluginConfig{
+		URL:                 server.URL,
+		ExpectedStatusCodes: []int{http.StatusAccepted},
+		Result:              map[string]string{"status": "state.phase", "nodes": "nodes", "missing": "foo.bar"},
+	})
+
+	result, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "drained" {
+		t.Fatalf("expected the mapped status, got %q", result.Status)
+	}
+	if result.Output["nodes"] != "3" {
+		t.Fatalf("expected the mapped output, got %v", result.Output)
+	}
+	if _, ok := result.Output["missing"]; ok {
+		t.Fatal("expected missing paths to be left out of the output")
+	}
+}
+
+func TestHTTPPluginUnexpectedStatus(t *testing.T) {
+	hook := &webhook{status: http.StatusOK}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	p := NewHTTPPlugin("webhook", HTTPPluginConfig{
+		URL:                 server.URL,
+		ExpectedStatusCodes: []int{http.StatusAccepted},
+	})
+	_, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err == nil || !strings.Contains(err.Error(), "unexpected response status") {
+		t.Fatalf("expected an unexpected status error, got %v", err)
+	}
+	if !isActionFailure(err) {
+		t.Fatal("expected a rejected event not to count as a failure of the plugin")
+	}
+
+	hook.status = http.StatusInternalServerError
+	p = NewHTTPPlugin("webhook", HTTPPluginConfig{URL: server.URL})
+	_, err = p.Handle(context.Background(), testEvent(nil), nil)
+	if err == nil {
+		t.Fatal("expected a non-2xx status to fail by default")
+	}
+	if isActionFailure(err) {
+		t.Fatal("expected a server error to count as a failure of the plugin")
+	}
+}
+
+func TestHTTPPluginTimeout(t *testing.T) {
+	hook := &webhook{status: http.StatusOK, delay: 200 * time.Millisecond}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	p := NewHTTPPlugin("webhook", HTTPPluginConfig{
+		URL:     server.URL,
+		Timeout: 50 * time.Millisecond,
+	})
+
+	start := time.Now()
+	_, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err == nil {
+		t.Fatal("expected the call to time out")
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Fatalf("expected the call to be aborted at the timeout, it took %s", elapsed)
+	}
+}
@@ -15,12 +15,24 @@
 package plugin
 
 import (
+	"context"
 	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/goph/emperror"
+	uuid "github.com/satori/go.uuid"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
 	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin"
 )
 
 // PluginManager describes what a plugin manager implementation must provide
@@ -28,34 +40,222 @@ type PluginManager interface {
 	Add(plugin ...EventHandlerPlugin)
 	GetByNames(names ...string) (map[string]EventHandlerPlugin, error)
 	GetByName(name string) (EventHandlerPlugin, error)
+	Available(name string) bool
+	Description(name string) (*Description, bool)
+	Tenants(name string) []string
+	WatchOperation(name string, result *Result, timeout time.Duration, done func(*OperationStatus, error))
 }
 
 // Manager is a PluginManager implementation
 type Manager struct {
 	logger       log.Logger
 	errorHandler emperror.Handler
+	publisher    EventPublisher
+	clock        clock.Clock
+	breaker      BreakerConfig
+	tenantKeys   []string
 
-	plugins map[string]EventHandlerPlugin
+	operationConfig OperationConfig
+	operations      *operations
+	callbackSecret  []byte
+
+	// the plugin set is never changed once set, it is replaced as a whole when plugins are added or reloaded
+	mu     sync.RWMutex
+	reload sync.Mutex
+	pluginSet
+}
+
+// pluginSet holds the plugins of the manager and their state
+type pluginSet struct {
+	plugins      map[string]EventHandlerPlugin
+	breakers     map[string]*breaker
+	descriptions map[string]*Description
+	pollers      map[string]StatusPoller
+	tenants      map[string][]string
+
+	// configuration of the plugins loaded from configuration
+	configs map[string]PluginConfig
+}
+
+func newPluginSet() pluginSet {
+	return pluginSet{
+		plugins:      make(map[string]EventHandlerPlugin),
+		breakers:     make(map[string]*breaker),
+		descriptions: make(map[string]*Description),
+		pollers:      make(map[string]StatusPoller),
+		tenants:      make(map[string][]string),
+		configs:      make(map[string]PluginConfig),
+	}
+}
+
+func (r pluginSet) clone() pluginSet {
+	c := newPluginSet()
+	for name, p := range r.plugins {
+		c.plugins[name] = p
+	}
+	for name, b := range r.breakers {
+		c.breakers[name] = b
+	}
+	for name, d := range r.descriptions {
+		c.descriptions[name] = d
+	}
+	for name, p := range r.pollers {
+		c.pollers[name] = p
+	}
+	for name, t := range r.tenants {
+		c.tenants[name] = t
+	}
+	for name, config := range r.configs {
+		c.configs[name] = config
+	}
+
+	return c
+}
+
+func (r pluginSet) remove(name string) {
+	delete(r.plugins, name)
+	delete(r.breakers, name)
+	delete(r.descriptions, name)
+	delete(r.pollers, name)
+	delete(r.tenants, name)
+	delete(r.configs, name)
+}
+
+// ManagerOption sets configuration on the Manager
+type ManagerOption func(*Manager)
+
+// WithEventPublisher sets the publisher internal plugins use to emit new events
+func WithEventPublisher(publisher EventPublisher) ManagerOption {
+	return func(m *Manager) {
+		m.publisher = publisher
+	}
+}
+
+// WithClock sets the clock timing the circuit breakers and the waits for long-running operations
+func WithClock(clock clock.Clock) ManagerOption {
+	return func(m *Manager) {
+		m.clock = clock
+	}
+}
+
+// WithTenantKeys sets the event attributes identifying the tenant of an event, internal plugins
+// publishing events keep them, they are the org_id and cluster_id attributes by default
+func WithTenantKeys(keys ...string) ManagerOption {
+	return func(m *Manager) {
+		m.tenantKeys = keys
+	}
+}
+
+// WithCircuitBreaker guards every plugin added to the manager with a circuit breaker
+func WithCircuitBreaker(config BreakerConfig) ManagerOption {
+	return func(m *Manager) {
+		m.breaker = config
+	}
+}
+
+// WithOperations sets how the long-running operations of plugins are waited for
+func WithOperations(config OperationConfig) ManagerOption {
+	return func(m *Manager) {
+		m.operationConfig = config
+	}
 }
 
 // NewManager returns an initialized Manager
-func NewManager(logger log.Logger, errorHandler emperror.Handler) *Manager {
+func NewManager(logger log.Logger, errorHandler emperror.Handler, opts ...ManagerOption) *Manager {
 	m := &Manager{
 		logger:       logger,
 		errorHandler: errorHandler,
+		publisher:    nopPublisher{},
+		clock:        clock.New(),
+		tenantKeys:   []string{"org_id", "cluster_id"},
+
+		operationConfig: OperationConfig{
+			PollInterval: DefaultOperationPollInterval,
+			Timeout:      DefaultOperationTimeout,
+		},
+		operations:     newOperations(),
+		callbackSecret: uuid.NewV4().Bytes(),
+
+		pluginSet: newPluginSet(),
 	}
 
-	plugins := make(map[string]EventHandlerPlugin)
-	m.plugins = plugins
+	for _, o := range opts {
+		o(m)
+	}
 
 	return m
 }
 
-// Add add an initialized plugin
+// Add add an initialized plugin, it is wrapped in a circuit breaker if enabled
 func (m *Manager) Add(plugins ...EventHandlerPlugin) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := m.pluginSet.clone()
 	for _, plugin := range plugins {
-		m.plugins[plugin.GetName()] = plugin
+		m.add(r, plugin)
 	}
+	m.pluginSet = r
+}
+
+func (m *Manager) add(r pluginSet, plugin EventHandlerPlugin) {
+	if p, ok := plugin.(StatusPoller); ok {
+		r.pollers[plugin.GetName()] = p
+	}
+
+	if !m.breaker.Enabled {
+		r.plugins[plugin.GetName()] = plugin
+		return
+	}
+
+	b := newBreaker(m.breaker, m.clock.Now)
+	r.breakers[plugin.GetName()] = b
+	r.plugins[plugin.GetName()] = &breakerPlugin{
+		EventHandlerPlugin: plugin,
+		breaker:            b,
+	}
+}
+
+// Available reports whether the plugin can be called, ie. its circuit breaker is not open
+func (m *Manager) Available(name string) bool {
+	m.mu.RLock()
+	b, ok := m.breakers[name]
+	m.mu.RUnlock()
+
+	if ok {
+		return b.available()
+	}
+
+	return true
+}
+
+// PluginStatus describes the state of a plugin
+type PluginStatus struct {
+	Name        string         `json:"name"`
+	Breaker     *BreakerStatus `json:"breaker,omitempty"`
+	Description *Description   `json:"description,omitempty"`
+}
+
+// Status returns the state of every plugin sorted by name
+func (m *Manager) Status() []PluginStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]PluginStatus, 0, len(m.plugins))
+	for name := range m.plugins {
+		s := PluginStatus{Name: name, Description: m.descriptions[name]}
+		if b, ok := m.breakers[name]; ok {
+			bs := b.status()
+			s.Breaker = &bs
+		}
+		statuses = append(statuses, s)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
 }
 
 // GetByNames returns a map of plugins by their names
@@ -75,7 +275,10 @@ func (m *Manager) GetByNames(names ...string) (map[string]EventHandlerPlugin, er
 
 // GetByName returns a plugin by it's name
 func (m *Manager) GetByName(name string) (EventHandlerPlugin, error) {
+	m.mu.RLock()
 	p := m.plugins[name]
+	m.mu.RUnlock()
+
 	if p == nil {
 		return nil, emperror.With(errors.New("plugin not found"), "name", name)
 	}
@@ -83,29 +286,212 @@ func (m *Manager) GetByName(name string) (EventHandlerPlugin, error) {
 	return p, nil
 }
 
+// NewInternalPlugin creates a plugin with the given name using a registered internal implementation
+func (m *Manager) NewInternalPlugin(name string, implementation string, config map[string]interface{}) (EventHandlerPlugin, error) {
+	factory, err := getFactory(implementation)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := factory(name, config, Dependencies{
+		Logger:     m.logger.WithField("plugin", name),
+		Publisher:  m.publisher,
+		Clock:      m.clock,
+		TenantKeys: m.tenantKeys,
+	})
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not create internal plugin", "implementation", implementation)
+	}
+
+	return p, nil
+}
+
 // LoadFromConfig loads plugins from configuration
 func (m *Manager) LoadFromConfig(v *viper.Viper) error {
-	var plugins PluginConfigs
+	return m.ReloadFromConfig(v, nil)
+}
+
+// ReloadFromConfig replaces the plugins loaded from configuration with the plugins of v, plugins added
+// otherwise are kept. Unchanged plugins keep their connections and circuit breakers, changed and removed
+// plugins are closed, so event flows calling them at that moment may fail. If apply is set it is called
+// with the new plugins in place, eg. to load the flows using them, and the previous plugins are restored
+// if it fails.
+func (m *Manager) ReloadFromConfig(v *viper.Viper, apply func() error) error {
+	var configs PluginConfigs
 
-	err := viper.UnmarshalKey("plugins", &plugins)
+	err := v.UnmarshalKey("plugins", &configs)
 	if err != nil {
 		return emperror.Wrap(err, "could not unmarshal plugin configs")
 	}
 
-	if len(plugins) == 0 {
-		return emperror.Wrap(err, "no plugins were defined")
+	m.reload.Lock()
+	defer m.reload.Unlock()
+
+	m.mu.RLock()
+	previous := m.pluginSet
+	m.mu.RUnlock()
+
+	next := previous.clone()
+	for name := range previous.configs {
+		next.remove(name)
 	}
 
-	for _, plugin := range plugins {
-		err := plugin.Validate()
+	created := make([]EventHandlerPlugin, 0, len(configs))
+	for _, config := range configs {
+		if c, ok := previous.configs[config.Name]; ok && reflect.DeepEqual(c, config) {
+			next.plugins[config.Name] = previous.plugins[config.Name]
+			if b, ok := previous.breakers[config.Name]; ok {
+				next.breakers[config.Name] = b
+			}
+			if d, ok := previous.descriptions[config.Name]; ok {
+				next.descriptions[config.Name] = d
+			}
+			if p, ok := previous.pollers[config.Name]; ok {
+				next.pollers[config.Name] = p
+			}
+		} else {
+			p, d, err := m.newPlugin(config)
+			if err != nil {
+				m.close(created...)
+				return err
+			}
+			created = append(created, p)
+
+			m.add(next, p)
+			if d != nil {
+				next.descriptions[config.Name] = d
+			}
+		}
+
+		if len(config.Tenants) > 0 {
+			next.tenants[config.Name] = config.Tenants
+		}
+		next.configs[config.Name] = config
+	}
+
+	m.mu.Lock()
+	m.pluginSet = next
+	m.mu.Unlock()
+
+	if apply != nil {
+		err := apply()
 		if err != nil {
-			return emperror.WrapWith(err, "invalid plugin configuration", "plugin", plugin.Name)
+			m.mu.Lock()
+			m.pluginSet = previous
+			m.mu.Unlock()
+
+			m.close(created...)
+
+			return err
 		}
-		switch plugin.Type {
-		case "grpc":
-			m.Add(NewGrpcPlugin(plugin.Name, plugin.Address))
+	}
+
+	// the previous plugins which were not kept are closed
+	var replaced []EventHandlerPlugin
+	for name := range previous.configs {
+		if p, ok := previous.plugins[name]; ok && next.plugins[name] != p {
+			replaced = append(replaced, p)
 		}
 	}
+	m.close(replaced...)
 
 	return nil
 }
+
+// newPlugin creates a plugin from its configuration and describes it
+func (m *Manager) newPlugin(config PluginConfig) (EventHandlerPlugin, *Description, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, nil, emperror.WrapWith(err, "invalid plugin configuration", "plugin", config.Name)
+	}
+
+	var p EventHandlerPlugin
+	switch config.Type {
+	case "grpc":
+		p, err = NewGrpcPlugin(config.Name, config.GetAddresses(), config.GRPC, m.callbackIssuer(config.Name))
+	case "http":
+		p = NewHTTPPlugin(config.Name, config.HTTP)
+	case "exec":
+		p = NewExecPlugin(config.Name, config.Exec)
+	case "internal":
+		p, err = m.NewInternalPlugin(config.Name, config.Implementation, config.Config)
+	}
+	if err != nil {
+		return nil, nil, emperror.WrapWith(err, "invalid plugin configuration", "plugin", config.Name)
+	}
+
+	d, err := m.describe(p)
+	if err != nil {
+		m.close(p)
+		return nil, nil, emperror.WrapWith(err, "incompatible plugin", "plugin", config.Name)
+	}
+
+	return p, d, nil
+}
+
+// close releases the connections of the plugins
+func (m *Manager) close(plugins ...EventHandlerPlugin) {
+	for _, p := range plugins {
+		if b, ok := p.(*breakerPlugin); ok {
+			p = b.EventHandlerPlugin
+		}
+
+		if c, ok := p.(io.Closer); ok {
+			err := c.Close()
+			if err != nil {
+				m.errorHandler.Handle(emperror.WrapWith(err, "could not close plugin", "plugin", p.GetName()))
+			}
+		}
+	}
+}
+
+// describe asks the plugin for its capabilities if it is a Describer, plugins which
+// can not be reached or do not describe themselves are used without validation
+func (m *Manager) describe(p EventHandlerPlugin) (*Description, error) {
+	describer, ok := p.(Describer)
+	if !ok {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+
+	d, err := describer.Describe(ctx)
+	if status.Code(err) == codes.Unimplemented {
+		m.logger.WithField("plugin", p.GetName()).Debug("plugin does not describe its capabilities")
+		return nil, nil
+	}
+	if err != nil {
+		m.logger.WithFields(log.Fields{"plugin": p.GetName(), "error": err.Error()}).Warn("could not describe plugin, its flows are not validated")
+		return nil, nil
+	}
+
+	if d.ProtocolVersion > grpcplugin.ProtocolVersion {
+		return nil, emperror.With(errors.New("plugin requires a newer protocol version"), "plugin-protocol", d.ProtocolVersion, "protocol", grpcplugin.ProtocolVersion)
+	}
+
+	return d, nil
+}
+
+// Description returns the capabilities of a plugin, if it described them
+func (m *Manager) Description(name string) (*Description, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, ok := m.descriptions[name]
+	return d, ok
+}
+
+// Tenants returns the tenants whose event flows may call the plugin, nil if any tenant may
+func (m *Manager) Tenants(name string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.tenants[name]
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(*ce.Event) error {
+	return errors.New("event publishing is not available")
+}
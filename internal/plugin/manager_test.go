@@ -0,0 +1,186 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+func pluginsConfig(t *testing.T, config string) *viper.Viper {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(bytes.NewBufferString(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestReloadFromConfig(t *testing.T) {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	m := NewManager(logger, emperror.NewNopHandler(), WithCircuitBreaker(testBreakerConfig))
+
+	err := m.LoadFromConfig(pluginsConfig(t, `
+plugins:
+- name: "kept"
+  type: "http"
+  http:
+    url: "http://kept"
+- name: "changed"
+  type: "http"
+  http:
+    url: "http://changed"
+- name: "removed"
+  type: "http"
+  http:
+    url: "http://removed"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Add(&failingPlugin{})
+
+	previous := make(map[string]EventHandlerPlugin)
+	for _, name := range []string{"kept", "changed", "removed", "failing"} {
+		previous[name], err = m.GetByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v := pluginsConfig(t, `
+plugins:
+- name: "kept"
+  type: "http"
+  http:
+    url: "http://kept"
+- name: "changed"
+  type: "http"
+  http:
+    url: "http://changed:8080"
+  tenants: ["team-a"]
+- name: "new"
+  type: "http"
+  http:
+    url: "http://new"
+`)
+
+	// the previous plugins are restored if the new ones are rejected
+	err = m.ReloadFromConfig(v, func() error {
+		if _, err := m.GetByName("new"); err != nil {
+			t.Fatal("expected the new plugins to be in place while they are applied")
+		}
+		return errors.New("flow refers to an undefined plugin")
+	})
+	if err == nil {
+		t.Fatal("expected the error of applying the plugins")
+	}
+	if _, err := m.GetByName("removed"); err != nil {
+		t.Fatal("expected the previous plugins to be restored")
+	}
+	if _, err := m.GetByName("new"); err == nil {
+		t.Fatal("expected the rejected plugins to be dropped")
+	}
+
+	err = m.ReloadFromConfig(v, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, same := range map[string]bool{"kept": true, "changed": false, "failing": true} {
+		p, err := m.GetByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (p == previous[name]) != same {
+			t.Fatalf("expected plugin %s to be kept: %t", name, same)
+		}
+	}
+	if _, err := m.GetByName("new"); err != nil {
+		t.Fatal("expected the new plugin to be added")
+	}
+	if _, err := m.GetByName("removed"); err == nil {
+		t.Fatal("expected the removed plugin to be dropped")
+	}
+	if tenants := m.Tenants("changed"); len(tenants) != 1 || tenants[0] != "team-a" {
+		t.Fatalf("expected the tenants of the changed plugin, got %v", tenants)
+	}
+
+	statuses := m.Status()
+	if len(statuses) != 4 {
+		t.Fatalf("expected a status for every plugin, got %+v", statuses)
+	}
+	for _, s := range statuses {
+		if s.Breaker == nil {
+			t.Fatalf("expected plugin %s to be guarded by a circuit breaker", s.Name)
+		}
+	}
+}
+
+func TestReloadInvalidConfig(t *testing.T) {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	m := NewManager(logger, emperror.NewNopHandler())
+
+	err := m.LoadFromConfig(pluginsConfig(t, `
+plugins:
+- name: "kept"
+  type: "http"
+  http:
+    url: "http://kept"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := false
+	err = m.ReloadFromConfig(pluginsConfig(t, `
+plugins:
+- name: "invalid"
+  type: "unknown"
+`), func() error {
+		applied = true
+		return nil
+	})
+	if err == nil || applied {
+		t.Fatal("expected invalid plugins to be rejected before they are applied")
+	}
+	if _, err := m.GetByName("kept"); err != nil {
+		t.Fatal("expected the previous plugins to be kept")
+	}
+}
+
+func TestLoadWithoutPlugins(t *testing.T) {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	m := NewManager(logger, emperror.NewNopHandler())
+
+	// flows may use the internal plugins only
+	err := m.LoadFromConfig(viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Status()) != 0 {
+		t.Fatal("expected no plugins to be loaded")
+	}
+}
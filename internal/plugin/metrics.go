@@ -0,0 +1,62 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nolint: gochecknoglobals
+var (
+	breakerStateDesc = prometheus.NewDesc(
+		"hollowtrees_plugin_circuit_breaker_state",
+		"State of the plugin circuit breaker: 0 closed, 1 half-open, 2 open.",
+		[]string{"plugin"}, nil,
+	)
+	pluginCallsDesc = prometheus.NewDesc(
+		"hollowtrees_plugin_calls_total",
+		"Plugin calls guarded by a circuit breaker by result: success, error or rejected.",
+		[]string{"plugin", "result"}, nil,
+	)
+)
+
+// Describe implements prometheus.Collector
+func (m *Manager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+	ch <- pluginCallsDesc
+}
+
+// Collect implements prometheus.Collector, it reports the circuit breaker states
+func (m *Manager) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for name, b := range m.breakers {
+		s := b.status()
+
+		var state float64
+		switch s.State {
+		case BreakerHalfOpen:
+			state = 1
+		case BreakerOpen:
+			state = 2
+		}
+
+		ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, state, name)
+		ch <- prometheus.MustNewConstMetric(pluginCallsDesc, prometheus.CounterValue, float64(s.Successes), name, "success")
+		ch <- prometheus.MustNewConstMetric(pluginCallsDesc, prometheus.CounterValue, float64(s.Errors), name, "error")
+		ch <- prometheus.MustNewConstMetric(pluginCallsDesc, prometheus.CounterValue, float64(s.Rejected), name, "rejected")
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// States of a long-running plugin operation
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+
+	DefaultOperationPollInterval = 10 * time.Second
+	DefaultOperationTimeout      = time.Hour
+
+	// callbacks arriving before the event flow starts waiting are kept this long
+	earlyCallbackExpiration = 10 * time.Minute
+)
+
+// nolint: gochecknoglobals
+var (
+	// ErrOperationTimeout is returned if a long-running operation does not finish in time
+	ErrOperationTimeout = errors.New("operation timed out")
+
+	// ErrInvalidCallbackToken is returned for callbacks to callback URLs not issued by the manager
+	ErrInvalidCallbackToken = errors.New("invalid callback token")
+
+	// ErrOperationNotReported is returned if the plugin can not be polled for the state of the
+	// operation and no callback URL is configured for it to report the state
+	ErrOperationNotReported = errors.New("plugin does not report the state of its operations")
+)
+
+// OperationConfig holds configuration values for the long-running operations of plugins
+type OperationConfig struct {
+	// Interval of polling the status of operations of plugins implementing GetStatus
+	PollInterval time.Duration
+
+	// Operations not finished within this period fail, unless the step sets its own timeout
+	Timeout time.Duration
+
+	// Base URL of the admin API plugins report finished operations to (eg. http://hollowtrees:8083/api/v1),
+	// operations are only polled if empty
+	CallbackURL string
+}
+
+// Validate validates the operation configuration
+func (c OperationConfig) Validate() error {
+	if c.PollInterval <= 0 {
+		return errors.New("poll interval must be positive")
+	}
+
+	if c.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+
+	if c.CallbackURL != "" {
+		u, err := url.Parse(c.CallbackURL)
+		if err != nil {
+			return emperror.Wrap(err, "invalid callback URL")
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return emperror.With(errors.New("callback URL must be an HTTP URL"), "url", c.CallbackURL)
+		}
+	}
+
+	return nil
+}
+
+// OperationStatus describes the state of a long-running plugin operation
+type OperationStatus struct {
+	State  string `json:"state"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s OperationStatus) finished() bool {
+	return s.State == OperationSucceeded || s.State == OperationFailed
+}
+
+// StatusPoller is implemented by plugins which can be asked for the state of their operations
+type StatusPoller interface {
+	GetStatus(ctx context.Context, operationID string) (*OperationStatus, error)
+}
+
+// callbackIssuer returns the callback URL of a plugin call with the token it contains
+type callbackIssuer func() (callbackURL string, token string)
+
+// operations passes the reported operation states to the event flows waiting for them,
+// they are keyed by the plugin, the operation and the callback token of the plugin call
+type operations struct {
+	mu      sync.Mutex
+	waiters map[string]func(OperationStatus)
+	early   *cache.Cache
+}
+
+func newOperations() *operations {
+	return &operations{
+		waiters: make(map[string]func(OperationStatus)),
+		early:   cache.New(earlyCallbackExpiration, earlyCallbackExpiration),
+	}
+}
+
+// wait registers the function called with the state of the operation when it is reported,
+// it returns the state right away if it was reported before
+func (o *operations) wait(key string, fn func(OperationStatus)) (OperationStatus, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if s, ok := o.early.Get(key); ok {
+		o.early.Delete(key)
+		return s.(OperationStatus), true
+	}
+	o.waiters[key] = fn
+
+	return OperationStatus{}, false
+}
+
+func (o *operations) forget(key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.waiters, key)
+}
+
+func (o *operations) complete(key string, s OperationStatus) {
+	o.mu.Lock()
+	fn, ok := o.waiters[key]
+	if !ok {
+		o.early.SetDefault(key, s)
+		o.mu.Unlock()
+		return
+	}
+	delete(o.waiters, key)
+	o.mu.Unlock()
+
+	fn(s)
+}
+
+// operationWatch waits for a long-running operation on the timers of the clock without blocking
+// a goroutine, it finishes once: when the state is reported, polled or the timeout passes
+type operationWatch struct {
+	manager     *Manager
+	name        string
+	operationID string
+	key         string
+	poller      StatusPoller
+	callbacks   bool
+	logger      log.Logger
+	done        func(*OperationStatus, error)
+
+	mu       sync.Mutex
+	finished bool
+	poll     clock.Timer
+	timeout  clock.Timer
+}
+
+// WatchOperation waits for the long-running operation started by a plugin call to finish and calls done
+// with its state, it returns right away and done is called on a timer of the clock, the state is reported by
+// a callback or polled if the plugin supports it, waiting fails when the timeout or, if it is not positive,
+// the operation timeout passes
+func (m *Manager) WatchOperation(name string, result *Result, timeout time.Duration, done func(*OperationStatus, error)) {
+	operationID := result.OperationID
+	callbacks := result.callbackToken != ""
+
+	m.mu.RLock()
+	poller, ok := m.pollers[name]
+	m.mu.RUnlock()
+	if !ok && !callbacks {
+		m.clock.AfterFunc(0, func() {
+			done(nil, emperror.With(ErrOperationNotReported, "plugin", name, "operation", operationID))
+		})
+		return
+	}
+
+	if timeout <= 0 {
+		timeout = m.operationConfig.Timeout
+	}
+
+	w := &operationWatch{
+		manager:     m,
+		name:        name,
+		operationID: operationID,
+		key:         operationKey(name, operationID, result.callbackToken),
+		poller:      poller,
+		callbacks:   callbacks,
+		logger:      m.logger.WithFields(log.Fields{"plugin": name, "operation": operationID}),
+		done:        done,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if s, ok := m.operations.wait(w.key, w.report); ok {
+		w.report(s)
+		return
+	}
+
+	w.timeout = m.clock.AfterFunc(timeout, func() {
+		w.finish(nil, emperror.With(ErrOperationTimeout, "plugin", name, "operation", operationID))
+	})
+	if poller != nil {
+		w.poll = m.clock.AfterFunc(m.operationConfig.PollInterval, w.pollStatus)
+	}
+}
+
+// report finishes the watch with the reported state on a timer, so the event flow
+// does not continue in the goroutine of the callback request
+func (w *operationWatch) report(s OperationStatus) {
+	w.manager.clock.AfterFunc(0, func() {
+		w.finish(&s, nil)
+	})
+}
+
+// pollStatus asks the plugin for the state of the operation and polls again after the poll interval
+func (w *operationWatch) pollStatus() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.manager.operationConfig.PollInterval)
+	s, err := w.poller.GetStatus(ctx, w.operationID)
+	cancel()
+
+	switch status.Code(err) {
+	case codes.OK:
+		if s.finished() {
+			w.finish(s, nil)
+			return
+		}
+	case codes.Unimplemented:
+		if !w.callbacks {
+			w.finish(nil, emperror.With(ErrOperationNotReported, "plugin", w.name, "operation", w.operationID))
+			return
+		}
+		w.logger.Debug("plugin does not report operation states, waiting for callback")
+		return
+	case codes.NotFound:
+		// another replica of the plugin may run the operation
+		w.logger.Debug("operation not found")
+	default:
+		w.logger.WithField("error", err.Error()).Warn("could not get operation state")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.finished {
+		w.poll = w.manager.clock.AfterFunc(w.manager.operationConfig.PollInterval, w.pollStatus)
+	}
+}
+
+// finish stops waiting for the operation and calls done unless the watch finished already
+func (w *operationWatch) finish(s *OperationStatus, err error) {
+	w.mu.Lock()
+	if w.finished {
+		w.mu.Unlock()
+		return
+	}
+	w.finished = true
+	if w.poll != nil {
+		w.poll.Stop()
+	}
+	if w.timeout != nil {
+		w.timeout.Stop()
+	}
+	w.mu.Unlock()
+
+	w.manager.operations.forget(w.key)
+	w.done(s, err)
+}
+
+// CompleteOperation reports the final state of a long-running plugin operation to the
+// callback URL of the plugin call, the token of the URL must be issued by the manager
+func (m *Manager) CompleteOperation(name string, token string, operationID string, s OperationStatus) error {
+	if _, err := m.GetByName(name); err != nil {
+		return err
+	}
+
+	if !m.verifyCallbackToken(name, token) {
+		return emperror.With(ErrInvalidCallbackToken, "plugin", name, "operation", operationID)
+	}
+
+	switch s.State {
+	case OperationRunning:
+		return nil
+	case OperationSucceeded, OperationFailed:
+	default:
+		return emperror.With(errors.New("invalid operation state"), "state", s.State)
+	}
+
+	m.operations.complete(operationKey(name, operationID, token), s)
+
+	return nil
+}
+
+func operationKey(name string, operationID string, token string) string {
+	return name + "/" + operationID + "/" + token
+}
+
+// callbackIssuer returns the issuer of the URLs the plugin reports its finished operations to, every
+// plugin call gets its own URL, nil if no callback URL is configured
+func (m *Manager) callbackIssuer(name string) callbackIssuer {
+	if m.operationConfig.CallbackURL == "" {
+		return nil
+	}
+
+	base := strings.TrimSuffix(m.operationConfig.CallbackURL, "/") + "/plugins/" + url.PathEscape(name) + "/operations/"
+
+	return func() (string, string) {
+		nonce := uuid.NewV4().String()
+		token := nonce + "." + m.signCallback(name, nonce)
+
+		return base + url.PathEscape(token), token
+	}
+}
+
+// signCallback returns the signature of the callback token nonce for the plugin
+func (m *Manager) signCallback(name string, nonce string) string {
+	mac := hmac.New(sha256.New, m.callbackSecret)
+	_, _ = mac.Write([]byte(name + "/" + nonce))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCallbackToken checks that the callback token was issued for a call of the plugin
+func (m *Manager) verifyCallbackToken(name string, token string) bool {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return false
+	}
+
+	return hmac.Equal([]byte(token[i+1:]), []byte(m.signCallback(name, token[:i])))
+}
@@ -0,0 +1,210 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+type operationPlugin struct {
+	BasePlugin
+}
+
+func (p *operationPlugin) Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error) {
+	return &Result{}, nil
+}
+
+// unimplementedPoller is a plugin not implementing the GetStatus call
+type unimplementedPoller struct {
+	operationPlugin
+}
+
+func (p *unimplementedPoller) GetStatus(ctx context.Context, operationID string) (*OperationStatus, error) {
+	return nil, status.Error(codes.Unimplemented, "GetStatus is not implemented")
+}
+
+func newOperationTestManager(callbackURL string, plugins ...EventHandlerPlugin) *Manager {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	m := NewManager(logger, emperror.NewNopHandler(), WithOperations(OperationConfig{
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      time.Second,
+		CallbackURL:  callbackURL,
+	}))
+	m.Add(plugins...)
+
+	return m
+}
+
+type watchResult struct {
+	status *OperationStatus
+	err    error
+}
+
+// watch waits for the operation and returns the channel its outcome is sent on
+func watch(m *Manager, name string, result *Result) <-chan watchResult {
+	ch := make(chan watchResult, 1)
+	m.WatchOperation(name, result, 0, func(s *OperationStatus, err error) {
+		ch <- watchResult{status: s, err: err}
+	})
+
+	return ch
+}
+
+func TestOperationCallbacks(t *testing.T) {
+	r := gin.New()
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	m := newOperationTestManager(server.URL+"/api/v1", &operationPlugin{NewBasePlugin("drain")}, &operationPlugin{NewBasePlugin("notify")})
+	NewAPI(m).RegisterRoutes(r.Group("/api/v1"))
+
+	post := func(url string) int {
+		resp, err := http.Post(url, "application/json", bytes.NewBufferString(`{"state": "succeeded", "status": "drained"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	callbackURL, token := m.callbackIssuer("drain")()
+	if !strings.HasPrefix(callbackURL, server.URL+"/api/v1/plugins/drain/operations/") {
+		t.Fatalf("unexpected callback URL: %s", callbackURL)
+	}
+
+	done := watch(m, "drain", &Result{OperationID: "op-1", callbackToken: token})
+
+	// tokens not issued by the manager or issued for another plugin are rejected
+	if code := post(server.URL + "/api/v1/plugins/drain/operations/forged.token/op-1"); code != http.StatusForbidden {
+		t.Fatalf("expected a forged token to be rejected, got %d", code)
+	}
+	otherURL, _ := m.callbackIssuer("notify")()
+	if code := post(strings.Replace(otherURL, "/plugins/notify/", "/plugins/drain/", 1) + "/op-1"); code != http.StatusForbidden {
+		t.Fatalf("expected the token of another plugin to be rejected, got %d", code)
+	}
+
+	// the callback URL of another call does not complete the operation
+	anotherURL, _ := m.callbackIssuer("drain")()
+	if code := post(anotherURL + "/op-1"); code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	select {
+	case <-done:
+		t.Fatal("expected the operation to be completed by the callback URL of its call only")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if code := post(callbackURL + "/op-1"); code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if r := <-done; r.err != nil || r.status.State != OperationSucceeded || r.status.Status != "drained" {
+		t.Fatalf("unexpected operation state: %+v", r)
+	}
+}
+
+func TestOperationEarlyCallback(t *testing.T) {
+	m := newOperationTestManager("http://hollowtrees:8083/api/v1", &operationPlugin{NewBasePlugin("drain")})
+
+	_, token := m.callbackIssuer("drain")()
+	err := m.CompleteOperation("drain", token, "op-1", OperationStatus{State: OperationFailed, Error: "eviction failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-watch(m, "drain", &Result{OperationID: "op-1", callbackToken: token})
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.status.State != OperationFailed {
+		t.Fatalf("unexpected operation state: %+v", r.status)
+	}
+}
+
+func TestOperationNotReported(t *testing.T) {
+	m := newOperationTestManager("", &operationPlugin{NewBasePlugin("drain")}, &unimplementedPoller{operationPlugin{NewBasePlugin("scale")}})
+
+	// without a callback URL nothing would report the state of the operations
+	for _, name := range []string{"drain", "scale"} {
+		r := <-watch(m, name, &Result{OperationID: "op-1"})
+		if errors.Cause(r.err) != ErrOperationNotReported {
+			t.Fatalf("expected the operation of %s to fail right away, got %v", name, r.err)
+		}
+	}
+}
+
+// runningPoller is a plugin whose operations finish after the given number of polls
+type runningPoller struct {
+	operationPlugin
+	polls  int
+	polled chan struct{}
+}
+
+func (p *runningPoller) GetStatus(ctx context.Context, operationID string) (*OperationStatus, error) {
+	p.polled <- struct{}{}
+	p.polls--
+	if p.polls > 0 {
+		return &OperationStatus{State: OperationRunning}, nil
+	}
+
+	return &OperationStatus{State: OperationSucceeded, Status: "scaled"}, nil
+}
+
+func TestOperationPolling(t *testing.T) {
+	p := &runningPoller{operationPlugin: operationPlugin{NewBasePlugin("scale")}, polls: 3, polled: make(chan struct{}, 3)}
+	m := newOperationTestManager("", p)
+
+	r := <-watch(m, "scale", &Result{OperationID: "op-1"})
+	if r.err != nil || r.status.Status != "scaled" {
+		t.Fatalf("unexpected operation state: %+v", r)
+	}
+	if len(p.polled) != 3 {
+		t.Fatalf("expected the operation to be polled until it finished, got %d polls", len(p.polled))
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	p := &runningPoller{operationPlugin: operationPlugin{NewBasePlugin("scale")}, polls: 1000, polled: make(chan struct{}, 1000)}
+	m := newOperationTestManager("", p)
+
+	ch := make(chan watchResult, 1)
+	m.WatchOperation("scale", &Result{OperationID: "op-1"}, 50*time.Millisecond, func(s *OperationStatus, err error) {
+		ch <- watchResult{status: s, err: err}
+	})
+
+	select {
+	case r := <-ch:
+		if errors.Cause(r.err) != ErrOperationTimeout {
+			t.Fatalf("expected the operation to time out, got %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the operation to time out at the timeout of the step")
+	}
+}
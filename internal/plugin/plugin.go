@@ -15,13 +15,29 @@
 package plugin
 
 import (
+	"context"
+
 	"github.com/banzaicloud/hollowtrees/internal/ce"
 )
 
 // EventHandlerPlugin defines an event handler plugin
 type EventHandlerPlugin interface {
 	GetName() string
-	Handle(event *ce.Event) error
+	Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error)
+}
+
+// Params holds the rendered parameters of a flow step passed to the plugin
+type Params map[string]string
+
+// Result describes the outcome of a plugin call
+type Result struct {
+	Status string            `json:"status"`
+	Output map[string]string `json:"output,omitempty"`
+	// Set if the plugin started a long-running operation instead of finishing the action
+	OperationID string `json:"operationId,omitempty"`
+
+	// token of the callback URL the plugin reports the operation to
+	callbackToken string
 }
 
 // BasePlugin describes a basic plugin struct
@@ -29,6 +45,13 @@ type BasePlugin struct {
 	name string
 }
 
+// NewBasePlugin returns a BasePlugin with the given name
+func NewBasePlugin(name string) BasePlugin {
+	return BasePlugin{
+		name: name,
+	}
+}
+
 // GetName returns the plugin name
 func (p *BasePlugin) GetName() string {
 	return p.name
@@ -0,0 +1,44 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+)
+
+// Progress is a progress update of a plugin handling an event
+type Progress struct {
+	Percentage int               `json:"percentage"`
+	Message    string            `json:"message,omitempty"`
+	Output     map[string]string `json:"output,omitempty"`
+}
+
+// ProgressFunc receives the progress updates of a plugin call
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgressFunc returns a context passing the progress updates of the plugin called with it to f
+func WithProgressFunc(ctx context.Context, f ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+// ReportProgress passes a progress update to the caller of the plugin, if it listens for them,
+// plugins must report from the goroutine of their Handle call before it returns
+func ReportProgress(ctx context.Context, p Progress) {
+	if f, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		f(p)
+	}
+}
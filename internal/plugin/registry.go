@@ -0,0 +1,114 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/goph/emperror"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// DemoPluginName is the name of the internal demo plugin the daemon always adds
+const DemoPluginName = "internal-demo"
+
+// Factory creates an internal plugin with the given name from its configuration
+type Factory func(name string, config map[string]interface{}, deps Dependencies) (EventHandlerPlugin, error)
+
+// Dependencies holds the services of the daemon available to internal plugins
+type Dependencies struct {
+	Logger    log.Logger
+	Publisher EventPublisher
+	Clock     clock.Clock
+
+	// Event attributes identifying the tenant of an event, events published for a
+	// handled event must keep them
+	TenantKeys []string
+}
+
+// EventPublisher publishes new events to be processed by the action flows
+type EventPublisher interface {
+	Publish(event *ce.Event) error
+}
+
+// nolint: gochecknoglobals
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes an internal plugin implementation available by the given name,
+// it is meant to be called from the init function of the implementing package
+func Register(implementation string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("plugin: Register factory is nil")
+	}
+	if _, dup := registry[implementation]; dup {
+		panic("plugin: Register called twice for implementation " + implementation)
+	}
+
+	registry[implementation] = factory
+}
+
+// Implementations returns the sorted names of the registered internal plugin implementations
+func Implementations() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func getFactory(implementation string) (Factory, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factory, ok := registry[implementation]
+	if !ok {
+		return nil, emperror.With(errors.New("unknown internal plugin implementation"), "implementation", implementation)
+	}
+
+	return factory, nil
+}
+
+// DecodeConfig decodes the configuration map of an internal plugin into target,
+// string values are converted to durations and other basic types where needed
+func DecodeConfig(config map[string]interface{}, target interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+		Result:           target,
+	})
+	if err != nil {
+		return err
+	}
+
+	return emperror.Wrap(decoder.Decode(config), "could not decode plugin config")
+}
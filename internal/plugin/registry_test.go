@@ -0,0 +1,156 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+type factoryConfig struct {
+	Message string        `mapstructure:"message"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// configuredPlugin is an internal plugin returning its configuration as the result
+type configuredPlugin struct {
+	BasePlugin
+	config factoryConfig
+}
+
+func (p *configuredPlugin) Handle(ctx context.Context, event *ce.Event, params Params) (*Result, error) {
+	return &Result{Status: p.config.Message, Output: map[string]string{"timeout": p.config.Timeout.String()}}, nil
+}
+
+func configuredFactory(name string, config map[string]interface{}, deps Dependencies) (EventHandlerPlugin, error) {
+	var c factoryConfig
+	err := DecodeConfig(config, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &configuredPlugin{BasePlugin: NewBasePlugin(name), config: c}, nil
+}
+
+// registerTestImplementation registers the factory under a name unique to the test run
+func registerTestImplementation(factory Factory) string {
+	implementation := "test-" + uuid.NewV4().String()
+	Register(implementation, factory)
+
+	return implementation
+}
+
+func expectPanic(t *testing.T, message string, fn func()) {
+	t.Helper()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal(message)
+		}
+	}()
+	fn()
+}
+
+func TestRegister(t *testing.T) {
+	implementation := registerTestImplementation(configuredFactory)
+
+	found := false
+	implementations := Implementations()
+	for i, name := range implementations {
+		if name == implementation {
+			found = true
+		}
+		if i > 0 && implementations[i-1] > name {
+			t.Fatalf("expected the implementations to be sorted, got %v", implementations)
+		}
+	}
+	if !found {
+		t.Fatalf("expected %s to be registered, got %v", implementation, implementations)
+	}
+
+	expectPanic(t, "expected registering an implementation twice to panic", func() {
+		Register(implementation, configuredFactory)
+	})
+	expectPanic(t, "expected registering a nil factory to panic", func() {
+		Register("test-nil", nil)
+	})
+}
+
+func TestLoadInternalPlugins(t *testing.T) {
+	implementation := registerTestImplementation(configuredFactory)
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(bytes.NewBufferString(`
+plugins:
+- name: "configured"
+  type: "internal"
+  implementation: "` + implementation + `"
+  config:
+    message: "hello"
+    timeout: "5m"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	m := NewManager(logger, emperror.NewNopHandler())
+	err = m.LoadFromConfig(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := m.GetByName("configured")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.Handle(context.Background(), testEvent(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "hello" || result.Output["timeout"] != "5m0s" {
+		t.Fatalf("expected the plugin to be created from its config, got %+v", result)
+	}
+}
+
+func TestNewInternalPluginErrors(t *testing.T) {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	m := NewManager(logger, emperror.NewNopHandler())
+
+	_, err := m.NewInternalPlugin("unknown", "test-unknown", nil)
+	if err == nil {
+		t.Fatal("expected an unknown implementation to fail")
+	}
+
+	implementation := registerTestImplementation(configuredFactory)
+	_, err = m.NewInternalPlugin("configured", implementation, map[string]interface{}{"unknown": "value"})
+	if err == nil {
+		t.Fatal("expected an unknown config key to fail")
+	}
+	_, err = m.NewInternalPlugin("configured", implementation, map[string]interface{}{"timeout": "soon"})
+	if err == nil {
+		t.Fatal("expected an invalid duration to fail")
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	uuid "github.com/satori/go.uuid"
 	"gopkg.in/go-playground/validator.v9"
 
 	"github.com/banzaicloud/hollowtrees/internal/ce"
@@ -73,10 +72,28 @@ func (a *Alert) convertToCE(cid string) (*ce.Event, error) {
 	for k, v := range a.Labels {
 		e.Set(k, v)
 	}
+
+	// resolved alerts are sent with an end time in the past
+	status := ce.AlertFiring
+	if !a.EndsAt.IsZero() && !a.EndsAt.After(time.Now()) {
+		status = ce.AlertResolved
+	}
+	e.Set(ce.AlertStatusKey, status)
+	e.Set(ce.AlertIDKey, ce.Fingerprint(a.Labels))
+
+	// the status is part of the fingerprint, so the resolved alert is not a duplicate of the firing one
+	values := make(map[string]string, len(a.Labels)+1)
+	for k, v := range a.Labels {
+		values[k] = v
+	}
+	values[ce.AlertStatusKey] = status
+	fingerprint := ce.Fingerprint(values)
+
 	e.Set("correlationid", cid)
 	e.Set("labels", a.Labels)
+	e.Set(ce.FingerprintKey, fingerprint)
 
-	e.Set("id", uuid.NewV4().String())
+	e.Set("id", ce.IDFromFingerprint(fingerprint))
 	e.Set("type", fmt.Sprintf("%s%s", CETypePrefix, a.Labels["alertname"]))
 	e.Set("specversion", "0.2")
 	u, err := url.Parse(a.GeneratorURL)
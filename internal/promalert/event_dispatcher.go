@@ -19,7 +19,7 @@ import (
 )
 
 type baseEventPublisher interface {
-	Publish(topic string, args ...interface{})
+	Publish(topic string, args ...interface{}) error
 }
 
 type eventDispatcher struct {
@@ -27,7 +27,7 @@ type eventDispatcher struct {
 }
 
 type eventPublisher interface {
-	Publish(topic string, event *ce.Event)
+	Publish(topic string, event *ce.Event) error
 }
 
 // NewEventDispatcher returns a new event dispatcher
@@ -38,6 +38,6 @@ func NewEventDispatcher(eb baseEventPublisher) *eventDispatcher {
 }
 
 // Publish sends the given event through the event dispatcher
-func (b *eventDispatcher) Publish(topic string, event *ce.Event) {
-	b.eb.Publish(topic, event)
+func (b *eventDispatcher) Publish(topic string, event *ce.Event) error {
+	return b.eb.Publish(topic, event)
 }
@@ -19,7 +19,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/goph/emperror"
+	"github.com/pkg/errors"
 
+	"github.com/banzaicloud/hollowtrees/internal/platform/eventbus"
 	"github.com/banzaicloud/hollowtrees/internal/platform/gin/correlationid"
 	ginlog "github.com/banzaicloud/hollowtrees/internal/platform/gin/log"
 	"github.com/banzaicloud/hollowtrees/internal/platform/log"
@@ -114,7 +116,18 @@ func (p *PromAlertHandler) handle(c *gin.Context) {
 	log.WithField("alert-count", len(alerts)).Debug("alerts received")
 
 	cid := c.GetString(correlationid.ContextKey)
-	p.publishAlerts(alerts, cid)
+	if err := p.publishAlerts(alerts, cid); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Cause(err) == eventbus.ErrQueueFull {
+			status = http.StatusTooManyRequests
+		}
+		c.AbortWithStatusJSON(status, gin.H{
+			"status":  status,
+			"message": "could not process alerts",
+			"error":   err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": http.StatusOK,
@@ -123,13 +136,19 @@ func (p *PromAlertHandler) handle(c *gin.Context) {
 }
 
 // publishAlerts publishing incoming alerts through the event dispatcher
-func (p *PromAlertHandler) publishAlerts(alerts []Alert, cid string) {
+func (p *PromAlertHandler) publishAlerts(alerts []Alert, cid string) error {
 	for _, alert := range alerts {
 		event, err := alert.convertToCE(cid)
 		if err != nil {
 			p.errorHandler.Handle(err)
 			continue
 		}
-		p.eb.Publish(EventTopic, event)
+
+		err = p.eb.Publish(EventTopic, event)
+		if err != nil {
+			return emperror.Wrap(err, "could not publish alert")
+		}
 	}
+
+	return nil
 }
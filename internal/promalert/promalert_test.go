@@ -0,0 +1,88 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promalert
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/eventbus"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+type testPublisher struct {
+	err       error
+	published []*ce.Event
+}
+
+func (p *testPublisher) Publish(topic string, event *ce.Event) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event)
+
+	return nil
+}
+
+const testAlerts = `[{
+	"labels": {"alertname": "NodeNotReady", "cluster_id": "1", "org_id": "1"},
+	"generatorURL": "http://prometheus"
+}]`
+
+func postAlerts(publisher eventPublisher) int {
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	p := New(Config{}, logger, emperror.NewNopHandler(), publisher)
+
+	r := gin.New()
+	r.POST("/api/v1/alerts", p.handle)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts", bytes.NewBufferString(testAlerts))
+	r.ServeHTTP(w, req)
+
+	return w.Code
+}
+
+func TestHandlePublishesAlerts(t *testing.T) {
+	publisher := &testPublisher{}
+	if code := postAlerts(publisher); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+
+	if len(publisher.published) != 1 {
+		t.Fatalf("expected the alert to be published, got %d events", len(publisher.published))
+	}
+	if event := publisher.published[0]; event.Type != CETypePrefix+"NodeNotReady" {
+		t.Fatalf("unexpected event type: %s", event.Type)
+	}
+}
+
+func TestHandleFullQueue(t *testing.T) {
+	publisher := &testPublisher{err: eventbus.ErrQueueFull}
+	if code := postAlerts(publisher); code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", code)
+	}
+
+	publisher = &testPublisher{err: emperror.Wrap(eventbus.ErrQueueFull, "wrapped")}
+	if code := postAlerts(publisher); code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 for a wrapped error, got %d", code)
+	}
+}
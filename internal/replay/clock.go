@@ -0,0 +1,195 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/clock"
+)
+
+// simClock is a clock whose time only moves when it is advanced, timers and
+// sleeping goroutines are woken one by one in the order of their deadlines
+type simClock struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	now  time.Time
+
+	// number of tracked goroutines which are neither finished nor sleeping
+	running  int
+	sleepers []*sleeper
+}
+
+type sleeper struct {
+	until time.Time
+	wake  chan struct{}
+
+	// whether the sleeping goroutine was started by the clock
+	tracked bool
+}
+
+func newSimClock(now time.Time) *simClock {
+	c := &simClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// Now returns the simulated time
+func (c *simClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Sleep blocks until the simulated time is advanced past the duration d, the
+// calling goroutine is not tracked by the clock, so the code run by the clock
+// must not sleep but schedule its waits with AfterFunc
+func (c *simClock) Sleep(d time.Duration) {
+	c.sleep(d, false)
+}
+
+func (c *simClock) sleep(d time.Duration, tracked bool) {
+	if d <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	s := &sleeper{
+		until:   c.now.Add(d),
+		wake:    make(chan struct{}),
+		tracked: tracked,
+	}
+	c.sleepers = append(c.sleepers, s)
+	if tracked {
+		c.running--
+		c.cond.Broadcast()
+	}
+	c.mu.Unlock()
+
+	<-s.wake
+}
+
+// Go runs fn in a goroutine tracked by the clock
+func (c *simClock) Go(fn func()) {
+	c.mu.Lock()
+	c.running++
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			c.running--
+			c.cond.Broadcast()
+			c.mu.Unlock()
+		}()
+
+		fn()
+	}()
+}
+
+// AfterFunc calls fn in a goroutine tracked by the clock once the simulated time is advanced past the duration d
+func (c *simClock) AfterFunc(d time.Duration, fn func()) clock.Timer {
+	t := &simTimer{}
+	c.Go(func() {
+		c.sleep(d, true)
+		if t.fire() {
+			fn()
+		}
+	})
+
+	return t
+}
+
+// simTimer is a timer of the simulated clock, stopped timers still sleep until their deadline
+type simTimer struct {
+	mu   sync.Mutex
+	done bool
+}
+
+func (t *simTimer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return false
+	}
+	t.done = true
+
+	return true
+}
+
+func (t *simTimer) fire() bool {
+	return t.Stop()
+}
+
+// Wait blocks until every tracked goroutine is either finished or sleeping
+func (c *simClock) Wait() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.running > 0 {
+		c.cond.Wait()
+	}
+}
+
+// AdvanceTo moves the simulated time forward to t, waking the sleepers with an earlier deadline
+func (c *simClock) AdvanceTo(t time.Time) {
+	for c.wakeNext(t, false) {
+	}
+}
+
+// Drain wakes every sleeper regardless of its deadline
+func (c *simClock) Drain() {
+	for c.wakeNext(time.Time{}, true) {
+	}
+}
+
+// wakeNext wakes the sleeper with the earliest deadline not after t and waits until
+// it finishes or sleeps again if it is tracked, it returns false when there is no such sleeper
+func (c *simClock) wakeNext(t time.Time, all bool) bool {
+	c.Wait()
+
+	c.mu.Lock()
+	sort.SliceStable(c.sleepers, func(i, j int) bool {
+		return c.sleepers[i].until.Before(c.sleepers[j].until)
+	})
+
+	if len(c.sleepers) == 0 || (!all && c.sleepers[0].until.After(t)) {
+		if !all && t.After(c.now) {
+			c.now = t
+		}
+		c.mu.Unlock()
+		return false
+	}
+
+	s := c.sleepers[0]
+	c.sleepers = c.sleepers[1:]
+	if s.until.After(c.now) {
+		c.now = s.until
+	}
+	if s.tracked {
+		c.running++
+	}
+	close(s.wake)
+	c.mu.Unlock()
+
+	c.Wait()
+
+	return true
+}
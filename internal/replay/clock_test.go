@@ -0,0 +1,134 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSimClockTimerOrder(t *testing.T) {
+	start := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	c := newSimClock(start)
+
+	var mu sync.Mutex
+	var fired []time.Duration
+	for _, d := range []time.Duration{3 * time.Minute, time.Minute, 2 * time.Minute} {
+		d := d
+		c.AfterFunc(d, func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if now := c.Now(); !now.Equal(start.Add(d)) {
+				t.Errorf("expected the timer of %s to fire at %s, got %s", d, start.Add(d), now)
+			}
+			fired = append(fired, d)
+		})
+	}
+	stopped := c.AfterFunc(time.Minute, func() {
+		t.Error("expected the stopped timer not to fire")
+	})
+	if !stopped.Stop() {
+		t.Fatal("expected the timer to be stopped")
+	}
+
+	c.AdvanceTo(start.Add(90 * time.Second))
+	if len(fired) != 1 || fired[0] != time.Minute {
+		t.Fatalf("expected only the first timer to fire, got %v", fired)
+	}
+	if now := c.Now(); !now.Equal(start.Add(90 * time.Second)) {
+		t.Fatalf("expected the clock to be advanced to the given time, got %s", now)
+	}
+
+	c.AdvanceTo(start.Add(time.Hour))
+	if len(fired) != 3 || fired[1] != 2*time.Minute || fired[2] != 3*time.Minute {
+		t.Fatalf("expected the timers to fire in the order of their deadlines, got %v", fired)
+	}
+}
+
+func TestSimClockTimerScheduledByTimer(t *testing.T) {
+	start := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	c := newSimClock(start)
+
+	done := make(chan time.Time, 1)
+	c.AfterFunc(time.Minute, func() {
+		c.AfterFunc(time.Minute, func() {
+			done <- c.Now()
+		})
+	})
+
+	c.Drain()
+	select {
+	case now := <-done:
+		if !now.Equal(start.Add(2 * time.Minute)) {
+			t.Fatalf("expected the second timer to fire at %s, got %s", start.Add(2*time.Minute), now)
+		}
+	default:
+		t.Fatal("expected draining to fire the timer scheduled by another timer")
+	}
+}
+
+func TestSimClockUntrackedSleep(t *testing.T) {
+	start := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	c := newSimClock(start)
+
+	woken := make(chan struct{})
+	go func() {
+		c.Sleep(time.Minute)
+		close(woken)
+	}()
+
+	// wait until the goroutine sleeps
+	for {
+		c.mu.Lock()
+		sleeping := len(c.sleepers) == 1
+		c.mu.Unlock()
+		if sleeping {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a sleeping goroutine not started by the clock leaves the count of running goroutines alone
+	c.Wait()
+	c.mu.Lock()
+	running := c.running
+	c.mu.Unlock()
+	if running != 0 {
+		t.Fatalf("expected no running goroutines, got %d", running)
+	}
+
+	c.AdvanceTo(start.Add(30 * time.Second))
+	select {
+	case <-woken:
+		t.Fatal("expected the goroutine to sleep until its deadline")
+	default:
+	}
+
+	c.AdvanceTo(start.Add(time.Minute))
+	select {
+	case <-woken:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the goroutine to be woken")
+	}
+
+	c.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running != 0 {
+		t.Fatalf("expected no running goroutines, got %d", c.running)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"context"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// dryRunPlugin stands in for a configured plugin during replay, the
+// call itself is recorded as part of the event flow execution
+type dryRunPlugin struct {
+	plugin.BasePlugin
+}
+
+func newDryRunPlugin(name string) *dryRunPlugin {
+	return &dryRunPlugin{
+		BasePlugin: plugin.NewBasePlugin(name),
+	}
+}
+
+// Handle does nothing
+func (p *dryRunPlugin) Handle(ctx context.Context, event *ce.Event, params plugin.Params) (*plugin.Result, error) {
+	return &plugin.Result{Status: "dry-run"}, nil
+}
@@ -0,0 +1,189 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/goph/emperror"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/flows"
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+	"github.com/banzaicloud/hollowtrees/internal/plugin"
+)
+
+// Source provides the recorded events to replay
+type Source interface {
+	Events(query history.Query) ([]history.EventRecord, error)
+}
+
+// Report describes the result of a replay
+type Report struct {
+	// Number of replayed events
+	Events int
+
+	// Event flow executions which would have happened, in chronological order
+	Executions []history.ExecutionRecord
+}
+
+// Replayer feeds recorded events through a flow configuration in dry-run mode:
+// plugins are not called and time is simulated, so groupBy and cooldown behave
+// as if the events were arriving at the time they were recorded
+type Replayer struct {
+	logger       log.Logger
+	errorHandler emperror.Handler
+}
+
+// New returns an initialized Replayer
+func New(logger log.Logger, errorHandler emperror.Handler) *Replayer {
+	return &Replayer{
+		logger:       logger,
+		errorHandler: errorHandler,
+	}
+}
+
+// Run replays the events of source matching the query through the flows defined in v
+func (r *Replayer) Run(source Source, query history.Query, v *viper.Viper) (*Report, error) {
+	records, err := source.Events(query)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not read recorded events")
+	}
+
+	var configs flows.FlowConfigs
+	err = v.UnmarshalKey("flows", &configs)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not unmarshal flow configs")
+	}
+
+	engine := flows.EngineConfig{
+		LifecycleEvents: true,
+		MaxHops:         flows.DefaultMaxHops,
+	}
+	err = v.UnmarshalKey("flowEngine", &engine)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not unmarshal flow engine config")
+	}
+
+	// every plugin referenced by the flows is replaced with a dry-run plugin
+	plugins := plugin.NewManager(r.logger, r.errorHandler)
+	for _, config := range configs {
+		for _, name := range config.PluginNames() {
+			plugins.Add(newDryRunPlugin(name))
+		}
+	}
+
+	report := &Report{
+		Events:     len(records),
+		Executions: []history.ExecutionRecord{},
+	}
+	if len(records) == 0 {
+		return report, nil
+	}
+
+	clock := newSimClock(records[0].ReceivedAt)
+	recorder := &recorder{}
+	dispatcher := &dispatcher{clock: clock}
+
+	manager := flows.NewManager(r.logger, r.errorHandler, dispatcher, plugins,
+		flows.WithExecutionRecorder(recorder),
+		flows.WithClock(clock),
+		flows.WithEngineConfig(engine),
+	)
+	err = manager.LoadFlows(v)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		event := &ce.Event{}
+		err := event.UnmarshalJSON(record.Event)
+		if err != nil {
+			r.errorHandler.Handle(emperror.WrapWith(err, "could not unmarshal recorded event", "id", record.ID))
+			continue
+		}
+
+		clock.AdvanceTo(record.ReceivedAt)
+		dispatcher.dispatch(event)
+		clock.Wait()
+	}
+
+	// let the pending delays and the queued or deferred event flows finish
+	clock.Drain()
+
+	report.Executions = recorder.executions()
+
+	return report, nil
+}
+
+// dispatcher collects the flows instead of subscribing them to an event bus,
+// events published by the flows, eg. lifecycle events, are replayed as well
+type dispatcher struct {
+	clock *simClock
+	flows []flows.ActionFlow
+}
+
+func (d *dispatcher) SubscribeAsync(topic string, flow flows.ActionFlow) error {
+	d.flows = append(d.flows, flow)
+
+	return nil
+}
+
+func (d *dispatcher) Publish(topic string, event *ce.Event) error {
+	d.dispatch(event)
+
+	return nil
+}
+
+// dispatch hands the event to every flow in a goroutine tracked by the clock
+func (d *dispatcher) dispatch(event *ce.Event) {
+	for _, f := range d.flows {
+		f := f
+		d.clock.Go(func() {
+			f.Handle(event)
+		})
+	}
+}
+
+// recorder collects the event flow executions
+type recorder struct {
+	mu      sync.Mutex
+	records []history.ExecutionRecord
+}
+
+func (r *recorder) RecordExecution(record *history.ExecutionRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, *record)
+}
+
+func (r *recorder) executions() []history.ExecutionRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := append([]history.ExecutionRecord{}, r.records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].StartedAt.Equal(records[j].StartedAt) {
+			return records[i].FlowID < records[j].FlowID
+		}
+		return records[i].StartedAt.Before(records[j].StartedAt)
+	})
+
+	return records
+}
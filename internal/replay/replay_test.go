@@ -0,0 +1,149 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/history"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// fixture is a recorded history of events
+type fixture []history.EventRecord
+
+func (f fixture) Events(query history.Query) ([]history.EventRecord, error) {
+	return f, nil
+}
+
+func (f *fixture) add(t *testing.T, at time.Time, eventType string, attributes map[string]string) {
+	t.Helper()
+
+	event := &ce.Event{}
+	event.Set("id", ce.IDFromFingerprint(eventType+at.String()))
+	event.Set("type", eventType)
+	event.Set("specversion", "0.2")
+	event.Set("time", &at)
+	for k, v := range attributes {
+		event.Set(k, v)
+	}
+
+	r, err := history.NewEventRecord(event, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*f = append(*f, *r)
+}
+
+func runReplay(t *testing.T, source Source, config string) *Report {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(bytes.NewBufferString(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	report, err := New(logger, emperror.NewNopHandler()).Run(source, history.Query{}, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report
+}
+
+func TestReplayCooldown(t *testing.T) {
+	start := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	var events fixture
+	for _, e := range []struct {
+		at      time.Duration
+		cluster string
+	}{{0, "1"}, {time.Minute, "2"}, {5 * time.Minute, "1"}, {15 * time.Minute, "1"}, {20 * time.Minute, "1"}} {
+		events.add(t, start.Add(e.at), "prometheus.server.alert.NodeNotReady", map[string]string{"cluster_id": e.cluster})
+	}
+
+	report := runReplay(t, events, `
+flows:
+  drain:
+    name: "Drain"
+    allowedEvents:
+    - "prometheus.server.alert.NodeNotReady"
+    plugins:
+    - "drain"
+    groupBy:
+    - "cluster_id"
+    cooldown: 10m
+`)
+
+	if report.Events != 5 {
+		t.Fatalf("expected 5 replayed events, got %d", report.Events)
+	}
+
+	// the events within the cooldown of the cluster are skipped
+	expected := []struct {
+		at      time.Duration
+		cluster string
+	}{{0, "1"}, {time.Minute, "2"}, {15 * time.Minute, "1"}}
+	if len(report.Executions) != len(expected) {
+		t.Fatalf("expected %d executions, got %+v", len(expected), report.Executions)
+	}
+	for i, e := range expected {
+		r := report.Executions[i]
+		if !r.StartedAt.Equal(start.Add(e.at)) || r.ClusterID != e.cluster {
+			t.Errorf("expected execution %d for cluster %s at %s, got %s at %s", i, e.cluster, start.Add(e.at), r.ClusterID, r.StartedAt)
+		}
+		if len(r.Plugins) != 1 || r.Plugins[0].Name != "drain" || r.Plugins[0].Result != "dry-run" {
+			t.Errorf("expected a dry-run call of the drain plugin, got %+v", r.Plugins)
+		}
+	}
+}
+
+func TestReplayDelay(t *testing.T) {
+	start := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	var events fixture
+	events.add(t, start, "prometheus.server.alert.SpotTermination", map[string]string{"cluster_id": "1"})
+	events.add(t, start.Add(time.Hour), "prometheus.server.alert.SpotTermination", map[string]string{"cluster_id": "1"})
+
+	report := runReplay(t, events, `
+flows:
+  notify:
+    name: "Notify"
+    allowedEvents:
+    - "prometheus.server.alert.SpotTermination"
+    plugins:
+    - "notify"
+    delay: 5m
+`)
+
+	// the delay of the last event elapses after the last recorded event
+	if len(report.Executions) != 2 {
+		t.Fatalf("expected 2 executions, got %+v", report.Executions)
+	}
+	for i, at := range []time.Duration{5 * time.Minute, time.Hour + 5*time.Minute} {
+		if r := report.Executions[i]; !r.StartedAt.Equal(start.Add(at)) {
+			t.Errorf("expected execution %d to start at %s, got %s", i, start.Add(at), r.StartedAt)
+		}
+	}
+}
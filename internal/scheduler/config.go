@@ -0,0 +1,93 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// Config holds configuration values for the scheduler publishing events on schedule
+type Config struct {
+	// Enables publishing the scheduled events
+	Enabled bool
+
+	// Scheduled events by their ID
+	Schedules map[string]ScheduleConfig
+}
+
+// ScheduleConfig holds configuration values for an event published on schedule
+type ScheduleConfig struct {
+	// Cron expression (eg. "0 * * * *") or descriptor (eg. "@hourly")
+	Schedule string
+
+	// Time zone of the schedule, eg. Europe/Budapest, UTC if empty
+	Timezone string
+
+	// Type of the published events, hollowtrees.schedule.<id> if empty
+	Type string
+
+	// Static attributes added to the published events (eg. cluster_id)
+	Attributes map[string]string
+}
+
+// Validate checks that the configuration is valid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Schedules) == 0 {
+		return errors.New("at least one schedule must be defined")
+	}
+
+	for id, s := range c.Schedules {
+		_, err := s.parse()
+		if err != nil {
+			return emperror.WrapWith(err, "invalid schedule", "schedule", id)
+		}
+	}
+
+	return nil
+}
+
+func (c ScheduleConfig) parse() (cron.Schedule, error) {
+	if c.Schedule == "" {
+		return nil, errors.New("schedule must not be empty")
+	}
+
+	loc := time.UTC
+	if c.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, emperror.WrapWith(err, "invalid time zone", "timezone", c.Timezone)
+		}
+	}
+
+	schedule, err := cron.ParseStandard(c.Schedule)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "invalid cron expression", "schedule", c.Schedule)
+	}
+
+	if s, ok := schedule.(*cron.SpecSchedule); ok {
+		s.Location = loc
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,131 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/robfig/cron/v3"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+const (
+	EventTopic   = "cloud.events.incoming"
+	CETypePrefix = "hollowtrees.schedule."
+)
+
+// Scheduler publishes synthetic events on the configured schedules,
+// eg. to trigger periodic housekeeping flows
+type Scheduler struct {
+	cron *cron.Cron
+
+	logger       log.Logger
+	errorHandler emperror.Handler
+	eb           eventPublisher
+}
+
+// New returns an initialized Scheduler
+func New(config Config, logger log.Logger, errorHandler emperror.Handler, eb eventPublisher) (*Scheduler, error) {
+	s := &Scheduler{
+		cron: cron.New(),
+
+		logger:       logger,
+		errorHandler: errorHandler,
+		eb:           eb,
+	}
+
+	ids := make([]string, 0, len(config.Schedules))
+	for id := range config.Schedules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		c := config.Schedules[id]
+		schedule, err := c.parse()
+		if err != nil {
+			return nil, emperror.WrapWith(err, "invalid schedule", "schedule", id)
+		}
+
+		s.cron.Schedule(schedule, s.job(id, c))
+		logger.WithFields(log.Fields{"schedule": id, "spec": c.Schedule, "next": schedule.Next(time.Now()).String()}).Info("event scheduled")
+	}
+
+	return s, nil
+}
+
+// Run publishes the scheduled events until the process exits
+func (s *Scheduler) Run() {
+	s.logger.Info("starting scheduler")
+
+	s.cron.Run()
+}
+
+func (s *Scheduler) job(id string, config ScheduleConfig) cron.Job {
+	return cron.FuncJob(func() {
+		// runs of a schedule are at least a second apart
+		event := convertToCE(id, config, time.Now().Truncate(time.Second))
+
+		s.logger.WithFields(log.Fields{"schedule": id, "type": event.Type}).Info("publishing scheduled event")
+		err := s.eb.Publish(EventTopic, event)
+		if err != nil {
+			s.errorHandler.Handle(emperror.WrapWith(err, "could not publish scheduled event", "schedule", id))
+		}
+	})
+}
+
+// convertToCE converts a scheduled run to CloudEvent struct
+func convertToCE(id string, config ScheduleConfig, scheduled time.Time) *ce.Event {
+	e := &ce.Event{}
+
+	labels := map[string]string{
+		"schedule": id,
+	}
+	for k, v := range config.Attributes {
+		labels[k] = v
+	}
+
+	for k, v := range labels {
+		e.Set(k, v)
+	}
+	// every run of a schedule is a distinct event
+	fingerprint := ce.Fingerprint(map[string]string{
+		"schedule": id,
+		"time":     scheduled.UTC().Format(time.RFC3339),
+	})
+	e.Set("correlationid", uuid.NewV4().String())
+	e.Set("labels", labels)
+	e.Set(ce.FingerprintKey, fingerprint)
+
+	eventType := config.Type
+	if eventType == "" {
+		eventType = CETypePrefix + id
+	}
+
+	e.Set("id", ce.IDFromFingerprint(fingerprint))
+	e.Set("type", eventType)
+	e.Set("specversion", "0.2")
+	e.Set("source", url.URL{Path: "/hollowtrees/schedule/" + id})
+	e.Set("time", &scheduled)
+	e.Set("eventType", "schedule")
+
+	return e
+}
@@ -0,0 +1,70 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultKubernetesTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultKubernetesCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	DefaultKubernetesNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// Config holds configuration values for loading flow and plugin definitions besides the main config file
+type Config struct {
+	// Directory of YAML files defining a flow each
+	FlowsDir string
+
+	// Directory of YAML files defining a plugin each
+	PluginsDir string
+
+	// HollowtreesFlow and HollowtreesPlugin custom resources
+	Kubernetes KubernetesConfig
+}
+
+// KubernetesConfig holds configuration values for loading custom resources from the Kubernetes API
+type KubernetesConfig struct {
+	Enabled bool
+
+	// Namespace of the custom resources, the namespace of the pod if empty
+	Namespace string
+
+	// Time between two checks of the custom resources besides watching them for changes
+	Interval time.Duration
+
+	// API server address, the in-cluster address if empty
+	Host string
+
+	// Service account credentials
+	TokenFile string
+	CAFile    string
+}
+
+// Validate checks that the configuration is valid.
+func (c Config) Validate() error {
+	if !c.Kubernetes.Enabled {
+		return nil
+	}
+
+	if c.Kubernetes.Interval <= 0 {
+		return errors.New("kubernetes interval must be positive")
+	}
+
+	return nil
+}
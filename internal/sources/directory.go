@@ -0,0 +1,72 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goph/emperror"
+	"github.com/spf13/viper"
+)
+
+// definition is a flow or plugin definition read from a file or a custom resource
+type definition struct {
+	name   string
+	source string
+	values map[string]interface{}
+}
+
+// readDirectory reads the YAML files of the directory in the order of their names,
+// the definitions are named after the files without their extension
+func readDirectory(dir string) ([]definition, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not read directory", "dir", dir)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+
+	definitions := make([]definition, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+
+		// files are read the same way as the main config file, eg. keys are case insensitive
+		v := viper.New()
+		v.SetConfigFile(path)
+		err := v.ReadInConfig()
+		if err != nil {
+			return nil, emperror.WrapWith(err, "could not read file", "file", path)
+		}
+
+		definitions = append(definitions, definition{
+			name:   strings.TrimSuffix(name, filepath.Ext(name)),
+			source: path,
+			values: v.AllSettings(),
+		})
+	}
+
+	return definitions, nil
+}
@@ -0,0 +1,259 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Group and version of the HollowtreesFlow and HollowtreesPlugin custom resources
+	Group   = "hollowtrees.banzaicloud.io"
+	Version = "v1alpha1"
+
+	flowResource   = "hollowtreesflows"
+	pluginResource = "hollowtreesplugins"
+
+	requestTimeout = 10 * time.Second
+)
+
+// kubernetesClient lists and watches custom resources through the Kubernetes API with the credentials of a service account.
+//
+// The custom resources are listed and watched with plain HTTP requests instead of controller-runtime: its releases
+// depend on client-go versions that conflict with the client-go version pinned for the bank-vaults SDK
+// in go.mod, and only the spec of the resources is needed, the flows and plugins are reloaded as a whole anyway,
+// so there is no informer cache, a change is only a signal to list the resources again.
+type kubernetesClient struct {
+	host      string
+	namespace string
+	token     string
+	client    *http.Client
+
+	// watches are long requests, they are limited by their context only
+	watchClient *http.Client
+}
+
+type resourceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []resource `json:"items"`
+}
+
+// customResources are the definitions of the custom resources of a kind
+type customResources struct {
+	definitions []definition
+
+	// version changes if any resource is added, removed or changed
+	version string
+
+	// version of the list the changes are watched from
+	resourceVersion string
+}
+
+type watchEvent struct {
+	Type string `json:"type"`
+}
+
+type resource struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Spec map[string]interface{} `json:"spec"`
+}
+
+func newKubernetesClient(config KubernetesConfig) (*kubernetesClient, error) {
+	host := config.Host
+	if host == "" {
+		h, p := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if h == "" || p == "" {
+			return nil, errors.New("kubernetes host must be set outside of a cluster")
+		}
+		host = "https://" + net.JoinHostPort(h, p)
+	}
+
+	namespace := config.Namespace
+	if namespace == "" {
+		ns, err := ioutil.ReadFile(DefaultKubernetesNamespaceFile)
+		if err != nil {
+			return nil, emperror.Wrap(err, "kubernetes namespace must be set outside of a cluster")
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	c := &kubernetesClient{
+		host:      strings.TrimSuffix(host, "/"),
+		namespace: namespace,
+		client:    &http.Client{Timeout: requestTimeout},
+	}
+
+	if config.TokenFile != "" {
+		token, err := ioutil.ReadFile(config.TokenFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, emperror.WrapWith(err, "could not read kubernetes token", "file", config.TokenFile)
+		}
+		c.token = strings.TrimSpace(string(token))
+	}
+
+	if config.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.CAFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, emperror.WrapWith(err, "could not read kubernetes CA certificate", "file", config.CAFile)
+		}
+		if len(ca) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, emperror.With(errors.New("invalid kubernetes CA certificate"), "file", config.CAFile)
+			}
+			c.client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+		}
+	}
+
+	c.watchClient = &http.Client{Transport: c.client.Transport}
+
+	return c, nil
+}
+
+func (c *kubernetesClient) request(ctx context.Context, kind string, query url.Values) (*http.Request, error) {
+	u := fmt.Sprintf("%s/apis/%s/%s/namespaces/%s/%s", c.host, Group, Version, c.namespace, kind)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return req, nil
+}
+
+// list returns the custom resources of the namespace
+func (c *kubernetesClient) list(ctx context.Context, kind string) (*resourceList, error) {
+	req, err := c.request(ctx, kind, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not list custom resources", "resource", kind)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, emperror.With(errors.New("could not list custom resources"), "resource", kind, "status", resp.StatusCode)
+	}
+
+	var list resourceList
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	if err != nil {
+		return nil, emperror.WrapWith(err, "could not decode custom resources", "resource", kind)
+	}
+
+	return &list, nil
+}
+
+// definitions returns the custom resources as definitions named after the resources
+func (c *kubernetesClient) definitions(ctx context.Context, kind string) (*customResources, error) {
+	list, err := c.list(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := &customResources{
+		definitions:     make([]definition, 0, len(list.Items)),
+		resourceVersion: list.Metadata.ResourceVersion,
+	}
+	versions := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		resources.definitions = append(resources.definitions, definition{
+			name:   item.Metadata.Name,
+			source: fmt.Sprintf("%s/%s/%s", kind, item.Metadata.Namespace, item.Metadata.Name),
+			values: item.Spec,
+		})
+		versions = append(versions, item.Metadata.Name+"@"+item.Metadata.ResourceVersion)
+	}
+	resources.version = strings.Join(versions, ",")
+
+	return resources, nil
+}
+
+// watch waits for a change of the custom resources since the resource version of their list, it returns
+// once a resource is added, changed or removed, or the API server ends the watch after the timeout
+func (c *kubernetesClient) watch(ctx context.Context, kind string, resourceVersion string, timeout time.Duration) error {
+	query := url.Values{}
+	query.Set("watch", "true")
+	query.Set("resourceVersion", resourceVersion)
+	seconds := int(timeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	query.Set("timeoutSeconds", strconv.Itoa(seconds))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout+requestTimeout)
+	defer cancel()
+
+	req, err := c.request(ctx, kind, query)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.watchClient.Do(req)
+	if err != nil {
+		return emperror.WrapWith(err, "could not watch custom resources", "resource", kind)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return emperror.With(errors.New("could not watch custom resources"), "resource", kind, "status", resp.StatusCode)
+	}
+
+	// every event is a change, an expired resource version is reported as an error event,
+	// which is handled the same way as the resources are listed again after it
+	var event watchEvent
+	err = json.NewDecoder(resp.Body).Decode(&event)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return emperror.WrapWith(err, "could not decode watch event", "resource", kind)
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"context"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// Loader merges the flows and plugins defined in directories and Kubernetes custom resources
+// into the configuration, so teams can own their flows in separate files, eg. through GitOps
+type Loader struct {
+	config Config
+	kube   *kubernetesClient
+
+	// flows and plugins defined in the main config file and the directories
+	flows   map[string]interface{}
+	plugins []interface{}
+
+	// versions of the custom resources loaded last
+	flowsVersion   string
+	pluginsVersion string
+
+	// versions of the custom resource lists the changes are watched from
+	flowsResourceVersion   string
+	pluginsResourceVersion string
+
+	logger       log.Logger
+	errorHandler emperror.Handler
+}
+
+// New returns an initialized Loader
+func New(config Config, logger log.Logger, errorHandler emperror.Handler) (*Loader, error) {
+	l := &Loader{
+		config: config,
+
+		logger:       logger,
+		errorHandler: errorHandler,
+	}
+
+	if config.Kubernetes.Enabled {
+		kube, err := newKubernetesClient(config.Kubernetes)
+		if err != nil {
+			return nil, emperror.Wrap(err, "could not create kubernetes client")
+		}
+		l.kube = kube
+	}
+
+	return l, nil
+}
+
+// Load merges the flow and plugin definitions of the directories and custom resources into v,
+// a flow or plugin must not be defined more than once
+func (l *Loader) Load(v *viper.Viper) error {
+	flows := make(map[string]interface{})
+	for id, f := range v.GetStringMap("flows") {
+		flows[id] = f
+	}
+
+	plugins := []interface{}{}
+	if p, ok := v.Get("plugins").([]interface{}); ok {
+		plugins = append(plugins, p...)
+	}
+	names := make(map[string]bool)
+	for _, p := range plugins {
+		if name, ok := pluginName(p); ok {
+			names[name] = true
+		}
+	}
+
+	if l.config.FlowsDir != "" {
+		definitions, err := readDirectory(l.config.FlowsDir)
+		if err != nil {
+			return emperror.Wrap(err, "could not load flows")
+		}
+
+		err = addFlows(flows, definitions)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.config.PluginsDir != "" {
+		definitions, err := readDirectory(l.config.PluginsDir)
+		if err != nil {
+			return emperror.Wrap(err, "could not load plugins")
+		}
+
+		plugins, err = addPlugins(plugins, names, definitions)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.flows = flows
+	l.plugins = plugins
+
+	if l.kube != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		var err error
+		plugins, l.pluginsVersion, err = l.kubernetesPlugins(ctx)
+		if err != nil {
+			return err
+		}
+
+		flows, l.flowsVersion, err = l.kubernetesFlows(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	v.Set("flows", flows)
+	v.Set("plugins", plugins)
+
+	return nil
+}
+
+// Watch watches the HollowtreesFlow and HollowtreesPlugin custom resources until the process exits and calls
+// reload with every flow and plugin when they change. The resources are also checked every interval, so changes
+// rejected by reload are retried, and if watching fails the resources are checked every interval instead.
+func (l *Loader) Watch(reload func(v *viper.Viper) error) {
+	if l.kube == nil {
+		return
+	}
+
+	l.logger.WithField("interval", l.config.Kubernetes.Interval.String()).Info("watching custom resources")
+
+	for {
+		err := l.wait()
+		if err != nil {
+			l.errorHandler.Handle(err)
+			time.Sleep(l.config.Kubernetes.Interval)
+		}
+
+		err = l.check(reload)
+		if err != nil {
+			l.errorHandler.Handle(err)
+		}
+	}
+}
+
+// wait returns once the flow or plugin custom resources change or the interval passes
+func (l *Loader) wait() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watches := map[string]string{
+		flowResource:   l.flowsResourceVersion,
+		pluginResource: l.pluginsResourceVersion,
+	}
+
+	errs := make(chan error, len(watches))
+	for kind, resourceVersion := range watches {
+		go func(kind string, resourceVersion string) {
+			errs <- l.kube.watch(ctx, kind, resourceVersion, l.config.Kubernetes.Interval)
+		}(kind, resourceVersion)
+	}
+
+	// the first watch returning ends the other one
+	err := <-errs
+	cancel()
+	for i := 1; i < len(watches); i++ {
+		<-errs
+	}
+
+	return err
+}
+
+func (l *Loader) check(reload func(v *viper.Viper) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	plugins, pluginsVersion, err := l.kubernetesPlugins(ctx)
+	if err != nil {
+		return err
+	}
+
+	flows, flowsVersion, err := l.kubernetesFlows(ctx)
+	if err != nil {
+		return err
+	}
+
+	if flowsVersion == l.flowsVersion && pluginsVersion == l.pluginsVersion {
+		return nil
+	}
+
+	// the versions are updated only once the flows and plugins are reloaded, so rejected changes are retried on the next check
+	v := viper.New()
+	v.Set("flows", flows)
+	v.Set("plugins", plugins)
+	err = reload(v)
+	if err != nil {
+		return emperror.Wrap(err, "could not reload flows and plugins, keeping the previous ones")
+	}
+	l.flowsVersion, l.pluginsVersion = flowsVersion, pluginsVersion
+
+	l.logger.WithFields(log.Fields{"flows": len(flows), "plugins": len(plugins)}).Info("flows and plugins reloaded")
+
+	return nil
+}
+
+// kubernetesPlugins returns the plugins of the config file and the directory with the plugin custom resources,
+// and the version of the custom resources
+func (l *Loader) kubernetesPlugins(ctx context.Context) ([]interface{}, string, error) {
+	resources, err := l.kube.definitions(ctx, pluginResource)
+	if err != nil {
+		return nil, "", emperror.Wrap(err, "could not load plugins")
+	}
+	l.pluginsResourceVersion = resources.resourceVersion
+
+	names := make(map[string]bool)
+	for _, p := range l.plugins {
+		if name, ok := pluginName(p); ok {
+			names[name] = true
+		}
+	}
+
+	plugins := append([]interface{}{}, l.plugins...)
+	plugins, err = addPlugins(plugins, names, resources.definitions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return plugins, resources.version, nil
+}
+
+// kubernetesFlows returns the flows of the config file and the directory with the flow custom resources,
+// and the version of the custom resources
+func (l *Loader) kubernetesFlows(ctx context.Context) (map[string]interface{}, string, error) {
+	resources, err := l.kube.definitions(ctx, flowResource)
+	if err != nil {
+		return nil, "", emperror.Wrap(err, "could not load flows")
+	}
+	l.flowsResourceVersion = resources.resourceVersion
+
+	flows := make(map[string]interface{}, len(l.flows)+len(resources.definitions))
+	for id, f := range l.flows {
+		flows[id] = f
+	}
+
+	err = addFlows(flows, resources.definitions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return flows, resources.version, nil
+}
+
+func addFlows(flows map[string]interface{}, definitions []definition) error {
+	for _, d := range definitions {
+		if _, ok := flows[d.name]; ok {
+			return emperror.With(errors.New("flow defined more than once"), "flow", d.name, "source", d.source)
+		}
+		flows[d.name] = d.values
+	}
+
+	return nil
+}
+
+// addPlugins appends the plugin definitions to the plugins, the plugins are named after
+// their definition unless the name is set explicitly
+func addPlugins(plugins []interface{}, names map[string]bool, definitions []definition) ([]interface{}, error) {
+	for _, d := range definitions {
+		values := make(map[string]interface{}, len(d.values)+1)
+		for k, v := range d.values {
+			values[k] = v
+		}
+		if _, ok := pluginName(values); !ok {
+			values["name"] = d.name
+		}
+
+		name, _ := pluginName(values)
+		if names[name] {
+			return nil, emperror.With(errors.New("plugin defined more than once"), "plugin", name, "source", d.source)
+		}
+		names[name] = true
+
+		plugins = append(plugins, values)
+	}
+
+	return plugins, nil
+}
+
+func pluginName(plugin interface{}) (string, bool) {
+	var name interface{}
+	switch p := plugin.(type) {
+	case map[string]interface{}:
+		name = p["name"]
+	case map[interface{}]interface{}:
+		name = p["name"]
+	}
+
+	s, ok := name.(string)
+
+	return s, ok && s != ""
+}
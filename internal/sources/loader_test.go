@@ -0,0 +1,331 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+// fakeAPIServer serves the custom resources of the default namespace like the Kubernetes API
+type fakeAPIServer struct {
+	mu        sync.Mutex
+	resources map[string][]resource
+	version   int
+
+	// closed and replaced on every change
+	changed chan struct{}
+}
+
+func (s *fakeAPIServer) set(kind string, name string, version string, spec map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := resource{Spec: spec}
+	r.Metadata.Name = name
+	r.Metadata.Namespace = "default"
+	r.Metadata.ResourceVersion = version
+
+	for i, other := range s.resources[kind] {
+		if other.Metadata.Name == name {
+			s.resources[kind][i] = r
+			s.change()
+			return
+		}
+	}
+	s.resources[kind] = append(s.resources[kind], r)
+	s.change()
+}
+
+func (s *fakeAPIServer) change() {
+	s.version++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+
+	if r.URL.Query().Get("watch") == "true" {
+		changed := s.changed
+		s.mu.Unlock()
+
+		// the watch ends with an event on the next change or without one when the client gives up
+		select {
+		case <-changed:
+			_ = json.NewEncoder(w).Encode(watchEvent{Type: "MODIFIED"})
+		case <-r.Context().Done():
+		}
+		return
+	}
+	defer s.mu.Unlock()
+
+	list := resourceList{Items: []resource{}}
+	list.Metadata.ResourceVersion = strconv.Itoa(s.version)
+	for kind, items := range s.resources {
+		if r.URL.Path == "/apis/"+Group+"/"+Version+"/namespaces/default/"+kind {
+			list.Items = items
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// newTestLoader returns a loader of the custom resources of the fake API server, the server must be closed
+func newTestLoader(t *testing.T, config Config) (*Loader, *fakeAPIServer, *httptest.Server) {
+	t.Helper()
+
+	api := &fakeAPIServer{resources: make(map[string][]resource), changed: make(chan struct{})}
+	server := httptest.NewServer(api)
+
+	config.Kubernetes = KubernetesConfig{
+		Enabled:   true,
+		Namespace: "default",
+		Interval:  time.Minute,
+		Host:      server.URL,
+	}
+
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+	l, err := New(config, logger, emperror.NewNopHandler())
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return l, api, server
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "notify.yaml"), []byte("name: notify\nplugins: [slack]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, api, server := newTestLoader(t, Config{FlowsDir: dir})
+	defer server.Close()
+	api.set(flowResource, "drain", "1", map[string]interface{}{"name": "drain", "plugins": []interface{}{"drain"}})
+	api.set(pluginResource, "drain", "1", map[string]interface{}{"address": "drain:8888"})
+
+	v := viper.New()
+	v.Set("flows", map[string]interface{}{"scale": map[string]interface{}{"name": "scale"}})
+	err = l.Load(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flows := v.GetStringMap("flows")
+	for _, id := range []string{"scale", "notify", "drain"} {
+		if _, ok := flows[id]; !ok {
+			t.Fatalf("expected flow %s to be loaded, got %v", id, flows)
+		}
+	}
+
+	plugins, _ := v.Get("plugins").([]interface{})
+	if len(plugins) != 1 {
+		t.Fatalf("expected 1 plugin, got %d", len(plugins))
+	}
+	if name, _ := pluginName(plugins[0]); name != "drain" {
+		t.Fatalf("expected the plugin to be named after the custom resource, got %q", name)
+	}
+}
+
+func TestLoadDuplicateFlow(t *testing.T) {
+	l, api, server := newTestLoader(t, Config{})
+	defer server.Close()
+	api.set(flowResource, "drain", "1", map[string]interface{}{"name": "drain"})
+
+	v := viper.New()
+	v.Set("flows", map[string]interface{}{"drain": map[string]interface{}{"name": "drain"}})
+	err := l.Load(v)
+	if err == nil {
+		t.Fatal("expected an error for a flow defined more than once")
+	}
+}
+
+func TestCheckReloadsChangedFlows(t *testing.T) {
+	l, api, server := newTestLoader(t, Config{})
+	defer server.Close()
+	api.set(flowResource, "drain", "1", map[string]interface{}{"name": "drain"})
+
+	err := l.Load(viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reloads int
+	var reloadErr error
+	reload := func(v *viper.Viper) error {
+		reloads++
+		if _, ok := v.GetStringMap("flows")["drain"]; !ok {
+			t.Fatal("expected the reloaded flows to contain the custom resource")
+		}
+		return reloadErr
+	}
+
+	err = l.check(reload)
+	if err != nil || reloads != 0 {
+		t.Fatal("expected no reload without changes")
+	}
+
+	// rejected flows are retried on the next check
+	api.set(flowResource, "drain", "2", map[string]interface{}{"name": "drain", "cooldown": "invalid"})
+	reloadErr = errors.New("invalid flow config")
+	err = l.check(reload)
+	if err == nil || reloads != 1 {
+		t.Fatal("expected a failed reload")
+	}
+
+	err = l.check(reload)
+	if err == nil || reloads != 2 {
+		t.Fatal("expected the rejected flows to be reloaded again")
+	}
+
+	reloadErr = nil
+	err = l.check(reload)
+	if err != nil || reloads != 3 {
+		t.Fatal("expected the flows to be reloaded")
+	}
+
+	err = l.check(reload)
+	if err != nil || reloads != 3 {
+		t.Fatal("expected no reload once the changes are applied")
+	}
+}
+
+func TestCheckReloadsChangedPlugins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "notify.yaml"), []byte("type: http\nhttp:\n  url: http://notify\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, api, server := newTestLoader(t, Config{PluginsDir: dir})
+	defer server.Close()
+	api.set(flowResource, "drain", "1", map[string]interface{}{"name": "drain", "plugins": []interface{}{"drain"}})
+	api.set(pluginResource, "drain", "1", map[string]interface{}{"address": "drain:8888"})
+
+	err = l.Load(viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reloaded *viper.Viper
+	reload := func(v *viper.Viper) error {
+		reloaded = v
+		return nil
+	}
+
+	api.set(pluginResource, "drain", "2", map[string]interface{}{"address": "drain:9999"})
+	err = l.check(reload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded == nil {
+		t.Fatal("expected the changed plugins to be reloaded")
+	}
+
+	// every flow and plugin is reloaded, not only the changed ones
+	if _, ok := reloaded.GetStringMap("flows")["drain"]; !ok {
+		t.Fatal("expected the flows to be reloaded with the plugins")
+	}
+	plugins, _ := reloaded.Get("plugins").([]interface{})
+	addresses := make(map[string]interface{})
+	for _, p := range plugins {
+		name, _ := pluginName(p)
+		addresses[name] = p.(map[string]interface{})["address"]
+	}
+	if len(addresses) != 2 || addresses["drain"] != "drain:9999" {
+		t.Fatalf("expected the plugin of the directory and the changed custom resource, got %v", addresses)
+	}
+
+	reloaded = nil
+	err = l.check(reload)
+	if err != nil || reloaded != nil {
+		t.Fatal("expected no reload once the changes are applied")
+	}
+}
+
+func TestWaitForChanges(t *testing.T) {
+	l, api, server := newTestLoader(t, Config{})
+	defer server.Close()
+
+	err := l.Load(viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.wait()
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected waiting until the custom resources change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	api.set(pluginResource, "drain", "1", map[string]interface{}{"address": "drain:8888"})
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the change of a custom resource to end waiting")
+	}
+}
+
+func TestWatchFailure(t *testing.T) {
+	l, _, server := newTestLoader(t, Config{})
+
+	err := l.Load(viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the resources are checked every interval if the API server can not be watched
+	server.Close()
+	err = l.wait()
+	if err == nil {
+		t.Fatal("expected an error when the custom resources can not be watched")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotpoller
+
+import (
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+)
+
+// Config holds configuration values for the spot interruption notice poller
+type Config struct {
+	// Enables polling the instance metadata services
+	Enabled bool
+
+	// Time between two subsequent polls
+	Interval time.Duration
+
+	// Timeout of a single metadata request
+	Timeout time.Duration
+
+	// Static attributes added to every published event (eg. cluster_id, org_id)
+	Attributes map[string]string
+
+	// Provider specific settings
+	AWS   ProviderConfig
+	GCP   ProviderConfig
+	Azure ProviderConfig
+}
+
+// ProviderConfig holds configuration values for a cloud provider metadata endpoint
+type ProviderConfig struct {
+	Enabled  bool
+	Endpoint string
+}
+
+// Validate checks that the configuration is valid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Interval <= 0 {
+		return errors.New("poll interval must be positive")
+	}
+
+	if c.Timeout <= 0 {
+		return errors.New("request timeout must be positive")
+	}
+
+	enabled := 0
+	for name, p := range c.providers() {
+		if !p.Enabled {
+			continue
+		}
+		enabled++
+
+		if p.Endpoint == "" {
+			return emperror.With(errors.New("endpoint must not be empty"), "provider", name)
+		}
+	}
+
+	if enabled == 0 {
+		return errors.New("at least one provider must be enabled")
+	}
+
+	return nil
+}
+
+func (c Config) providers() map[string]ProviderConfig {
+	return map[string]ProviderConfig{
+		ProviderAWS:   c.AWS,
+		ProviderGCP:   c.GCP,
+		ProviderAzure: c.Azure,
+	}
+}
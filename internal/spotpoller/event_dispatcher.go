@@ -0,0 +1,43 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotpoller
+
+import (
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+)
+
+type baseEventPublisher interface {
+	Publish(topic string, args ...interface{}) error
+}
+
+type eventDispatcher struct {
+	eb baseEventPublisher
+}
+
+type eventPublisher interface {
+	Publish(topic string, event *ce.Event) error
+}
+
+// NewEventDispatcher returns a new event dispatcher
+func NewEventDispatcher(eb baseEventPublisher) *eventDispatcher {
+	return &eventDispatcher{
+		eb: eb,
+	}
+}
+
+// Publish sends the given event through the event dispatcher
+func (b *eventDispatcher) Publish(topic string, event *ce.Event) error {
+	return b.eb.Publish(topic, event)
+}
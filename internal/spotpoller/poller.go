@@ -0,0 +1,172 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotpoller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/goph/emperror"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+const (
+	EventTopic   = "cloud.events.incoming"
+	CETypePrefix = "spot.interruption."
+)
+
+// Poller periodically polls the configured metadata services and
+// publishes an event for every new interruption notice
+type Poller struct {
+	interval   time.Duration
+	timeout    time.Duration
+	attributes map[string]string
+	providers  []provider
+	client     *http.Client
+
+	// notices already published by provider
+	seen map[string]map[string]bool
+
+	logger       log.Logger
+	errorHandler emperror.Handler
+	eb           eventPublisher
+}
+
+// New returns an initialized Poller
+func New(config Config, logger log.Logger, errorHandler emperror.Handler, eb eventPublisher) *Poller {
+	p := &Poller{
+		interval:   config.Interval,
+		timeout:    config.Timeout,
+		attributes: config.Attributes,
+		client:     &http.Client{},
+		seen:       make(map[string]map[string]bool),
+
+		logger:       logger,
+		errorHandler: errorHandler,
+		eb:           eb,
+	}
+
+	for name, c := range config.providers() {
+		if c.Enabled {
+			p.providers = append(p.providers, newProvider(name, c.Endpoint))
+		}
+	}
+
+	return p
+}
+
+// Run polls the metadata services until the process exits
+func (p *Poller) Run() {
+	for _, provider := range p.providers {
+		p.logger.WithFields(log.Fields{"provider": provider.Name(), "endpoint": provider.Endpoint(), "interval": p.interval.String()}).Info("starting spot interruption poller")
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.Poll()
+		<-ticker.C
+	}
+}
+
+// Poll polls every configured metadata service once
+func (p *Poller) Poll() {
+	for _, provider := range p.providers {
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		notices, err := provider.Poll(ctx, p.client)
+		cancel()
+		if err != nil {
+			p.errorHandler.Handle(emperror.WrapWith(err, "could not poll metadata service", "provider", provider.Name()))
+			continue
+		}
+
+		p.publishNotices(provider, notices)
+	}
+}
+
+// publishNotices publishes notices not seen before through the event dispatcher
+func (p *Poller) publishNotices(provider provider, notices []Notice) {
+	seen := make(map[string]bool, len(notices))
+	for _, notice := range notices {
+		seen[notice.ID] = true
+		if p.seen[provider.Name()][notice.ID] {
+			continue
+		}
+
+		event, err := p.convertToCE(provider, notice)
+		if err != nil {
+			p.errorHandler.Handle(emperror.WrapWith(err, "could not convert notice", "provider", provider.Name()))
+			continue
+		}
+
+		p.logger.WithFields(log.Fields{"provider": provider.Name(), "action": notice.Action, "time": notice.Time.String()}).Info("spot interruption notice received")
+		err = p.eb.Publish(EventTopic, event)
+		if err != nil {
+			// forget the notice so it is published again on the next poll
+			delete(seen, notice.ID)
+			p.errorHandler.Handle(emperror.WrapWith(err, "could not publish notice", "provider", provider.Name()))
+		}
+	}
+
+	p.seen[provider.Name()] = seen
+}
+
+// convertToCE converts an interruption notice to CloudEvent struct
+func (p *Poller) convertToCE(provider provider, notice Notice) (*ce.Event, error) {
+	e := &ce.Event{}
+
+	labels := map[string]string{
+		"provider": provider.Name(),
+		"action":   notice.Action,
+	}
+	for k, v := range p.attributes {
+		labels[k] = v
+	}
+
+	for k, v := range labels {
+		e.Set(k, v)
+	}
+	fingerprint := ce.Fingerprint(map[string]string{
+		"provider": provider.Name(),
+		"endpoint": provider.Endpoint(),
+		"notice":   notice.ID,
+	})
+	e.Set("correlationid", uuid.NewV4().String())
+	e.Set("labels", labels)
+	e.Set(ce.FingerprintKey, fingerprint)
+
+	e.Set("id", ce.IDFromFingerprint(fingerprint))
+	e.Set("type", fmt.Sprintf("%s%s", CETypePrefix, provider.Name()))
+	e.Set("specversion", "0.2")
+	u, err := url.Parse(provider.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+	e.Set("source", *u)
+	t := notice.Time
+	e.Set("time", &t)
+	e.Set("contenttype", "application/json")
+	e.Set("data", string(notice.Raw))
+	e.Set("eventType", "spot")
+
+	return e, nil
+}
@@ -0,0 +1,132 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotpoller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+
+	"github.com/banzaicloud/hollowtrees/internal/ce"
+	"github.com/banzaicloud/hollowtrees/internal/platform/log"
+)
+
+type testPublisher struct {
+	err       error
+	published []*ce.Event
+}
+
+func (p *testPublisher) Publish(topic string, event *ce.Event) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event)
+
+	return nil
+}
+
+// scheduledEvents serves the Azure scheduled events document, it can be changed between polls
+type scheduledEvents struct {
+	mu       sync.Mutex
+	document string
+}
+
+func (s *scheduledEvents) set(document string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.document = document
+}
+
+func (s *scheduledEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _ = w.Write([]byte(s.document))
+}
+
+func newTestPoller(endpoint string, publisher eventPublisher) *Poller {
+	config := Config{
+		Enabled:    true,
+		Interval:   time.Second,
+		Timeout:    time.Second,
+		Attributes: map[string]string{"cluster_id": "1"},
+		Azure:      ProviderConfig{Enabled: true, Endpoint: endpoint},
+	}
+	logger := log.NewLogger(log.Config{Format: "logfmt", Level: "error"})
+
+	return New(config, logger, emperror.NewNopHandler(), publisher)
+}
+
+func TestPollPublishesNoticesOnce(t *testing.T) {
+	events := &scheduledEvents{document: `{"Events": [{"EventId": "preempt-1", "EventType": "Preempt"}]}`}
+	server := httptest.NewServer(events)
+	defer server.Close()
+
+	publisher := &testPublisher{}
+	p := newTestPoller(server.URL, publisher)
+
+	p.Poll()
+	p.Poll()
+	if len(publisher.published) != 1 {
+		t.Fatalf("expected the notice to be published once, got %d events", len(publisher.published))
+	}
+
+	event := publisher.published[0]
+	if event.Type != CETypePrefix+ProviderAzure {
+		t.Fatalf("unexpected event type: %s", event.Type)
+	}
+	if id, _ := event.GetString("cluster_id"); id != "1" {
+		t.Fatal("expected the static attributes to be set on the event")
+	}
+
+	events.set(`{"Events": [{"EventId": "preempt-1", "EventType": "Preempt"}, {"EventId": "preempt-2", "EventType": "Preempt"}]}`)
+	p.Poll()
+	if len(publisher.published) != 2 {
+		t.Fatalf("expected only the new notice to be published, got %d events", len(publisher.published))
+	}
+	if publisher.published[0].ID == publisher.published[1].ID {
+		t.Fatal("expected the notices to be published with different IDs")
+	}
+
+	// notices are published again once they disappeared
+	events.set(`{"Events": []}`)
+	p.Poll()
+	events.set(`{"Events": [{"EventId": "preempt-1", "EventType": "Preempt"}]}`)
+	p.Poll()
+	if len(publisher.published) != 3 {
+		t.Fatalf("expected the reappearing notice to be published again, got %d events", len(publisher.published))
+	}
+}
+
+func TestPollRetriesFailedPublish(t *testing.T) {
+	server := httptest.NewServer(&scheduledEvents{document: `{"Events": [{"EventId": "preempt-1", "EventType": "Preempt"}]}`})
+	defer server.Close()
+
+	publisher := &testPublisher{err: errors.New("event bus is closed")}
+	p := newTestPoller(server.URL, publisher)
+
+	p.Poll()
+	publisher.err = nil
+	p.Poll()
+	if len(publisher.published) != 1 {
+		t.Fatalf("expected the notice to be published on the next poll, got %d events", len(publisher.published))
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotpoller
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+)
+
+const (
+	ProviderAWS   = "aws"
+	ProviderGCP   = "gcp"
+	ProviderAzure = "azure"
+
+	DefaultAWSEndpoint   = "http://169.254.169.254/latest/meta-data/spot/instance-action"
+	DefaultGCPEndpoint   = "http://metadata.google.internal/computeMetadata/v1/instance/preempted"
+	DefaultAzureEndpoint = "http://169.254.169.254/metadata/scheduledevents?api-version=2019-01-01"
+)
+
+// Notice describes an interruption notice returned by a metadata service
+type Notice struct {
+	// ID identifies the notice, subsequent polls returning the same notice must return the same ID
+	ID     string
+	Action string
+	Time   time.Time
+	Raw    []byte
+}
+
+// provider polls the metadata service of a cloud provider
+type provider interface {
+	Name() string
+	Endpoint() string
+	Poll(ctx context.Context, client *http.Client) ([]Notice, error)
+}
+
+func newProvider(name string, endpoint string) provider {
+	switch name {
+	case ProviderAWS:
+		return &awsProvider{endpoint: endpoint}
+	case ProviderGCP:
+		return &gcpProvider{endpoint: endpoint}
+	case ProviderAzure:
+		return &azureProvider{endpoint: endpoint}
+	}
+
+	return nil
+}
+
+// awsProvider polls the EC2 spot instance-action endpoint, which returns 404 until an interruption is scheduled
+type awsProvider struct {
+	endpoint string
+}
+
+func (p *awsProvider) Name() string {
+	return ProviderAWS
+}
+
+func (p *awsProvider) Endpoint() string {
+	return p.endpoint
+}
+
+func (p *awsProvider) Poll(ctx context.Context, client *http.Client) ([]Notice, error) {
+	status, body, err := get(ctx, client, p.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if status != http.StatusOK {
+		return nil, emperror.With(errors.New("unexpected response status"), "status", status)
+	}
+
+	var action struct {
+		Action string    `json:"action"`
+		Time   time.Time `json:"time"`
+	}
+	err = json.Unmarshal(body, &action)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not parse instance action")
+	}
+
+	return []Notice{
+		{
+			ID:     action.Action + "/" + action.Time.UTC().Format(time.RFC3339),
+			Action: action.Action,
+			Time:   action.Time,
+			Raw:    body,
+		},
+	}, nil
+}
+
+// gcpProvider polls the GCE preempted endpoint, which returns TRUE once the instance is being preempted
+type gcpProvider struct {
+	endpoint string
+}
+
+func (p *gcpProvider) Name() string {
+	return ProviderGCP
+}
+
+func (p *gcpProvider) Endpoint() string {
+	return p.endpoint
+}
+
+func (p *gcpProvider) Poll(ctx context.Context, client *http.Client) ([]Notice, error) {
+	status, body, err := get(ctx, client, p.endpoint, map[string]string{"Metadata-Flavor": "Google"})
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, emperror.With(errors.New("unexpected response status"), "status", status)
+	}
+
+	if strings.TrimSpace(string(body)) != "TRUE" {
+		return nil, nil
+	}
+
+	return []Notice{
+		{
+			ID:     "preempted",
+			Action: "preempt",
+			Time:   time.Now(),
+			Raw:    body,
+		},
+	}, nil
+}
+
+// azureProvider polls the Azure Scheduled Events endpoint for Preempt events
+type azureProvider struct {
+	endpoint string
+}
+
+func (p *azureProvider) Name() string {
+	return ProviderAzure
+}
+
+func (p *azureProvider) Endpoint() string {
+	return p.endpoint
+}
+
+func (p *azureProvider) Poll(ctx context.Context, client *http.Client) ([]Notice, error) {
+	status, body, err := get(ctx, client, p.endpoint, map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, emperror.With(errors.New("unexpected response status"), "status", status)
+	}
+
+	var document struct {
+		Events []struct {
+			EventID   string `json:"EventId"`
+			EventType string `json:"EventType"`
+			NotBefore string `json:"NotBefore"`
+		} `json:"Events"`
+	}
+	err = json.Unmarshal(body, &document)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not parse scheduled events")
+	}
+
+	var notices []Notice
+	for _, e := range document.Events {
+		if e.EventType != "Preempt" {
+			continue
+		}
+
+		t, err := http.ParseTime(e.NotBefore)
+		if err != nil {
+			t = time.Now()
+		}
+
+		notices = append(notices, Notice{
+			ID:     e.EventID,
+			Action: "preempt",
+			Time:   t,
+			Raw:    body,
+		})
+	}
+
+	return notices, nil
+}
+
+func get(ctx context.Context, client *http.Client, url string, headers map[string]string) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, emperror.Wrap(err, "could not create request")
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, emperror.Wrap(err, "could not reach metadata service")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, emperror.Wrap(err, "could not read response body")
+	}
+
+	return resp.StatusCode, body, nil
+}
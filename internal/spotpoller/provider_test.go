@@ -0,0 +1,116 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotpoller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// metadataServer responds to the metadata requests with the status and body, the handler checks the request
+func metadataServer(status int, body string, check func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func poll(t *testing.T, p provider) []Notice {
+	t.Helper()
+
+	notices, err := p.Poll(context.Background(), &http.Client{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return notices
+}
+
+func TestAWSProvider(t *testing.T) {
+	server := metadataServer(http.StatusNotFound, "", nil)
+	defer server.Close()
+
+	if notices := poll(t, newProvider(ProviderAWS, server.URL)); len(notices) != 0 {
+		t.Fatalf("expected no notices without an instance action, got %v", notices)
+	}
+
+	server = metadataServer(http.StatusOK, `{"action": "terminate", "time": "2019-08-01T12:02:00Z"}`, nil)
+	defer server.Close()
+
+	notices := poll(t, newProvider(ProviderAWS, server.URL))
+	if len(notices) != 1 {
+		t.Fatalf("expected 1 notice, got %d", len(notices))
+	}
+	if n := notices[0]; n.ID != "terminate/2019-08-01T12:02:00Z" || n.Action != "terminate" || !n.Time.Equal(time.Date(2019, 8, 1, 12, 2, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected notice: %+v", n)
+	}
+
+	server = metadataServer(http.StatusInternalServerError, "", nil)
+	defer server.Close()
+
+	_, err := newProvider(ProviderAWS, server.URL).Poll(context.Background(), &http.Client{})
+	if err == nil {
+		t.Fatal("expected an error for an unexpected status")
+	}
+}
+
+func TestGCPProvider(t *testing.T) {
+	for body, expected := range map[string]int{"FALSE": 0, "TRUE\n": 1} {
+		server := metadataServer(http.StatusOK, body, func(r *http.Request) {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				t.Error("expected the Metadata-Flavor header to be set")
+			}
+		})
+
+		notices := poll(t, newProvider(ProviderGCP, server.URL))
+		server.Close()
+
+		if len(notices) != expected {
+			t.Fatalf("expected %d notices for %q, got %d", expected, body, len(notices))
+		}
+		if expected > 0 && (notices[0].ID != "preempted" || notices[0].Action != "preempt") {
+			t.Fatalf("unexpected notice: %+v", notices[0])
+		}
+	}
+}
+
+func TestAzureProvider(t *testing.T) {
+	server := metadataServer(http.StatusOK, `{
+		"DocumentIncarnation": 2,
+		"Events": [
+			{"EventId": "reboot-1", "EventType": "Reboot", "NotBefore": "Thu, 01 Aug 2019 12:05:00 GMT"},
+			{"EventId": "preempt-1", "EventType": "Preempt", "NotBefore": "Thu, 01 Aug 2019 12:00:30 GMT"}
+		]
+	}`, func(r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			t.Error("expected the Metadata header to be set")
+		}
+	})
+	defer server.Close()
+
+	notices := poll(t, newProvider(ProviderAzure, server.URL))
+	if len(notices) != 1 {
+		t.Fatalf("expected only the Preempt event, got %d notices", len(notices))
+	}
+	if n := notices[0]; n.ID != "preempt-1" || n.Action != "preempt" || !n.Time.Equal(time.Date(2019, 8, 1, 12, 0, 30, 0, time.UTC)) {
+		t.Fatalf("unexpected notice: %+v", n)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcplugin
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin/proto"
+)
+
+// ProtocolVersion is the version of the plugin protocol implemented by this package
+const ProtocolVersion = 1
+
+// Parameter types of ParamSpec
+const (
+	ParamTypeString   = "string"
+	ParamTypeInt      = "int"
+	ParamTypeBool     = "bool"
+	ParamTypeDuration = "duration"
+)
+
+// Describer can be implemented by an EventHandler to tell Hollowtrees its capabilities,
+// flows wiring the plugin to unsupported events or parameters are rejected
+type Describer interface {
+	Describe() *Description
+}
+
+// Description describes the capabilities of a plugin
+type Description struct {
+	Name    string
+	Version string
+	// Supported event type patterns, eg. `prometheus.server.alert.*`, empty means any
+	EventTypes []string
+	// Every parameter the plugin accepts
+	Params []ParamSpec
+}
+
+// ParamSpec describes a flow step parameter accepted by a plugin
+type ParamSpec struct {
+	Name string
+	// string, int, bool or duration
+	Type        string
+	Required    bool
+	Description string
+}
+
+// Describe returns the description of the event handler if it implements Describer
+func (h *handler) Describe(ctx context.Context, req *proto.DescribeRequest) (*proto.Description, error) {
+	d, ok := h.EventHandler.(Describer)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "plugin does not describe its capabilities")
+	}
+
+	desc := d.Describe()
+	resp := &proto.Description{
+		Name:            desc.Name,
+		Version:         desc.Version,
+		EventTypes:      desc.EventTypes,
+		ProtocolVersion: ProtocolVersion,
+	}
+	for _, p := range desc.Params {
+		resp.Params = append(resp.Params, &proto.ParamSpec{
+			Name:        p.Name,
+			Type:        p.Type,
+			Required:    p.Required,
+			Description: p.Description,
+		})
+	}
+
+	return resp, nil
+}
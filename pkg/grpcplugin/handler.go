@@ -42,22 +42,29 @@ func NewHandler(eh EventHandler) *handler {
 
 // Handle converts and passes the incoming event to the defined event handler
 func (h *handler) Handle(ctx context.Context, ce *proto.CloudEvent) (*proto.Result, error) {
-	var e = CloudEvent{
+	result, err := h.EventHandler.Handle(newCloudEvent(ce))
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not handle event")
+	}
+
+	return &proto.Result{
+		Status:      result.Status,
+		OperationId: result.OperationId,
+	}, nil
+}
+
+func newCloudEvent(ce *proto.CloudEvent) *CloudEvent {
+	return &CloudEvent{
 		Specversion: ce.Specversion,
 		Type:        ce.Type,
 		Source:      ce.Source,
 		Id:          ce.Id,
 		Time:        ce.Time,
+		Schemaurl:   ce.Schemaurl,
 		Contenttype: ce.Contenttype,
 		Data:        ce.Data,
+		Extensions:  ce.Extensions,
+		Params:      ce.Params,
+		CallbackUrl: ce.CallbackUrl,
 	}
-
-	result, err := h.EventHandler.Handle(&e)
-	if err != nil {
-		return nil, emperror.Wrap(err, "could not handle event")
-	}
-
-	return &proto.Result{
-		Status: result.Status,
-	}, nil
 }
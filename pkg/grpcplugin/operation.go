@@ -0,0 +1,187 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcplugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin/proto"
+)
+
+// States of a long-running operation
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+const callbackAttempts = 3
+
+// ErrOperationNotFound is returned for unknown or expired operations
+var ErrOperationNotFound = errors.New("operation not found") // nolint: gochecknoglobals
+
+// StatusGetter can be implemented by an EventHandler returning operation IDs from Handle,
+// Hollowtrees polls the status of the operation until it finishes
+type StatusGetter interface {
+	GetStatus(operationID string) (*OperationStatus, error)
+}
+
+// OperationStatus describes the state of a long-running operation
+type OperationStatus struct {
+	ID     string `json:"id"`
+	State  string `json:"state"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Operations runs long-running operations in the background and keeps their state for
+// the retention period after they finish, it implements StatusGetter and reports finished
+// operations to the callback URL of the event if Hollowtrees sent one
+type Operations struct {
+	retention time.Duration
+	client    *http.Client
+
+	mu         sync.Mutex
+	operations map[string]*OperationStatus
+}
+
+// NewOperations returns an initialized Operations
+func NewOperations(retention time.Duration) *Operations {
+	return &Operations{
+		retention:  retention,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		operations: make(map[string]*OperationStatus),
+	}
+}
+
+// Start runs fn in the background and returns the result Handle should return for the event,
+// the operation fails if fn returns an error, otherwise its status is the status of the result
+func (o *Operations) Start(event *CloudEvent, fn func() (*Result, error)) *Result {
+	id := uuid.NewV4().String()
+
+	o.mu.Lock()
+	o.operations[id] = &OperationStatus{ID: id, State: OperationRunning}
+	o.mu.Unlock()
+
+	go o.run(id, event.CallbackUrl, fn)
+
+	return &Result{Status: OperationRunning, OperationId: id}
+}
+
+func (o *Operations) run(id string, callbackURL string, fn func() (*Result, error)) {
+	s := OperationStatus{ID: id, State: OperationSucceeded}
+
+	result, err := fn()
+	if err != nil {
+		s.State = OperationFailed
+		s.Error = err.Error()
+	} else if result != nil {
+		s.Status = result.Status
+	}
+
+	o.mu.Lock()
+	o.operations[id] = &s
+	o.mu.Unlock()
+
+	time.AfterFunc(o.retention, func() {
+		o.mu.Lock()
+		delete(o.operations, id)
+		o.mu.Unlock()
+	})
+
+	if callbackURL != "" {
+		// failed callbacks are not fatal, Hollowtrees falls back to polling the status
+		_ = o.callback(callbackURL+"/"+url.PathEscape(id), s)
+	}
+}
+
+// callback reports the finished operation to Hollowtrees
+func (o *Operations) callback(callbackURL string, s OperationStatus) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = o.post(callbackURL, body)
+		if err == nil || attempt == callbackAttempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+func (o *Operations) post(callbackURL string, body []byte) error {
+	resp, err := o.client.Post(callbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected callback response status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// GetStatus returns the state of an operation
+func (o *Operations) GetStatus(operationID string) (*OperationStatus, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s, ok := o.operations[operationID]
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	c := *s
+
+	return &c, nil
+}
+
+// GetStatus returns the state of an operation if the event handler implements StatusGetter
+func (h *handler) GetStatus(ctx context.Context, req *proto.OperationRequest) (*proto.OperationStatus, error) {
+	g, ok := h.EventHandler.(StatusGetter)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "plugin does not run long-running operations")
+	}
+
+	s, err := g.GetStatus(req.OperationId)
+	if err == ErrOperationNotFound {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &proto.OperationStatus{
+		OperationId: req.OperationId,
+		State:       s.State,
+		Status:      s.Status,
+		Error:       s.Error,
+	}, nil
+}
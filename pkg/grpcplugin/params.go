@@ -0,0 +1,82 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcplugin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/goph/emperror"
+)
+
+// Param returns the value of a flow step parameter and whether it was set
+func (e *CloudEvent) Param(name string) (string, bool) {
+	v, ok := e.Params[name]
+	return v, ok
+}
+
+// ParamString returns the value of a flow step parameter or the default value if it is not set
+func (e *CloudEvent) ParamString(name string, def string) string {
+	if v, ok := e.Param(name); ok {
+		return v
+	}
+
+	return def
+}
+
+// ParamInt returns a flow step parameter as an integer or the default value if it is not set
+func (e *CloudEvent) ParamInt(name string, def int) (int, error) {
+	v, ok := e.Param(name)
+	if !ok {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return def, emperror.WrapWith(err, "invalid integer parameter", "param", name)
+	}
+
+	return i, nil
+}
+
+// ParamBool returns a flow step parameter as a boolean or the default value if it is not set
+func (e *CloudEvent) ParamBool(name string, def bool) (bool, error) {
+	v, ok := e.Param(name)
+	if !ok {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, emperror.WrapWith(err, "invalid boolean parameter", "param", name)
+	}
+
+	return b, nil
+}
+
+// ParamDuration returns a flow step parameter as a duration (eg. `5m`) or the default value if it is not set
+func (e *CloudEvent) ParamDuration(name string, def time.Duration) (time.Duration, error) {
+	v, ok := e.Param(name)
+	if !ok {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def, emperror.WrapWith(err, "invalid duration parameter", "param", name)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,64 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcplugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParams(t *testing.T) {
+	e := &CloudEvent{}
+	e.Params = map[string]string{
+		"node":    "i-1",
+		"count":   "3",
+		"dryRun":  "true",
+		"grace":   "5m",
+		"invalid": "x",
+	}
+
+	if v, ok := e.Param("node"); !ok || v != "i-1" {
+		t.Fatalf("unexpected node parameter: %q, %v", v, ok)
+	}
+	if _, ok := e.Param("missing"); ok {
+		t.Fatal("expected a missing parameter not to be set")
+	}
+	if v := e.ParamString("missing", "default"); v != "default" {
+		t.Fatalf("expected the default value, got %q", v)
+	}
+
+	if v, err := e.ParamInt("count", 1); err != nil || v != 3 {
+		t.Fatalf("unexpected count parameter: %d, %v", v, err)
+	}
+	if v, err := e.ParamBool("dryRun", false); err != nil || !v {
+		t.Fatalf("unexpected dryRun parameter: %v, %v", v, err)
+	}
+	if v, err := e.ParamDuration("grace", time.Second); err != nil || v != 5*time.Minute {
+		t.Fatalf("unexpected grace parameter: %s, %v", v, err)
+	}
+	if v, err := e.ParamInt("missing", 7); err != nil || v != 7 {
+		t.Fatalf("expected the default value, got %d, %v", v, err)
+	}
+
+	if v, err := e.ParamInt("invalid", 1); err == nil || v != 1 {
+		t.Fatalf("expected an invalid integer to return the default with an error, got %d, %v", v, err)
+	}
+	if _, err := e.ParamBool("invalid", false); err == nil {
+		t.Fatal("expected an invalid boolean to fail")
+	}
+	if _, err := e.ParamDuration("invalid", 0); err == nil {
+		t.Fatal("expected an invalid duration to fail")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright © 2019 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcplugin
+
+import (
+	"github.com/goph/emperror"
+
+	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin/proto"
+)
+
+// ProgressReporter sends progress updates to Hollowtrees while an event is handled
+type ProgressReporter interface {
+	// Report sends the completion percentage (0-100), a message and partial outputs,
+	// the outputs of every update are merged into the outputs of the result
+	Report(percentage int, message string, output map[string]string) error
+}
+
+// StreamingEventHandler can be implemented by an EventHandler to report progress while handling events,
+// Hollowtrees calls HandleWithProgress instead of Handle then
+type StreamingEventHandler interface {
+	HandleWithProgress(event *CloudEvent, progress ProgressReporter) (*Result, error)
+}
+
+type streamReporter struct {
+	stream proto.EventHandler_HandleStreamServer
+}
+
+// Report sends a progress update on the stream
+func (r streamReporter) Report(percentage int, message string, output map[string]string) error {
+	if percentage < 0 {
+		percentage = 0
+	}
+	if percentage > 100 {
+		percentage = 100
+	}
+
+	return r.stream.Send(&proto.HandleUpdate{
+		Progress: &proto.Progress{
+			Percentage: uint32(percentage),
+			Message:    message,
+			Output:     output,
+		},
+	})
+}
+
+// HandleStream passes the incoming event to the defined event handler and streams its progress
+// updates followed by the result, handlers without progress reporting send the result only
+func (h *handler) HandleStream(ce *proto.CloudEvent, stream proto.EventHandler_HandleStreamServer) error {
+	var result *Result
+	var err error
+	if sh, ok := h.EventHandler.(StreamingEventHandler); ok {
+		result, err = sh.HandleWithProgress(newCloudEvent(ce), streamReporter{stream: stream})
+	} else {
+		result, err = h.EventHandler.Handle(newCloudEvent(ce))
+	}
+	if err != nil {
+		return emperror.Wrap(err, "could not handle event")
+	}
+
+	return stream.Send(&proto.HandleUpdate{
+		Result: &proto.Result{
+			Status:      result.Status,
+			OperationId: result.OperationId,
+		},
+	})
+}
@@ -1,30 +1,18 @@
-// Code generated by protoc-gen-go.
+// Code generated by protoc-gen-go. DO NOT EDIT.
 // source: event.proto
-// DO NOT EDIT!
 
-/*
-Package proto is a generated protocol buffer package.
-
-It is generated from these files:
-	event.proto
-
-It has these top-level messages:
-	CloudEvent
-	Result
-*/
 package proto
 
-import proto1 "github.com/golang/protobuf/proto"
-import fmt "fmt"
-import math "math"
-
 import (
-	context "golang.org/x/net/context"
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
 	grpc "google.golang.org/grpc"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
-var _ = proto1.Marshal
+var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
@@ -32,24 +20,49 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto1.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type CloudEvent struct {
-	Specversion string            `protobuf:"bytes,1,opt,name=specversion" json:"specversion,omitempty"`
-	Type        string            `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
-	Source      string            `protobuf:"bytes,3,opt,name=source" json:"source,omitempty"`
-	Id          string            `protobuf:"bytes,4,opt,name=id" json:"id,omitempty"`
-	Time        string            `protobuf:"bytes,5,opt,name=time" json:"time,omitempty"`
-	Schemaurl   string            `protobuf:"bytes,6,opt,name=schemaurl" json:"schemaurl,omitempty"`
-	Contenttype string            `protobuf:"bytes,7,opt,name=contenttype" json:"contenttype,omitempty"`
-	Data        []byte            `protobuf:"bytes,8,opt,name=data,proto3" json:"data,omitempty"`
-	Extensions  map[string]string `protobuf:"bytes,9,rep,name=extensions" json:"extensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-}
-
-func (m *CloudEvent) Reset()                    { *m = CloudEvent{} }
-func (m *CloudEvent) String() string            { return proto1.CompactTextString(m) }
-func (*CloudEvent) ProtoMessage()               {}
-func (*CloudEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+	Specversion          string            `protobuf:"bytes,1,opt,name=specversion,proto3" json:"specversion,omitempty"`
+	Type                 string            `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Source               string            `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Id                   string            `protobuf:"bytes,4,opt,name=id,proto3" json:"id,omitempty"`
+	Time                 string            `protobuf:"bytes,5,opt,name=time,proto3" json:"time,omitempty"`
+	Schemaurl            string            `protobuf:"bytes,6,opt,name=schemaurl,proto3" json:"schemaurl,omitempty"`
+	Contenttype          string            `protobuf:"bytes,7,opt,name=contenttype,proto3" json:"contenttype,omitempty"`
+	Data                 []byte            `protobuf:"bytes,8,opt,name=data,proto3" json:"data,omitempty"`
+	Extensions           map[string]string `protobuf:"bytes,9,rep,name=extensions,proto3" json:"extensions,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Params               map[string]string `protobuf:"bytes,10,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	CallbackUrl          string            `protobuf:"bytes,11,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *CloudEvent) Reset()         { *m = CloudEvent{} }
+func (m *CloudEvent) String() string { return proto.CompactTextString(m) }
+func (*CloudEvent) ProtoMessage()    {}
+func (*CloudEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{0}
+}
+
+func (m *CloudEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CloudEvent.Unmarshal(m, b)
+}
+func (m *CloudEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CloudEvent.Marshal(b, m, deterministic)
+}
+func (m *CloudEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CloudEvent.Merge(m, src)
+}
+func (m *CloudEvent) XXX_Size() int {
+	return xxx_messageInfo_CloudEvent.Size(m)
+}
+func (m *CloudEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_CloudEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CloudEvent proto.InternalMessageInfo
 
 func (m *CloudEvent) GetSpecversion() string {
 	if m != nil {
@@ -114,14 +127,52 @@ func (m *CloudEvent) GetExtensions() map[string]string {
 	return nil
 }
 
+func (m *CloudEvent) GetParams() map[string]string {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+func (m *CloudEvent) GetCallbackUrl() string {
+	if m != nil {
+		return m.CallbackUrl
+	}
+	return ""
+}
+
 type Result struct {
-	Status string `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	OperationId          string   `protobuf:"bytes,2,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Result) Reset()         { *m = Result{} }
+func (m *Result) String() string { return proto.CompactTextString(m) }
+func (*Result) ProtoMessage()    {}
+func (*Result) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{1}
+}
+
+func (m *Result) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Result.Unmarshal(m, b)
+}
+func (m *Result) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Result.Marshal(b, m, deterministic)
+}
+func (m *Result) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Result.Merge(m, src)
+}
+func (m *Result) XXX_Size() int {
+	return xxx_messageInfo_Result.Size(m)
+}
+func (m *Result) XXX_DiscardUnknown() {
+	xxx_messageInfo_Result.DiscardUnknown(m)
 }
 
-func (m *Result) Reset()                    { *m = Result{} }
-func (m *Result) String() string            { return proto1.CompactTextString(m) }
-func (*Result) ProtoMessage()               {}
-func (*Result) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+var xxx_messageInfo_Result proto.InternalMessageInfo
 
 func (m *Result) GetStatus() string {
 	if m != nil {
@@ -130,9 +181,455 @@ func (m *Result) GetStatus() string {
 	return ""
 }
 
+func (m *Result) GetOperationId() string {
+	if m != nil {
+		return m.OperationId
+	}
+	return ""
+}
+
+type Progress struct {
+	Percentage           uint32            `protobuf:"varint,1,opt,name=percentage,proto3" json:"percentage,omitempty"`
+	Message              string            `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Output               map[string]string `protobuf:"bytes,3,rep,name=output,proto3" json:"output,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *Progress) Reset()         { *m = Progress{} }
+func (m *Progress) String() string { return proto.CompactTextString(m) }
+func (*Progress) ProtoMessage()    {}
+func (*Progress) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{2}
+}
+
+func (m *Progress) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Progress.Unmarshal(m, b)
+}
+func (m *Progress) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Progress.Marshal(b, m, deterministic)
+}
+func (m *Progress) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Progress.Merge(m, src)
+}
+func (m *Progress) XXX_Size() int {
+	return xxx_messageInfo_Progress.Size(m)
+}
+func (m *Progress) XXX_DiscardUnknown() {
+	xxx_messageInfo_Progress.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Progress proto.InternalMessageInfo
+
+func (m *Progress) GetPercentage() uint32 {
+	if m != nil {
+		return m.Percentage
+	}
+	return 0
+}
+
+func (m *Progress) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Progress) GetOutput() map[string]string {
+	if m != nil {
+		return m.Output
+	}
+	return nil
+}
+
+type HandleUpdate struct {
+	Progress             *Progress `protobuf:"bytes,1,opt,name=progress,proto3" json:"progress,omitempty"`
+	Result               *Result   `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *HandleUpdate) Reset()         { *m = HandleUpdate{} }
+func (m *HandleUpdate) String() string { return proto.CompactTextString(m) }
+func (*HandleUpdate) ProtoMessage()    {}
+func (*HandleUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{3}
+}
+
+func (m *HandleUpdate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HandleUpdate.Unmarshal(m, b)
+}
+func (m *HandleUpdate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HandleUpdate.Marshal(b, m, deterministic)
+}
+func (m *HandleUpdate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HandleUpdate.Merge(m, src)
+}
+func (m *HandleUpdate) XXX_Size() int {
+	return xxx_messageInfo_HandleUpdate.Size(m)
+}
+func (m *HandleUpdate) XXX_DiscardUnknown() {
+	xxx_messageInfo_HandleUpdate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HandleUpdate proto.InternalMessageInfo
+
+func (m *HandleUpdate) GetProgress() *Progress {
+	if m != nil {
+		return m.Progress
+	}
+	return nil
+}
+
+func (m *HandleUpdate) GetResult() *Result {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+type DescribeRequest struct {
+	ProtocolVersion      uint32   `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DescribeRequest) Reset()         { *m = DescribeRequest{} }
+func (m *DescribeRequest) String() string { return proto.CompactTextString(m) }
+func (*DescribeRequest) ProtoMessage()    {}
+func (*DescribeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{4}
+}
+
+func (m *DescribeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DescribeRequest.Unmarshal(m, b)
+}
+func (m *DescribeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DescribeRequest.Marshal(b, m, deterministic)
+}
+func (m *DescribeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DescribeRequest.Merge(m, src)
+}
+func (m *DescribeRequest) XXX_Size() int {
+	return xxx_messageInfo_DescribeRequest.Size(m)
+}
+func (m *DescribeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DescribeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DescribeRequest proto.InternalMessageInfo
+
+func (m *DescribeRequest) GetProtocolVersion() uint32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+type Description struct {
+	Name                 string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version              string       `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	EventTypes           []string     `protobuf:"bytes,3,rep,name=event_types,json=eventTypes,proto3" json:"event_types,omitempty"`
+	Params               []*ParamSpec `protobuf:"bytes,4,rep,name=params,proto3" json:"params,omitempty"`
+	ProtocolVersion      uint32       `protobuf:"varint,5,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *Description) Reset()         { *m = Description{} }
+func (m *Description) String() string { return proto.CompactTextString(m) }
+func (*Description) ProtoMessage()    {}
+func (*Description) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{5}
+}
+
+func (m *Description) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Description.Unmarshal(m, b)
+}
+func (m *Description) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Description.Marshal(b, m, deterministic)
+}
+func (m *Description) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Description.Merge(m, src)
+}
+func (m *Description) XXX_Size() int {
+	return xxx_messageInfo_Description.Size(m)
+}
+func (m *Description) XXX_DiscardUnknown() {
+	xxx_messageInfo_Description.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Description proto.InternalMessageInfo
+
+func (m *Description) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Description) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *Description) GetEventTypes() []string {
+	if m != nil {
+		return m.EventTypes
+	}
+	return nil
+}
+
+func (m *Description) GetParams() []*ParamSpec {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+func (m *Description) GetProtocolVersion() uint32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+type ParamSpec struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type                 string   `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Required             bool     `protobuf:"varint,3,opt,name=required,proto3" json:"required,omitempty"`
+	Description          string   `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ParamSpec) Reset()         { *m = ParamSpec{} }
+func (m *ParamSpec) String() string { return proto.CompactTextString(m) }
+func (*ParamSpec) ProtoMessage()    {}
+func (*ParamSpec) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{6}
+}
+
+func (m *ParamSpec) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ParamSpec.Unmarshal(m, b)
+}
+func (m *ParamSpec) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ParamSpec.Marshal(b, m, deterministic)
+}
+func (m *ParamSpec) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ParamSpec.Merge(m, src)
+}
+func (m *ParamSpec) XXX_Size() int {
+	return xxx_messageInfo_ParamSpec.Size(m)
+}
+func (m *ParamSpec) XXX_DiscardUnknown() {
+	xxx_messageInfo_ParamSpec.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ParamSpec proto.InternalMessageInfo
+
+func (m *ParamSpec) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ParamSpec) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *ParamSpec) GetRequired() bool {
+	if m != nil {
+		return m.Required
+	}
+	return false
+}
+
+func (m *ParamSpec) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+type OperationRequest struct {
+	OperationId          string   `protobuf:"bytes,1,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OperationRequest) Reset()         { *m = OperationRequest{} }
+func (m *OperationRequest) String() string { return proto.CompactTextString(m) }
+func (*OperationRequest) ProtoMessage()    {}
+func (*OperationRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{7}
+}
+
+func (m *OperationRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OperationRequest.Unmarshal(m, b)
+}
+func (m *OperationRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OperationRequest.Marshal(b, m, deterministic)
+}
+func (m *OperationRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OperationRequest.Merge(m, src)
+}
+func (m *OperationRequest) XXX_Size() int {
+	return xxx_messageInfo_OperationRequest.Size(m)
+}
+func (m *OperationRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_OperationRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OperationRequest proto.InternalMessageInfo
+
+func (m *OperationRequest) GetOperationId() string {
+	if m != nil {
+		return m.OperationId
+	}
+	return ""
+}
+
+type OperationStatus struct {
+	OperationId          string   `protobuf:"bytes,1,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
+	State                string   `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Status               string   `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Error                string   `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OperationStatus) Reset()         { *m = OperationStatus{} }
+func (m *OperationStatus) String() string { return proto.CompactTextString(m) }
+func (*OperationStatus) ProtoMessage()    {}
+func (*OperationStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d17a9d3f0ddf27e, []int{8}
+}
+
+func (m *OperationStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OperationStatus.Unmarshal(m, b)
+}
+func (m *OperationStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OperationStatus.Marshal(b, m, deterministic)
+}
+func (m *OperationStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OperationStatus.Merge(m, src)
+}
+func (m *OperationStatus) XXX_Size() int {
+	return xxx_messageInfo_OperationStatus.Size(m)
+}
+func (m *OperationStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_OperationStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OperationStatus proto.InternalMessageInfo
+
+func (m *OperationStatus) GetOperationId() string {
+	if m != nil {
+		return m.OperationId
+	}
+	return ""
+}
+
+func (m *OperationStatus) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *OperationStatus) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *OperationStatus) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 func init() {
-	proto1.RegisterType((*CloudEvent)(nil), "proto.CloudEvent")
-	proto1.RegisterType((*Result)(nil), "proto.Result")
+	proto.RegisterType((*CloudEvent)(nil), "proto.CloudEvent")
+	proto.RegisterMapType((map[string]string)(nil), "proto.CloudEvent.ExtensionsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "proto.CloudEvent.ParamsEntry")
+	proto.RegisterType((*Result)(nil), "proto.Result")
+	proto.RegisterType((*Progress)(nil), "proto.Progress")
+	proto.RegisterMapType((map[string]string)(nil), "proto.Progress.OutputEntry")
+	proto.RegisterType((*HandleUpdate)(nil), "proto.HandleUpdate")
+	proto.RegisterType((*DescribeRequest)(nil), "proto.DescribeRequest")
+	proto.RegisterType((*Description)(nil), "proto.Description")
+	proto.RegisterType((*ParamSpec)(nil), "proto.ParamSpec")
+	proto.RegisterType((*OperationRequest)(nil), "proto.OperationRequest")
+	proto.RegisterType((*OperationStatus)(nil), "proto.OperationStatus")
+}
+
+func init() { proto.RegisterFile("event.proto", fileDescriptor_2d17a9d3f0ddf27e) }
+
+var fileDescriptor_2d17a9d3f0ddf27e = []byte{
+	// 730 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xdd, 0x4e, 0xdb, 0x4c,
+	0x10, 0x95, 0x13, 0x62, 0x92, 0x71, 0xf8, 0x92, 0x6f, 0x8b, 0xa8, 0x95, 0xfe, 0x85, 0x48, 0x55,
+	0xd3, 0x56, 0x8a, 0xaa, 0x20, 0x2a, 0x5a, 0xc1, 0x45, 0xa1, 0xa8, 0xf4, 0x8a, 0xc8, 0x94, 0xde,
+	0x46, 0x1b, 0x7b, 0x04, 0x16, 0xb6, 0xd7, 0xec, 0xae, 0x29, 0xf4, 0xad, 0x2a, 0xf5, 0xa5, 0xda,
+	0xa7, 0xa8, 0x76, 0xbd, 0x76, 0x4c, 0x92, 0x1b, 0xae, 0xb2, 0x73, 0x66, 0xce, 0x78, 0xf6, 0xe4,
+	0xcc, 0x82, 0x83, 0x37, 0x98, 0xc8, 0x51, 0xca, 0x99, 0x64, 0xa4, 0xa1, 0x7f, 0x06, 0x7f, 0xea,
+	0x00, 0x47, 0x11, 0xcb, 0x82, 0x63, 0x95, 0x23, 0x7d, 0x70, 0x44, 0x8a, 0xfe, 0x0d, 0x72, 0x11,
+	0xb2, 0xc4, 0xb5, 0xfa, 0xd6, 0xb0, 0xe5, 0x55, 0x21, 0x42, 0x60, 0x4d, 0xde, 0xa5, 0xe8, 0xd6,
+	0x74, 0x4a, 0x9f, 0xc9, 0x16, 0xd8, 0x82, 0x65, 0xdc, 0x47, 0xb7, 0xae, 0x51, 0x13, 0x91, 0xff,
+	0xa0, 0x16, 0x06, 0xee, 0x9a, 0xc6, 0x6a, 0x61, 0xa0, 0xb9, 0x61, 0x8c, 0x6e, 0xc3, 0x70, 0xc3,
+	0x18, 0xc9, 0x53, 0x68, 0x09, 0xff, 0x12, 0x63, 0x9a, 0xf1, 0xc8, 0xb5, 0x75, 0x62, 0x0e, 0xa8,
+	0x79, 0x7c, 0x96, 0x48, 0x4c, 0xa4, 0xfe, 0xe8, 0x7a, 0x3e, 0x4f, 0x05, 0x52, 0x3d, 0x03, 0x2a,
+	0xa9, 0xdb, 0xec, 0x5b, 0xc3, 0xb6, 0xa7, 0xcf, 0xe4, 0x13, 0x00, 0xde, 0x4a, 0x4c, 0xd4, 0xc0,
+	0xc2, 0x6d, 0xf5, 0xeb, 0x43, 0x67, 0xbc, 0x9d, 0xdf, 0x7b, 0x34, 0xbf, 0xec, 0xe8, 0xb8, 0xac,
+	0x39, 0x4e, 0x24, 0xbf, 0xf3, 0x2a, 0x24, 0xb2, 0x0b, 0x76, 0x4a, 0x39, 0x8d, 0x85, 0x0b, 0x9a,
+	0xfe, 0x6c, 0x99, 0x3e, 0xd1, 0xf9, 0x9c, 0x6a, 0x8a, 0xc9, 0x36, 0xb4, 0x7d, 0x1a, 0x45, 0x33,
+	0xea, 0x5f, 0x4d, 0xd5, 0x85, 0x1c, 0x33, 0xb0, 0xc1, 0xce, 0x79, 0xd4, 0x3b, 0x80, 0xce, 0xc2,
+	0x87, 0x49, 0x17, 0xea, 0x57, 0x78, 0x67, 0xd4, 0x56, 0x47, 0xb2, 0x09, 0x8d, 0x1b, 0x1a, 0x65,
+	0x85, 0xcc, 0x79, 0xf0, 0xb1, 0xb6, 0x67, 0xf5, 0x3e, 0x80, 0x53, 0xf9, 0xf0, 0x43, 0xa8, 0x83,
+	0x23, 0xb0, 0x3d, 0x14, 0x59, 0x24, 0xf5, 0x1f, 0x26, 0xa9, 0xcc, 0x84, 0x21, 0x9a, 0x48, 0x8d,
+	0xcf, 0x52, 0xe4, 0x54, 0x86, 0x2c, 0x99, 0x86, 0x81, 0x69, 0xe1, 0x94, 0xd8, 0xd7, 0x60, 0xf0,
+	0xdb, 0x82, 0xe6, 0x84, 0xb3, 0x0b, 0x8e, 0x42, 0x90, 0xe7, 0x00, 0x29, 0x72, 0x1f, 0x13, 0x49,
+	0x2f, 0x50, 0xf7, 0xda, 0xf0, 0x2a, 0x08, 0x71, 0x61, 0x3d, 0x46, 0x21, 0x54, 0x32, 0x6f, 0x55,
+	0x84, 0x64, 0x07, 0x6c, 0x96, 0xc9, 0x34, 0x93, 0x6e, 0x5d, 0xeb, 0xfb, 0xc4, 0xe8, 0x5b, 0xb4,
+	0x1e, 0x9d, 0xea, 0xac, 0x51, 0x37, 0x2f, 0x55, 0x77, 0xaf, 0xc0, 0x0f, 0xba, 0xfb, 0x0c, 0xda,
+	0x27, 0x34, 0x09, 0x22, 0x3c, 0x4f, 0x03, 0x2a, 0x91, 0xbc, 0x85, 0x66, 0x6a, 0x3e, 0xa5, 0x1b,
+	0x38, 0xe3, 0xce, 0xc2, 0x04, 0x5e, 0x59, 0x40, 0x5e, 0x82, 0xcd, 0xb5, 0x70, 0xba, 0xaf, 0x33,
+	0xde, 0x30, 0xa5, 0xb9, 0x9a, 0x9e, 0x49, 0x0e, 0xf6, 0xa1, 0xf3, 0x19, 0x85, 0xcf, 0xc3, 0x19,
+	0x7a, 0x78, 0x9d, 0xa1, 0x90, 0xe4, 0x35, 0x74, 0x75, 0xa9, 0xcf, 0xa2, 0x69, 0x75, 0xa9, 0x36,
+	0xbc, 0x4e, 0x81, 0x7f, 0xcf, 0xe1, 0xc1, 0x2f, 0x0b, 0x9c, 0x9c, 0x9e, 0x4a, 0xb3, 0x68, 0x09,
+	0x8d, 0xd1, 0x5c, 0x4f, 0x9f, 0x95, 0x9e, 0x45, 0x17, 0xa3, 0xa7, 0x09, 0xc9, 0x0b, 0xb3, 0xdd,
+	0x53, 0xb5, 0x14, 0x42, 0x8b, 0xda, 0xf2, 0x40, 0x43, 0xdf, 0x14, 0x42, 0x86, 0xa5, 0xa1, 0xd7,
+	0xb4, 0xe0, 0xdd, 0xe2, 0xba, 0x0a, 0x3c, 0x4b, 0xd1, 0x2f, 0x3d, 0xbc, 0x6a, 0xe6, 0xc6, 0xea,
+	0x99, 0xaf, 0xa1, 0x55, 0xf2, 0x57, 0x0e, 0xbc, 0xea, 0xb5, 0xe8, 0x41, 0x93, 0xe3, 0x75, 0x16,
+	0x72, 0x0c, 0xf4, 0x7b, 0xd1, 0xf4, 0xca, 0x58, 0xed, 0x7b, 0x30, 0xd7, 0xc0, 0x3c, 0x1d, 0x55,
+	0x68, 0xb0, 0x0b, 0xdd, 0xd3, 0xc2, 0x8e, 0x85, 0xca, 0x8b, 0xb6, 0xb5, 0x96, 0x6d, 0x7b, 0x0b,
+	0x9d, 0x92, 0x76, 0xb6, 0xda, 0xec, 0xcb, 0x2c, 0xe5, 0x27, 0xb5, 0x19, 0xa5, 0x9f, 0x74, 0x50,
+	0xd9, 0x9e, 0xfa, 0xbd, 0xed, 0xd9, 0x84, 0x06, 0x72, 0xce, 0xb8, 0x19, 0x3b, 0x0f, 0xc6, 0x7f,
+	0x2d, 0x68, 0xeb, 0x07, 0x23, 0xf7, 0x1f, 0x27, 0x6f, 0xc0, 0xce, 0x8f, 0xe4, 0xff, 0xa5, 0x47,
+	0xa5, 0x77, 0xdf, 0x5a, 0x64, 0xaf, 0xb0, 0xed, 0x99, 0xe4, 0x48, 0xe3, 0x55, 0x8c, 0x47, 0x06,
+	0xaa, 0xda, 0xfb, 0x9d, 0x45, 0xde, 0x43, 0xb3, 0x30, 0x23, 0xd9, 0x32, 0x25, 0x0b, 0xee, 0xec,
+	0x91, 0x7b, 0x78, 0x6e, 0xbb, 0x7d, 0x68, 0x7d, 0x41, 0x69, 0x24, 0x7a, 0x6c, 0x0a, 0x16, 0x15,
+	0xef, 0x6d, 0x2d, 0x26, 0x72, 0xc2, 0xe1, 0x01, 0xbc, 0xf2, 0x59, 0x3c, 0x9a, 0xd1, 0xe4, 0x27,
+	0x0d, 0x7d, 0x35, 0xe4, 0xe8, 0x92, 0x45, 0x11, 0xfb, 0x21, 0x39, 0xa2, 0x18, 0x5d, 0xea, 0x19,
+	0xf5, 0xdc, 0x87, 0xdd, 0x93, 0x79, 0x30, 0x51, 0xcd, 0x26, 0xd6, 0xcc, 0xd6, 0x5d, 0x77, 0xfe,
+	0x0d, 0x00, 0x61, 0xe4, 0xfd, 0x9c, 0xaa, 0x06, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -143,10 +640,14 @@ var _ grpc.ClientConn
 // is compatible with the grpc package it is being compiled against.
 const _ = grpc.SupportPackageIsVersion4
 
-// Client API for EventHandler service
-
+// EventHandlerClient is the client API for EventHandler service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type EventHandlerClient interface {
 	Handle(ctx context.Context, in *CloudEvent, opts ...grpc.CallOption) (*Result, error)
+	HandleStream(ctx context.Context, in *CloudEvent, opts ...grpc.CallOption) (EventHandler_HandleStreamClient, error)
+	Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*Description, error)
+	GetStatus(ctx context.Context, in *OperationRequest, opts ...grpc.CallOption) (*OperationStatus, error)
 }
 
 type eventHandlerClient struct {
@@ -159,17 +660,69 @@ func NewEventHandlerClient(cc *grpc.ClientConn) EventHandlerClient {
 
 func (c *eventHandlerClient) Handle(ctx context.Context, in *CloudEvent, opts ...grpc.CallOption) (*Result, error) {
 	out := new(Result)
-	err := grpc.Invoke(ctx, "/proto.EventHandler/Handle", in, out, c.cc, opts...)
+	err := c.cc.Invoke(ctx, "/proto.EventHandler/Handle", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for EventHandler service
+func (c *eventHandlerClient) HandleStream(ctx context.Context, in *CloudEvent, opts ...grpc.CallOption) (EventHandler_HandleStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_EventHandler_serviceDesc.Streams[0], "/proto.EventHandler/HandleStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventHandlerHandleStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EventHandler_HandleStreamClient interface {
+	Recv() (*HandleUpdate, error)
+	grpc.ClientStream
+}
+
+type eventHandlerHandleStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventHandlerHandleStreamClient) Recv() (*HandleUpdate, error) {
+	m := new(HandleUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventHandlerClient) Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*Description, error) {
+	out := new(Description)
+	err := c.cc.Invoke(ctx, "/proto.EventHandler/Describe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventHandlerClient) GetStatus(ctx context.Context, in *OperationRequest, opts ...grpc.CallOption) (*OperationStatus, error) {
+	out := new(OperationStatus)
+	err := c.cc.Invoke(ctx, "/proto.EventHandler/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
+// EventHandlerServer is the server API for EventHandler service.
 type EventHandlerServer interface {
 	Handle(context.Context, *CloudEvent) (*Result, error)
+	HandleStream(*CloudEvent, EventHandler_HandleStreamServer) error
+	Describe(context.Context, *DescribeRequest) (*Description, error)
+	GetStatus(context.Context, *OperationRequest) (*OperationStatus, error)
 }
 
 func RegisterEventHandlerServer(s *grpc.Server, srv EventHandlerServer) {
@@ -194,6 +747,63 @@ func _EventHandler_Handle_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EventHandler_HandleStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CloudEvent)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventHandlerServer).HandleStream(m, &eventHandlerHandleStreamServer{stream})
+}
+
+type EventHandler_HandleStreamServer interface {
+	Send(*HandleUpdate) error
+	grpc.ServerStream
+}
+
+type eventHandlerHandleStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventHandlerHandleStreamServer) Send(m *HandleUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _EventHandler_Describe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventHandlerServer).Describe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.EventHandler/Describe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventHandlerServer).Describe(ctx, req.(*DescribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventHandler_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OperationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventHandlerServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.EventHandler/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventHandlerServer).GetStatus(ctx, req.(*OperationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _EventHandler_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.EventHandler",
 	HandlerType: (*EventHandlerServer)(nil),
@@ -202,34 +812,21 @@ var _EventHandler_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Handle",
 			Handler:    _EventHandler_Handle_Handler,
 		},
+		{
+			MethodName: "Describe",
+			Handler:    _EventHandler_Describe_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _EventHandler_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "HandleStream",
+			Handler:       _EventHandler_HandleStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "event.proto",
 }
-
-func init() { proto1.RegisterFile("event.proto", fileDescriptor0) }
-
-var fileDescriptor0 = []byte{
-	// 330 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x50, 0x41, 0x4f, 0xf2, 0x40,
-	0x10, 0xfd, 0x5a, 0xa0, 0x9f, 0x0c, 0xa8, 0xb8, 0x31, 0x66, 0x43, 0x3c, 0x54, 0x2e, 0x72, 0x30,
-	0x3d, 0xe0, 0xc5, 0x18, 0x39, 0x88, 0x21, 0xe1, 0x48, 0xfa, 0x0f, 0x96, 0x76, 0x12, 0x1a, 0xb7,
-	0xbb, 0x64, 0x77, 0x8b, 0xe2, 0xef, 0xf4, 0x07, 0x99, 0x9d, 0x56, 0x69, 0xf4, 0xd4, 0x79, 0xef,
-	0xed, 0x7b, 0x7d, 0x33, 0x30, 0xc0, 0x3d, 0x2a, 0x97, 0xec, 0x8c, 0x76, 0x9a, 0xf5, 0xe8, 0x33,
-	0xf9, 0x0c, 0x01, 0x5e, 0xa4, 0xae, 0xf2, 0xa5, 0xd7, 0x58, 0x0c, 0x03, 0xbb, 0xc3, 0x6c, 0x8f,
-	0xc6, 0x16, 0x5a, 0xf1, 0x20, 0x0e, 0xa6, 0xfd, 0xb4, 0x4d, 0x31, 0x06, 0x5d, 0x77, 0xd8, 0x21,
-	0x0f, 0x49, 0xa2, 0x99, 0x5d, 0x41, 0x64, 0x75, 0x65, 0x32, 0xe4, 0x1d, 0x62, 0x1b, 0xc4, 0xce,
-	0x20, 0x2c, 0x72, 0xde, 0x25, 0x2e, 0x2c, 0x72, 0xf2, 0x16, 0x25, 0xf2, 0x5e, 0xe3, 0x2d, 0x4a,
-	0x64, 0xd7, 0xd0, 0xb7, 0xd9, 0x16, 0x4b, 0x51, 0x19, 0xc9, 0x23, 0x12, 0x8e, 0x84, 0xef, 0x93,
-	0x69, 0xe5, 0x50, 0x39, 0xfa, 0xe9, 0xff, 0xba, 0x4f, 0x8b, 0xf2, 0x99, 0xb9, 0x70, 0x82, 0x9f,
-	0xc4, 0xc1, 0x74, 0x98, 0xd2, 0xcc, 0x9e, 0x01, 0xf0, 0xdd, 0xa1, 0xf2, 0x85, 0x2d, 0xef, 0xc7,
-	0x9d, 0xe9, 0x60, 0x76, 0x53, 0xef, 0x9d, 0x1c, 0x97, 0x4d, 0x96, 0x3f, 0x6f, 0x96, 0xca, 0x99,
-	0x43, 0xda, 0x32, 0x8d, 0xe7, 0x70, 0xfe, 0x4b, 0x66, 0x23, 0xe8, 0xbc, 0xe2, 0xa1, 0xb9, 0x89,
-	0x1f, 0xd9, 0x25, 0xf4, 0xf6, 0x42, 0x56, 0xdf, 0xc7, 0xa8, 0xc1, 0x63, 0xf8, 0x10, 0x4c, 0x62,
-	0x88, 0x52, 0xb4, 0x95, 0x74, 0x74, 0x1b, 0x27, 0x5c, 0x65, 0x1b, 0x63, 0x83, 0x66, 0x4f, 0x30,
-	0xa4, 0x16, 0x2b, 0xa1, 0x72, 0x89, 0x86, 0xdd, 0x41, 0x54, 0x8f, 0xec, 0xe2, 0x4f, 0xd3, 0xf1,
-	0x69, 0x43, 0xd5, 0x99, 0x93, 0x7f, 0x8b, 0x39, 0xdc, 0x66, 0xba, 0x4c, 0x36, 0x42, 0x7d, 0x88,
-	0x22, 0xf3, 0x0f, 0x93, 0xad, 0x96, 0x52, 0xbf, 0x39, 0x83, 0x68, 0x93, 0x2d, 0x25, 0x91, 0x77,
-	0x31, 0x5a, 0x1d, 0xc1, 0xda, 0xa7, 0xac, 0x83, 0x4d, 0x44, 0x71, 0xf7, 0x5f, 0x01, 0x00, 0x00,
-	0xff, 0xff, 0x30, 0x95, 0x57, 0x04, 0x12, 0x02, 0x00, 0x00,
-}
@@ -19,11 +19,14 @@ import (
 
 	"github.com/goph/emperror"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/banzaicloud/hollowtrees/pkg/grpcplugin/proto"
 )
 
-// Serve registers the EventHandler and starts the GRPC server
+// Serve registers the EventHandler and the standard GRPC health service and starts
+// the GRPC server, so Hollowtrees can balance events between plugin replicas
 func Serve(bindAddress string, handler EventHandler, opt ...grpc.ServerOption) error {
 	listener, err := net.Listen("tcp", bindAddress)
 	if err != nil {
@@ -32,6 +35,7 @@ func Serve(bindAddress string, handler EventHandler, opt ...grpc.ServerOption) e
 
 	grpcServer := grpc.NewServer(opt...)
 	proto.RegisterEventHandlerServer(grpcServer, NewHandler(handler))
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
 
 	return grpcServer.Serve(listener)
 }